@@ -4,6 +4,7 @@ package gqlgen_plugins
 
 import (
 	"fmt"
+	"go/token"
 	"go/types"
 	"os"
 	"path/filepath"
@@ -12,10 +13,12 @@ import (
 	"strings"
 
 	"github.com/99designs/gqlgen/codegen"
+	"github.com/99designs/gqlgen/codegen/config"
 	"github.com/99designs/gqlgen/codegen/templates"
 	"github.com/99designs/gqlgen/plugin"
 	"github.com/StevenACoffman/simplerr/errors"
 	"github.com/vektah/gqlparser/v2/ast"
+	"golang.org/x/tools/go/packages"
 
 	"github.com/StevenACoffman/gqlgen-plugins/errors/kind"
 )
@@ -34,8 +37,113 @@ var PackageRoot = "github.com/Khan/webapp/"
 // conversion.  (The name "mapper" is from ADR-312.)
 //
 // See @automap directive in pkg/graphql/shared-schemas/automap.graphql
+//
+// Instead of an error object with a code enum, the error field may be a union
+// of error types, each of which is @automapped from the errors it represents;
+// the mapper then returns the matching member.
 type Automap struct {
 	OutputDir string
+	// EmitRegistry, if set, additionally generates a map AutomapperFor from
+	// each GraphQL type-name to its generated automapper, for wiring the
+	// mappers into resolvers generically.
+	EmitRegistry bool
+	// MatchJoinedErrors, if set, makes the generated automappers look inside
+	// errors combined with errors.Join (see kind.IsInTree).  Each case of the
+	// generated switch then matches if any of the joined errors matches, so
+	// since the cases are in order of precedence, the highest-precedence code
+	// among all the joined errors wins.  Without it, we rely on errors.Is,
+	// which doesn't know about joined errors wrapped with Cause()-only
+	// wrappers.
+	MatchJoinedErrors bool
+	// IncludeFieldPath, if set, makes the generated automappers include the
+	// GraphQL path of the field being resolved (per gqlgen's
+	// graphql.GetFieldContext) in the debug message and in any logs.
+	IncludeFieldPath bool
+	// RecoverPanics, if set, makes the generated automappers recover from
+	// any panic in the mapping logic, log it at error level, and return the
+	// default code (if there is one; otherwise they return an error).
+	RecoverPanics bool
+	// ErrorFieldName, CodeFieldName, and DebugMessageFieldName are the Go
+	// names of the error field of the types we generate automappers for, and
+	// of the error-code and debug-message fields of the error type.  They
+	// default to "Error", "Code", and "DebugMessage" respectively.  In
+	// particular, if ErrorFieldName is empty we skip types without an Error
+	// field, so set it only if all of a service's payloads use another name.
+	ErrorFieldName, CodeFieldName, DebugMessageFieldName string
+	// GenerateTests, if set, additionally generates automap_test.go, with a
+	// test for each automapper checking that each error it's configured to
+	// handle maps to the right code.  The tests use khantest.Suite, so the
+	// generated package must be able to import it.
+	GenerateTests bool
+	// DefaultCodeNames are the error codes to which we map all errors not
+	// otherwise mapped, in order of preference: we use the first which is
+	// in a payload's code enum.  If none is, we return such errors to the
+	// GraphQL errors array instead.  It defaults to "INTERNAL",
+	// "INTERNAL_ERROR", and "UNEXPECTED_ERROR"; set it to an empty (non-nil)
+	// list to never use a default code.  An enum may instead name its default
+	// code explicitly, with @automap(default: "SERVER_ERROR") on the enum
+	// itself, which takes precedence over these.
+	DefaultCodeNames []string
+	// Logger, if set, is the package-path-qualified name of the function the
+	// generated automappers call to log errors, like
+	// github.com/myorg/mylib/logging.LogError.  It must have the signature
+	//
+	//	func(ctx context.Context, level string, err error)
+	//
+	// where level is "warn" or "error" (see AutomapError.Log), and the
+	// automappers then take a plain context.Context.  By default they take a
+	// context with a Log() method, per webapp's log.KAContext, and call that.
+	Logger string
+	// RelativePathBase says what relative paths in @automap(go: ...), like
+	// "./errors.ErrNotFound", are relative to: AutomapRelativeToSchema (the
+	// default) for the directory of the .graphql file which uses them,
+	// AutomapRelativeToOutput for OutputDir, or AutomapRelativeToRoot for
+	// RelativePathRoot.
+	RelativePathBase string
+	// RelativePathRoot is the directory against which to resolve relative
+	// paths if RelativePathBase is AutomapRelativeToRoot.  Like OutputDir, it
+	// may itself be relative to the working directory.
+	RelativePathRoot string
+	// VerifySentinels, if set, makes GenerateCode check that each Go
+	// sentinel named in an @automap(go: ...) directive exists, i.e. that its
+	// package loads and declares a variable of that name, so a typo fails
+	// generation rather than the build of the generated code.  This loads
+	// the packages, which is slow, so it's off by default.
+	VerifySentinels bool
+
+	// _cachedMappings are the error mappings MutateConfig found, for
+	// GenerateCode to reuse.
+	_cachedMappings map[_mappingsKey]*_errorMappings
+}
+
+// Bases for relative paths in @automap(go: ...); see
+// Automap.RelativePathBase.
+const (
+	// The directory of the .graphql file.
+	AutomapRelativeToSchema = "schema"
+	// Automap.OutputDir.
+	AutomapRelativeToOutput = "output"
+	// Automap.RelativePathRoot.
+	AutomapRelativeToRoot = "root"
+)
+
+// _defaultCodeNames is the default for Automap.DefaultCodeNames.
+var _defaultCodeNames = []string{"INTERNAL", "INTERNAL_ERROR", "UNEXPECTED_ERROR"}
+
+// _goFieldNames returns the configured Go names of the error, error-code, and
+// debug-message fields, or their defaults.
+func (p Automap) _goFieldNames() (errorName, codeName, debugMessageName string) {
+	errorName, codeName, debugMessageName = "Error", "Code", "DebugMessage"
+	if p.ErrorFieldName != "" {
+		errorName = p.ErrorFieldName
+	}
+	if p.CodeFieldName != "" {
+		codeName = p.CodeFieldName
+	}
+	if p.DebugMessageFieldName != "" {
+		debugMessageName = p.DebugMessageFieldName
+	}
+	return errorName, codeName, debugMessageName
 }
 
 var _incompleteMapping = errors.Wrap(kind.InvalidInput, "Not all enum values are @automapped")
@@ -43,10 +151,76 @@ var _incompleteMapping = errors.Wrap(kind.InvalidInput, "Not all enum values are
 var (
 	_ plugin.Plugin        = Automap{}
 	_ plugin.CodeGenerator = Automap{}
+	_ plugin.ConfigMutator = (*Automap)(nil)
 )
 
 func (Automap) Name() string { return "automap" }
 
+// MutateConfig doesn't mutate the config; instead we use it to check, before
+// gqlgen does all its work of loading packages and binding types, that each
+// type's error codes are all automapped, so that we can fail early if not.
+// (Only the pointer has this method, so register &Automap{...} to use it.)
+// We check only what we can from the schema; GenerateCode checks the rest,
+// reusing the mappings we find here.
+func (p *Automap) MutateConfig(cfg *config.Config) error {
+	errorName, codeName, _ := p._goFieldNames()
+	p._cachedMappings = map[_mappingsKey]*_errorMappings{}
+	// Sort, so that which error we return first is deterministic.
+	names := make([]string, 0, len(cfg.Schema.Types))
+	for name, def := range cfg.Schema.Types {
+		if def.Kind == ast.Object {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		def := cfg.Schema.Types[name]
+		codeEnum := _codeEnumFor(cfg.Schema, def, errorName, codeName)
+		if codeEnum == nil {
+			continue // not automapped, or GenerateCode will say why
+		}
+		mappings, err := p._getErrorMappings(def, codeEnum)
+		if err != nil {
+			continue // GenerateCode will report it
+		}
+		if len(mappings.Missing) > 0 {
+			return errors.WrapWithFields(_incompleteMapping,
+				errors.Fields{"obj": def.Name, "missing": mappings.Missing})
+		}
+		p._cachedMappings[_mappingsKey{def.Name, codeEnum.Name}] = mappings
+	}
+	return nil
+}
+
+// _codeEnumFor returns the error-code enum of the given object type, found
+// via its error field and the error type's code field, or nil if it has none.
+// Fields are matched by their default Go names, as gqlgen would bind them.
+func _codeEnumFor(
+	schema *ast.Schema,
+	def *ast.Definition,
+	errorName, codeName string,
+) *ast.Definition {
+	var errorObj *ast.Definition
+	for _, field := range def.Fields {
+		if templates.ToGo(field.Name) == errorName {
+			errorObj = schema.Types[field.Type.Name()]
+		}
+	}
+	if errorObj == nil || errorObj.Kind != ast.Object {
+		return nil
+	}
+	for _, field := range errorObj.Fields {
+		if templates.ToGo(field.Name) == codeName {
+			codeEnum := schema.Types[field.Type.Name()]
+			if codeEnum == nil || codeEnum.Kind != ast.Enum {
+				return nil
+			}
+			return codeEnum
+		}
+	}
+	return nil
+}
+
 // AutomapError represents how we map a particular error; see
 // See @automap directive for more.
 type AutomapError struct {
@@ -60,6 +234,48 @@ type AutomapError struct {
 	// Log may be set to "error" or "warn", if we should log this error at that
 	// level.  The default of "" says to not log.
 	Log string
+	// Severity may be set to AutomapSeverityClient or AutomapSeverityServer
+	// to say who is at fault for this error, for metrics and logs.  The
+	// default of "" says to infer it from From; see EffectiveSeverity.
+	Severity string
+}
+
+// Severities of automapped errors; see AutomapError.Severity.
+const (
+	// The error is the client's fault, like an HTTP 4xx.
+	AutomapSeverityClient = "client"
+	// The error is our fault, like an HTTP 5xx.
+	AutomapSeverityServer = "server"
+)
+
+// _serverErrorKinds are the names of the error-kinds (without any "Kind"
+// suffix, so as to match both kind.Internal and errors.InternalKind) which
+// are our fault, rather than the client's.
+var _serverErrorKinds = map[string]bool{
+	"Internal":             true,
+	"NotImplemented":       true,
+	"GraphqlResponse":      true,
+	"TransientKhanService": true,
+	"KhanService":          true,
+	"TransientService":     true,
+	"Service":              true,
+	"Unspecified":          true,
+}
+
+// EffectiveSeverity returns the severity of this error: Severity, if set,
+// and otherwise one inferred from the error's name.  Server-side kinds like
+// Internal and Service are AutomapSeverityServer; everything else, including
+// kinds like NotFound and InvalidInput, is AutomapSeverityClient.  (Mapping
+// an error that isn't a kind to its own code usually means it's an expected,
+// domain-specific failure; if not, set Severity.)
+func (e AutomapError) EffectiveSeverity() string {
+	if e.Severity != "" {
+		return e.Severity
+	}
+	if _serverErrorKinds[strings.TrimSuffix(e.Name(), "Kind")] {
+		return AutomapSeverityServer
+	}
+	return AutomapSeverityClient
 }
 
 // Validate returns an error if this is not a valid mapping.
@@ -86,6 +302,11 @@ func (e AutomapError) Validate(enum ast.EnumValueList) error {
 			errors.Fields{"message": "invalid error mapping: log, if set, must be 'error' or 'warn'.", "got": e.Log})
 	}
 
+	if e.Severity != "" && e.Severity != AutomapSeverityClient && e.Severity != AutomapSeverityServer {
+		return errors.WrapWithFields(kind.InvalidInput,
+			errors.Fields{"message": "invalid error mapping: severity, if set, must be 'client' or 'server'.", "got": e.Severity})
+	}
+
 	return nil
 }
 
@@ -101,6 +322,29 @@ func (e AutomapError) Name() string {
 	return e.From[i+1:]
 }
 
+// _parseLogger splits the given Automap.Logger into its package-path and
+// function name, or returns an error if it's not of the form pkg/path.Func.
+func _parseLogger(logger string) (pkgPath, name string, err error) {
+	dotIndex := strings.LastIndex(logger, ".")
+	if dotIndex == -1 || strings.Contains(logger[dotIndex+1:], "/") {
+		return "", "", errors.WrapWithFields(kind.InvalidInput,
+			errors.Fields{"message": "invalid logger: should be pkg/path.Func", "logger": logger})
+	}
+	pkgPath, name = logger[:dotIndex], logger[dotIndex+1:]
+	if pkgPath == "" || strings.HasSuffix(pkgPath, "/") ||
+		strings.HasPrefix(pkgPath, "./") || strings.HasPrefix(pkgPath, "../") {
+		return "", "", errors.WrapWithFields(kind.InvalidInput,
+			errors.Fields{"message": "invalid logger: package-path should be a full import path",
+				"logger": logger})
+	}
+	if !token.IsIdentifier(name) || !token.IsExported(name) {
+		return "", "", errors.WrapWithFields(kind.InvalidInput,
+			errors.Fields{"message": "invalid logger: should name an exported function",
+				"logger": logger})
+	}
+	return pkgPath, name, nil
+}
+
 // _automapTemplateData is the object we pass to automap.gotpl.
 type _automapTemplateData struct {
 	// the mappers to generate
@@ -108,6 +352,45 @@ type _automapTemplateData struct {
 	// information about any mappers we couldn't generate (but that were not
 	// explicitly requested); we'll include this in comments.
 	Errors []string
+	// the (non-root) types we didn't generate mappers for because they have
+	// no error field; we'll list these in comments too.
+	Skipped []string
+	// the entries of the AutomapperFor map, if Automap.EmitRegistry is set
+	Registry []_automapRegistryEntry
+	// whether to match errors with kind.IsInTree rather than errors.Is; see
+	// Automap.MatchJoinedErrors
+	MatchJoinedErrors bool
+	// whether to include the GraphQL path in debug messages and logs; see
+	// Automap.IncludeFieldPath
+	IncludeFieldPath bool
+	// whether to recover from panics in the automappers; see
+	// Automap.RecoverPanics
+	RecoverPanics bool
+	// the package-path and name of the function with which to log, or "" to
+	// use ctx.Log(); see Automap.Logger
+	LoggerPkgPath, LoggerName string
+}
+
+// _automapRegistryEntry is an entry in the generated AutomapperFor map.
+type _automapRegistryEntry struct {
+	GraphQLTypeName string
+	MapperName      string
+}
+
+// _automapRegistry returns the entries of the AutomapperFor map for the given
+// mappers, sorted by GraphQL type-name so the generated code is stable.
+func _automapRegistry(mappers []*_automapper) []_automapRegistryEntry {
+	registry := make([]_automapRegistryEntry, len(mappers))
+	for i, mapper := range mappers {
+		registry[i] = _automapRegistryEntry{
+			GraphQLTypeName: mapper.GraphQLTypeName,
+			MapperName:      mapper.MapperName,
+		}
+	}
+	sort.Slice(registry, func(i, j int) bool {
+		return registry[i].GraphQLTypeName < registry[j].GraphQLTypeName
+	})
+	return registry
 }
 
 // _automapper is the configuration for each automapper we will
@@ -130,13 +413,25 @@ type _automapper struct {
 	// GraphQLModel, GraphQLError, and GraphQLErrorCode are the Go types to
 	// which we are mapping, for the whole model, the error field, and the
 	// error-code field, respectively.  Actually, the first two are the
-	// struct-types; the models-values are in fact pointers to those but that
-	// is not represented in this type, to save unwrapping and rewarapping.  In
-	// the above example, these would be `graphql.MyMutation`,
-	// `graphql.MyMutationError`, and `graphql.MyMutationErrorCode`.
-	// TODO(benkraft): Handle any cases that come up where they aren't pointers
-	// (e.g. can error be a slice or not a pointer? can code be optional?)
+	// struct-types; the model-values are usually pointers to those, but that
+	// is represented by ModelIsPointer and ErrorIsPointer, to save unwrapping
+	// and rewrapping.  In the above example, these would be
+	// `graphql.MyMutation`, `graphql.MyMutationError`, and
+	// `graphql.MyMutationErrorCode`.
+	//
+	// The generated code assumes the error-code field is a value, i.e. the
+	// code is non-null in the schema; _getAutomapData checks this.
 	GraphQLModel, GraphQLError, GraphQLErrorCode types.Type
+	// ModelIsPointer is set if the resolvers return a *GraphQLModel rather
+	// than a GraphQLModel, in which case so does the mapper.  This is what
+	// gqlgen generates by default; resolvers may return values if
+	// resolvers_always_return_pointers is false.
+	ModelIsPointer bool
+	// ErrorIsPointer is set if the error field has type *GraphQLError (or
+	// []*GraphQLError, if ErrorFieldIsList) rather than GraphQLError.  This is
+	// what gqlgen generates by default; non-null fields may be values if
+	// struct_fields_always_pointers is false.
+	ErrorIsPointer bool
 	// ErrorField and ErrorCodeField are the Go names of the error and
 	// error field of GraphQLModel and the error-code and debug-message fields
 	// of GraphQLError respectively.  (They have types GraphQLError,
@@ -145,6 +440,10 @@ type _automapper struct {
 	// In the above example, these would be "Error", "Code", and
 	// "DebugMessage".
 	ErrorField, ErrorCodeField, DebugMessageField string
+	// ErrorFieldIsList is set if the error field is a list of errors, like
+	// `errors: [MyMutationError!]`.  The mapper then returns a list of the
+	// one mapped error, or an empty list if there is no error.
+	ErrorFieldIsList bool
 	// Errors provides information about which errors we map to what, in order
 	// of precedence.
 	Errors []AutomapError
@@ -157,6 +456,32 @@ type _automapper struct {
 	// *string rather than string.  (In the above example it would be false,
 	// because debugMessage is required in the schema.)
 	DebugMessageIsPointer bool
+	// UnionMembers is set if the error field is a union of error types,
+	// rather than an object with a code enum, like
+	//
+	//	union MyMutationError = NotFoundError | UnauthorizedError
+	//
+	// It maps the GraphQL name of each member (which is the To of each of
+	// Errors) to how we build it.  GraphQLError is then the union's Go
+	// interface type, and GraphQLErrorCode, ErrorCodeField, DebugMessageField
+	// and DefaultCode are unused: there's no code to default to, so we map
+	// unmapped errors to the GraphQL errors array.
+	UnionMembers map[string]*_automapUnionMember
+	// UnionDebugMessage is set if any of UnionMembers has a debug-message
+	// field.
+	UnionDebugMessage bool
+}
+
+// _automapUnionMember is a member of an error union; see
+// _automapper.UnionMembers.
+type _automapUnionMember struct {
+	// GraphQLType is the Go struct-type of the member; the mapper returns a
+	// pointer to it, which implements the union's interface.
+	GraphQLType types.Type
+	// DebugMessageField and DebugMessageIsPointer are as for _automapper,
+	// but for this member.
+	DebugMessageField     string
+	DebugMessageIsPointer bool
 }
 
 // _defaultErrorMappings are the default error codes we'll map
@@ -253,17 +578,44 @@ func _getArgumentFromDirective(directive *ast.Directive, arg string) string {
 }
 
 // Convert a relpath to be a go-style package name.  The relpath is
-// taken to be relative to the directory that `obj` lives in.
-func _relpathToPackage(obj *codegen.Object, relpath string) (string, error) {
+// taken to be relative to the directory that `def` lives in, or another
+// directory per p.RelativePathBase.
+func (p Automap) _relpathToPackage(def *ast.Definition, relpath string) (string, error) {
 	// Where the object lives is a relative path.  gqlparser doesn't
 	// say, but mI assume it's relative to the gqlgen.yml file, which
 	// I think has to be in the current directory when running gqlgen.
-	objAbspath, err := filepath.Abs(obj.Definition.Position.Src.Name)
+	objAbspath, err := filepath.Abs(def.Position.Src.Name)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	schemaDir := filepath.Dir(objAbspath)
+	outputDir, err := filepath.Abs(p.OutputDir)
 	if err != nil {
 		return "", errors.WithStack(err)
 	}
 
-	abspath := filepath.Clean(filepath.Join(filepath.Dir(objAbspath), relpath))
+	var baseDir string
+	switch p.RelativePathBase {
+	case "", AutomapRelativeToSchema:
+		baseDir = schemaDir
+	case AutomapRelativeToOutput:
+		baseDir = outputDir
+	case AutomapRelativeToRoot:
+		if p.RelativePathRoot == "" {
+			return "", errors.WrapWithFields(kind.InvalidInput,
+				errors.Fields{"message": "RelativePathRoot must be set to resolve paths relative to it"})
+		}
+		baseDir, err = filepath.Abs(p.RelativePathRoot)
+		if err != nil {
+			return "", errors.WithStack(err)
+		}
+	default:
+		return "", errors.WrapWithFields(kind.InvalidInput,
+			errors.Fields{"message": "invalid RelativePathBase: must be 'schema', 'output', or 'root'",
+				"got": p.RelativePathBase})
+	}
+
+	abspath := filepath.Clean(filepath.Join(baseDir, relpath))
 	dotIndex := strings.LastIndex(abspath, ".")
 	if strings.Contains(abspath[dotIndex+1:], "/") {
 		return "", errors.WrapWithFields(kind.InvalidInput,
@@ -275,11 +627,18 @@ func _relpathToPackage(obj *codegen.Object, relpath string) (string, error) {
 			errors.Fields{"message": "invalid package-path: should be ./path.Symbol",
 				"path": pkgAbspath})
 	}
-	// Check that the path is a valid package.
+	// Check that the path is a valid package.  If not, we say where it
+	// would be relative to both the schema and the output directory, in case
+	// RelativePathBase is wrong.
 	stat, err := os.Stat(pkgAbspath)
 	if err != nil {
+		relPkgpath := strings.TrimSuffix(relpath, abspath[dotIndex:])
 		return "", errors.WrapWithFields(kind.InvalidInput,
-			errors.Fields{"message": "invalid package-path: nonexistent directory", "path": pkgAbspath, "originErr": err})
+			errors.Fields{"message": "invalid package-path: nonexistent directory", "path": pkgAbspath,
+				"relativePathBase": p.RelativePathBase,
+				"schemaDirPath":    filepath.Join(schemaDir, relPkgpath),
+				"outputDirPath":    filepath.Join(outputDir, relPkgpath),
+				"originErr":        err})
 	}
 	if !stat.IsDir() {
 		return "", errors.WrapWithFields(kind.InvalidInput,
@@ -292,9 +651,12 @@ func _relpathToPackage(obj *codegen.Object, relpath string) (string, error) {
 		return "", errors.Wrap(kind.Internal, "unable to get working directory")
 	}
 
-	path := filepath.Join(currWd, abspath)
+	path, err := filepath.Rel(currWd, abspath)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
 
-	return PackageRoot + path, nil
+	return PackageRoot + filepath.ToSlash(path), nil
 }
 
 // _getAutomapData returns the template data needed to generate the automapper
@@ -310,18 +672,22 @@ func _relpathToPackage(obj *codegen.Object, relpath string) (string, error) {
 //
 //	obj is the type for which we are generating an automapper
 //	objects is the map of GraphQL type-name to object, for all object types
-func _getAutomapData(
+func (p Automap) _getAutomapData(
 	obj *codegen.Object,
 	objects map[string]*codegen.Object,
 ) (*_automapper, error) {
-	// TODO(benkraft): Allow configuring the field-name we look for, if
-	// we ever need it. (Same for "Code", below.)
-	errorField := _findField(obj, "Error")
+	errorName, codeName, debugMessageName := p._goFieldNames()
+	errorField := _findField(obj, errorName)
 	if errorField == nil {
-		// If the object doesn't have an Error field, we can safely ignore it
+		// If the object doesn't have an error field, we can safely ignore it
 		return nil, nil
 	}
 
+	if errorField.TypeReference != nil && errorField.TypeReference.Definition != nil &&
+		errorField.TypeReference.Definition.Kind == ast.Union {
+		return p._getUnionAutomapData(obj, errorField, objects)
+	}
+
 	errorObj := objects[errorField.FieldDefinition.Type.Name()]
 	if errorObj == nil {
 		// error is not a GraphQL object (maybe a string).
@@ -330,9 +696,10 @@ func _getAutomapData(
 				"got": errorField.FieldDefinition.Type.Name()})
 	}
 
-	codeField := _findField(errorObj, "Code")
+	codeField := _findField(errorObj, codeName)
 	if codeField == nil {
-		return nil, errors.Wrap(kind.InvalidInput, "no error-code field found")
+		return nil, errors.WrapWithFields(kind.InvalidInput,
+			errors.Fields{"message": "no error-code field found", "want": codeName})
 	}
 
 	if codeField.TypeReference.Definition.Kind != ast.Enum {
@@ -340,7 +707,28 @@ func _getAutomapData(
 			errors.Fields{"message": "error field was not an enum type",
 				"got": codeField.TypeReference.Definition.Kind})
 	}
-	enumValues := codeField.TypeReference.Definition.EnumValues
+	// The generated code builds the error as [&]GraphQLError{Code: code}, so
+	// the error field must be the error type or a pointer to it (or a slice
+	// of those, for a list of errors), and the code a plain value.
+	errorGoType, errorFieldIsList, err := _errorFieldElem(errorField)
+	if err != nil {
+		return nil, err
+	}
+	errorIsPointer, err := _isPointerTo(errorGoType, errorObj.Type)
+	if err != nil {
+		return nil, errors.WrapWithFields(err,
+			errors.Fields{"message": "error field's Go type must be the error type or a pointer to it",
+				"got": errorField.TypeReference.GO.String()})
+	}
+	modelIsPointer, err := _resolversReturnPointers(obj, objects)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := codeField.TypeReference.GO.(*types.Pointer); ok {
+		return nil, errors.WrapWithFields(kind.InvalidInput,
+			errors.Fields{"message": "error-code field must be non-null",
+				"got": codeField.FieldDefinition.Type.String()})
+	}
 
 	// Second, build the template data.
 	var templateData _automapper
@@ -351,117 +739,506 @@ func _getAutomapData(
 	templateData.MapperName = goTypeName + "Err"
 	templateData.GraphQLTypeName = obj.Definition.Name
 
-	// TODO(benkraft): somewhere we should perhaps validate that these
-	// types "look right", e.g. that we don't have a []*MyMutationError
-	// instead of a *MyMutationError.  (If that happens the generated
-	// code will not compile.)  In practice it doesn't seem to come up
-	// when our other conditions are met.
+	// (We checked the shape of the error and code types above.)
 	templateData.GraphQLModel = obj.Type
 	templateData.GraphQLError = errorObj.Type
 	templateData.GraphQLErrorCode = codeField.TypeReference.Target
+	templateData.ModelIsPointer = modelIsPointer
+	templateData.ErrorIsPointer = errorIsPointer
 
 	templateData.ErrorField = errorField.GoFieldName
 	templateData.ErrorCodeField = codeField.GoFieldName
+	templateData.ErrorFieldIsList = errorFieldIsList
+
+	mappings := p._cachedMappings[_mappingsKey{obj.Definition.Name, codeField.TypeReference.Definition.Name}]
+	if mappings == nil {
+		mappings, err = p._getErrorMappings(obj.Definition, codeField.TypeReference.Definition)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(mappings.Missing) > 0 {
+		// Not all enum values in this enum are mapped either explicitly or by
+		// default, soe want to raise this as an error and refuse to generate.
+		// The error will appear in generated/autogen/autogen.go for
+		// visibility.
+		return nil, errors.WrapWithFields(kind.InvalidInput,
+			errors.Fields{"message": "Not all values automapped",
+				"obj": obj.Name, "missing": mappings.Missing})
+	}
+	templateData.Errors = mappings.Errors
+	templateData.DefaultCode = mappings.DefaultCode
+
+	templateData.DebugMessageField, templateData.DebugMessageIsPointer =
+		_debugMessageField(errorObj, debugMessageName)
+
+	return &templateData, nil
+}
+
+// _getUnionAutomapData is _getAutomapData for an object whose error field is
+// a union of error types, each of which says which errors map to it, like
+//
+//	union MyMutationError = NotFoundError | UnauthorizedError
+//	type NotFoundError @automap(go: "github.com/StevenACoffman/simplerr/errors.NotFoundKind") {
+//		debugMessage: String!
+//	}
+//
+// Every member must be @automapped.  See also _automapper.UnionMembers.
+func (p Automap) _getUnionAutomapData(
+	obj *codegen.Object,
+	errorField *codegen.Field,
+	objects map[string]*codegen.Object,
+) (*_automapper, error) {
+	_, _, debugMessageName := p._goFieldNames()
+	union := errorField.TypeReference.Definition
+
+	// The generated code sets the error field to a member (or a slice of
+	// one), so it must have the union's interface type.
+	errorGoType, errorFieldIsList, err := _errorFieldElem(errorField)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := errorGoType.(*types.Pointer); ok {
+		return nil, errors.WrapWithFields(kind.InvalidInput,
+			errors.Fields{"message": "union error field's Go type must be the union's interface type",
+				"got": errorField.TypeReference.GO.String()})
+	}
+	modelIsPointer, err := _resolversReturnPointers(obj, objects)
+	if err != nil {
+		return nil, err
+	}
+
+	var templateData _automapper
+	unqualified := func(*types.Package) string { return "" }
+	templateData.MapperName = types.TypeString(obj.Type, unqualified) + "Err"
+	templateData.GraphQLTypeName = obj.Definition.Name
+	templateData.GraphQLModel = obj.Type
+	templateData.GraphQLError = errorGoType
+	templateData.ModelIsPointer = modelIsPointer
+	templateData.ErrorField = errorField.GoFieldName
+	templateData.ErrorFieldIsList = errorFieldIsList
+	templateData.UnionMembers = map[string]*_automapUnionMember{}
+
+	// Validate checks that each mapping's To is one of the given values; for
+	// a union those are the members.
+	members := make(ast.EnumValueList, len(union.Types))
+	for i, name := range union.Types {
+		members[i] = &ast.EnumValueDefinition{Name: name}
+	}
+	var missing []string
+	for _, name := range union.Types {
+		memberObj := objects[name]
+		if memberObj == nil {
+			return nil, errors.WrapWithFields(kind.InvalidInput,
+				errors.Fields{"message": "error union member was not a valid object type",
+					"union": union.Name, "got": name})
+		}
+		automapDirective := memberObj.Definition.Directives.ForName("automap")
+		if automapDirective == nil {
+			missing = append(missing, name)
+			continue
+		}
+		mappings, err := p._getDirectiveMappings(memberObj.Definition, automapDirective, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, automapError := range mappings {
+			err := automapError.Validate(members)
+			if err != nil {
+				return nil, err
+			}
+			templateData.Errors = append(templateData.Errors, automapError)
+		}
 
+		member := &_automapUnionMember{GraphQLType: memberObj.Type}
+		member.DebugMessageField, member.DebugMessageIsPointer =
+			_debugMessageField(memberObj, debugMessageName)
+		if member.DebugMessageField != "" {
+			templateData.UnionDebugMessage = true
+		}
+		templateData.UnionMembers[name] = member
+	}
+	if len(missing) > 0 {
+		// As for codes, we refuse to generate a mapper which can't return
+		// some of the members.
+		return nil, errors.WrapWithFields(kind.InvalidInput,
+			errors.Fields{"message": "Not all union members automapped",
+				"obj": obj.Name, "missing": missing})
+	}
+
+	err = _validateReachableMappings(templateData.Errors)
+	if err != nil {
+		return nil, err
+	}
+	return &templateData, nil
+}
+
+// _errorFieldElem returns the Go type of the given error field, or of its
+// elements if it's a list (in which case isList is set), or an error if it's
+// a list we can't build.
+func _errorFieldElem(errorField *codegen.Field) (elem types.Type, isList bool, err error) {
+	errorGoType := errorField.TypeReference.GO
+	if errorField.FieldDefinition.Type.Elem == nil {
+		return errorGoType, false, nil
+	}
+	if errorField.FieldDefinition.Type.Elem.Elem != nil {
+		return nil, false, errors.WrapWithFields(kind.InvalidInput,
+			errors.Fields{"message": "error field may not be a nested list",
+				"got": errorField.FieldDefinition.Type.String()})
+	}
+	slice, ok := errorGoType.(*types.Slice)
+	if !ok {
+		return nil, false, errors.WrapWithFields(kind.InvalidInput,
+			errors.Fields{"message": "list error field's Go type must be a slice",
+				"got": errorGoType.String()})
+	}
+	return slice.Elem(), true, nil
+}
+
+// _debugMessageField returns the Go name of the given error object's
+// debug-message field, and whether it's a *string rather than a string, or ""
+// if it has no such field of either type.
+func _debugMessageField(errorObj *codegen.Object, debugMessageName string) (string, bool) {
+	debugMessageField := _findField(errorObj, debugMessageName)
+	if debugMessageField == nil {
+		return "", false
+	}
+	switch debugMessageField.TypeReference.GO.String() {
+	case "string":
+		return debugMessageField.GoFieldName, false
+	case "*string":
+		return debugMessageField.GoFieldName, true
+	default:
+		// some other type we don't know how to generate
+		return "", false
+	}
+}
+
+// _errorMappings is the part of an automapper which depends only on the
+// schema: the error mappings, configured and default, and the default code.
+type _errorMappings struct {
+	Errors      []AutomapError
+	DefaultCode string
+	// Missing lists the enum values which are mapped neither explicitly nor
+	// by default.
+	Missing []string
+}
+
+// _mappingsKey identifies the _errorMappings for a given GraphQL object and
+// error-code enum.
+type _mappingsKey struct{ objName, enumName string }
+
+// _getErrorMappings returns the error mappings for the given object, per the
+// @automap directives on its error-code enum and the enum's values, or an
+// error if they are invalid.  It's up to the caller to check that they're
+// complete.
+func (p Automap) _getErrorMappings(
+	def *ast.Definition,
+	enum *ast.Definition,
+) (*_errorMappings, error) {
+	var mappings _errorMappings
+	enumValues := enum.EnumValues
 	// Build the error mappings using automap directives
 	handledEnumValues := map[string]bool{}
+	configuredFroms := map[string]bool{}
 	for _, e := range enumValues {
 		automapDirective := e.Directives.ForName("automap")
 		if automapDirective != nil {
-			// Typestring is something like
-			// "github.com/StevenACoffman/simplerr/errors.NotFoundKind"
-			// or "../../pkg/lib/errors.NotFoundKind"
-			typeStrings, err := _getListArgumentFromDirective(automapDirective, "go")
+			directiveErrors, err := p._getDirectiveMappings(def, automapDirective, e.Name)
 			if err != nil {
 				return nil, err
 			}
-			for _, typeString := range typeStrings {
-				if typeString == "" {
-					continue
-				}
-				// Take it to be relative the directory of the .graphql
-				// file if typeString is a relative path
-				// (starts with ./ or ../)
-				if strings.HasPrefix(typeString, "./") ||
-					strings.HasPrefix(typeString, "../") {
-					var err error
-					typeString, err = _relpathToPackage(obj, typeString)
-					if err != nil {
-						return nil, err
-					}
-				}
-
-				automapError := AutomapError{
-					From: typeString,
-					To:   e.Name,
-					// TODO(jeremygervais) handle the case where only the
-					// log is present like: UNAUTHORIZED @automap(logLevel:
-					// "warn")
-					Log: _getArgumentFromDirective(automapDirective, "log"),
-				}
+			for _, automapError := range directiveErrors {
 				err := automapError.Validate(enumValues)
 				if err != nil {
 					return nil, err
 				}
-				templateData.Errors = append(templateData.Errors, automapError)
+				mappings.Errors = append(mappings.Errors, automapError)
+				configuredFroms[automapError.From] = true
 			}
 			handledEnumValues[e.Name] = true
 		}
 	}
 
+	err := _validateReachableMappings(mappings.Errors)
+	if err != nil {
+		return nil, err
+	}
+
 	for _, e := range _defaultErrorMappings {
-		// TODO(benkraft): Omit any default mappings that have the same From
-		// as a configured mapping (they will generate duplicate cases, which
-		// are dead code).  This can happen if you wanted to change a standard
-		// error-kind to map to a nonstandard code, or make it log.
+		// Omit any default mappings that have the same From as a configured
+		// mapping: they would generate duplicate cases, which are dead code.
+		// This happens if you map a standard error-kind to a nonstandard
+		// code, or make it log differently.  (We still count the standard
+		// code as handled, as we did when we generated the dead case, so
+		// that it needn't be @automapped.)
 		if e.Validate(enumValues) == nil {
-			templateData.Errors = append(templateData.Errors, e)
+			if !configuredFroms[e.From] {
+				mappings.Errors = append(mappings.Errors, e)
+			}
 			handledEnumValues[e.To] = true
 		} // it's fine if these don't exist.
 	}
 
-	switch {
-	case enumValues.ForName("INTERNAL") != nil:
-		templateData.DefaultCode = "INTERNAL"
-		handledEnumValues["INTERNAL"] = true
-	case enumValues.ForName("INTERNAL_ERROR") != nil:
-		templateData.DefaultCode = "INTERNAL_ERROR"
-		handledEnumValues["INTERNAL_ERROR"] = true
-	case enumValues.ForName("UNEXPECTED_ERROR") != nil:
-		templateData.DefaultCode = "UNEXPECTED_ERROR"
-		handledEnumValues["UNEXPECTED_ERROR"] = true
+	// The enum may say its default code explicitly, with
+	// @automap(default: "SERVER_ERROR"); otherwise we guess from the names.
+	var explicitDefault string
+	if automapDirective := enum.Directives.ForName("automap"); automapDirective != nil {
+		explicitDefault = _getArgumentFromDirective(automapDirective, "default")
 	}
-
-	if len(handledEnumValues) < len(enumValues) {
-		missingEnums := make([]string, 0)
-		for _, e := range enumValues {
-			if _, ok := handledEnumValues[e.Name]; !ok {
-				missingEnums = append(missingEnums, e.Name)
+	if explicitDefault != "" {
+		if enumValues.ForName(explicitDefault) == nil {
+			return nil, errors.WrapWithFields(kind.InvalidInput,
+				errors.Fields{"message": "invalid default code: not a value of the enum",
+					"enum": enum.Name, "default": explicitDefault})
+		}
+		mappings.DefaultCode = explicitDefault
+		handledEnumValues[explicitDefault] = true
+	} else {
+		defaultCodeNames := p.DefaultCodeNames
+		if defaultCodeNames == nil {
+			defaultCodeNames = _defaultCodeNames
+		}
+		for _, name := range defaultCodeNames {
+			if enumValues.ForName(name) != nil {
+				mappings.DefaultCode = name
+				handledEnumValues[name] = true
+				break
 			}
 		}
-		// Not all enum values in this enum are mapped either explicitly or by
-		// default, soe want to raise this as an error and refuse to generate.
-		// The error will appear in generated/autogen/autogen.go for
-		// visibility.
+	}
+
+	for _, e := range enumValues {
+		if !handledEnumValues[e.Name] {
+			mappings.Missing = append(mappings.Missing, e.Name)
+		}
+	}
+
+	return &mappings, nil
+}
+
+// _getDirectiveMappings returns the mappings configured by the given
+// @automap directive, from each of its Go sentinels to the given code (or,
+// for an error union, member).  It's up to the caller to Validate them.
+func (p Automap) _getDirectiveMappings(
+	def *ast.Definition,
+	automapDirective *ast.Directive,
+	to string,
+) ([]AutomapError, error) {
+	// Typestring is something like
+	// "github.com/StevenACoffman/simplerr/errors.NotFoundKind"
+	// or "../../pkg/lib/errors.NotFoundKind"
+	typeStrings, err := _getListArgumentFromDirective(automapDirective, "go")
+	if err != nil {
+		return nil, err
+	}
+	// log may be a single level, for all the sentinels, or a list
+	// with one level per sentinel.
+	logs, err := _getListArgumentFromDirective(automapDirective, "log")
+	if err != nil {
+		return nil, err
+	}
+	if len(logs) > 1 && len(logs) != len(typeStrings) {
 		return nil, errors.WrapWithFields(kind.InvalidInput,
-			errors.Fields{"message": "Not all values automapped",
-				"obj": obj.Name, "missing": missingEnums})
+			errors.Fields{"message": "invalid error mapping: log must be a single level " +
+				"or one level per go sentinel",
+				"to": to, "go": typeStrings, "log": logs})
 	}
+	var mappings []AutomapError
+	for i, typeString := range typeStrings {
+		if typeString == "" {
+			continue
+		}
+		// Take it to be relative the directory of the .graphql
+		// file if typeString is a relative path
+		// (starts with ./ or ../)
+		if strings.HasPrefix(typeString, "./") ||
+			strings.HasPrefix(typeString, "../") {
+			var err error
+			typeString, err = p._relpathToPackage(def, typeString)
+			if err != nil {
+				return nil, err
+			}
+		}
 
-	debugMessageField := _findField(errorObj, "DebugMessage")
-	if debugMessageField != nil {
-		switch debugMessageField.TypeReference.GO.String() {
-		case "string":
-			templateData.DebugMessageField = debugMessageField.GoFieldName
-		case "*string":
-			templateData.DebugMessageField = debugMessageField.GoFieldName
-			templateData.DebugMessageIsPointer = true
+		automapError := AutomapError{
+			From: typeString,
+			To:   to,
+			// TODO(jeremygervais) handle the case where only the
+			// log is present like: UNAUTHORIZED @automap(logLevel:
+			// "warn")
+			Severity: _getArgumentFromDirective(automapDirective, "severity"),
+		}
+		switch len(logs) {
+		case 0:
+		case 1:
+			automapError.Log = logs[0]
 		default:
-			// some other type we don't know how to generate
+			automapError.Log = logs[i]
 		}
+		mappings = append(mappings, automapError)
 	}
+	return mappings, nil
+}
 
-	return &templateData, nil
+// _isPointerTo returns whether goType is a pointer to target, or an error if
+// it's neither that nor target itself.  Object types are usually named, but
+// we also unwrap a pointer from target, in case it was bound to one.
+//
+// We compare the types by (package-qualified) name rather than with
+// types.Identical, since gqlgen may build the same named type more than once,
+// and distinct *types.Named for the same type aren't identical.
+func _isPointerTo(goType, target types.Type) (bool, error) {
+	if ptr, ok := target.(*types.Pointer); ok {
+		target = ptr.Elem()
+	}
+	targetName := types.TypeString(target, nil)
+	if ptr, ok := goType.(*types.Pointer); ok {
+		goType = ptr.Elem()
+		if types.TypeString(goType, nil) == targetName {
+			return true, nil
+		}
+	} else if types.TypeString(goType, nil) == targetName {
+		return false, nil
+	}
+	return false, errors.WrapWithFields(kind.InvalidInput,
+		errors.Fields{"message": "unexpected Go type", "got": goType.String(),
+			"want": target.String()})
+}
+
+// _resolversReturnPointers returns whether the fields which return obj (in
+// practice, the mutations, whose resolvers will call the mapper) return it as
+// a pointer, as gqlgen does by default.  It returns an error if some return a
+// pointer and others a value, since the mapper can't do both.  If no fields
+// return obj, it assumes the default.
+func _resolversReturnPointers(
+	obj *codegen.Object,
+	objects map[string]*codegen.Object,
+) (bool, error) {
+	var pointer, value []string
+	for _, parent := range objects {
+		for _, field := range parent.Fields {
+			// (Fields returning lists of obj can't use the mapper directly.)
+			if field.TypeReference == nil || field.FieldDefinition.Type.Elem != nil ||
+				field.FieldDefinition.Type.Name() != obj.Definition.Name {
+				continue
+			}
+			fieldName := parent.Definition.Name + "." + field.Name
+			isPointer, err := _isPointerTo(field.TypeReference.GO, obj.Type)
+			if err != nil {
+				return false, errors.WrapWithFields(err, errors.Fields{"field": fieldName})
+			}
+			if isPointer {
+				pointer = append(pointer, fieldName)
+			} else {
+				value = append(value, fieldName)
+			}
+		}
+	}
+
+	if len(pointer) > 0 && len(value) > 0 {
+		sort.Strings(pointer)
+		sort.Strings(value)
+		return false, errors.WrapWithFields(kind.InvalidInput,
+			errors.Fields{"message": "some fields return the model as a pointer and some as a value",
+				"pointer": pointer, "value": value})
+	}
+	return len(value) == 0, nil
+}
+
+// _validateReachableMappings returns an error if the same Go error is
+// @automapped to more than one code.  The generated mapper checks each From in
+// turn, so only the first such mapping could ever match; the rest would be
+// silently unreachable.
+func _validateReachableMappings(mappings []AutomapError) error {
+	mappedTo := map[string]string{}
+	for _, mapping := range mappings {
+		if to, ok := mappedTo[mapping.From]; ok {
+			return errors.WrapWithFields(kind.InvalidInput,
+				errors.Fields{"message": "invalid error mapping: error is already mapped to another code, " +
+					"so this mapping is unreachable",
+					"from": mapping.From, "to": mapping.To, "mappedTo": to})
+		}
+		mappedTo[mapping.From] = mapping.To
+	}
+	return nil
+}
+
+// _packageLoader is the part of gqlgen's code.Packages (which is internal to
+// gqlgen) that _verifySentinels uses.
+type _packageLoader interface {
+	LoadAll(importPaths ...string) []*packages.Package
+}
+
+// _verifySentinels returns an error naming the first sentinel of the given
+// automappers which doesn't exist: whose package can't be loaded, or doesn't
+// declare an exported variable of that name.  See Automap.VerifySentinels.
+func _verifySentinels(loader _packageLoader, mappers []*_automapper) error {
+	namesByPkgPath := map[string]map[string]bool{}
+	for _, mapper := range mappers {
+		for _, mapping := range mapper.Errors {
+			if namesByPkgPath[mapping.PkgPath()] == nil {
+				namesByPkgPath[mapping.PkgPath()] = map[string]bool{}
+			}
+			namesByPkgPath[mapping.PkgPath()][mapping.Name()] = true
+		}
+	}
+	if len(namesByPkgPath) == 0 {
+		return nil
+	}
+
+	pkgPaths := make([]string, 0, len(namesByPkgPath))
+	for pkgPath := range namesByPkgPath {
+		pkgPaths = append(pkgPaths, pkgPath)
+	}
+	sort.Strings(pkgPaths)
+
+	pkgsByPath := map[string]*packages.Package{}
+	for _, pkg := range loader.LoadAll(pkgPaths...) {
+		if pkg != nil {
+			pkgsByPath[pkg.PkgPath] = pkg
+		}
+	}
+
+	for _, pkgPath := range pkgPaths {
+		pkg := pkgsByPath[pkgPath]
+		if pkg == nil || len(pkg.Errors) > 0 || pkg.Types == nil {
+			fields := errors.Fields{
+				"message": "automap sentinel's package could not be loaded",
+				"package": pkgPath,
+			}
+			if pkg != nil && len(pkg.Errors) > 0 {
+				fields["originErr"] = pkg.Errors[0]
+			}
+			return errors.WrapWithFields(kind.InvalidInput, fields)
+		}
+
+		names := make([]string, 0, len(namesByPkgPath[pkgPath]))
+		for name := range namesByPkgPath[pkgPath] {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			obj := pkg.Types.Scope().Lookup(name)
+			if _, ok := obj.(*types.Var); !ok || !obj.Exported() {
+				return errors.WrapWithFields(kind.InvalidInput, errors.Fields{
+					"message":  "automap sentinel's package does not export a variable of that name",
+					"sentinel": pkgPath + "." + name,
+				})
+			}
+		}
+	}
+	return nil
+}
+
+// _objectsByName returns a map of GraphQL type-name -> object, to make
+// those lookups faster.
+func _objectsByName(cfg *codegen.Data) map[string]*codegen.Object {
+	objects := map[string]*codegen.Object{}
+	for _, obj := range cfg.Objects {
+		objects[obj.Definition.Name] = obj
+	}
+	return objects
 }
 
 func _sortAutoMapForSwitchOrder(mappers []*_automapper) {
@@ -470,17 +1247,18 @@ func _sortAutoMapForSwitchOrder(mappers []*_automapper) {
 		sort.SliceStable(automapper.Errors, func(i, j int) bool {
 			iFrom := automapper.Errors[i].From
 			jFrom := automapper.Errors[j].From
-			// For the sake of simplicity in producing a stable sort, we sort
-			// errors alphabetically with 2 groups, pkg and not pkg where pkg
-			// errors are last.
+			// We sort errors into 2 groups, pkg and not pkg where pkg errors
+			// are last, keeping the order of each group; each error keeps
+			// its own To and Log.
 			iIsPkg := strings.HasPrefix(iFrom, "github.com/StevenACoffman/simplerr/errors.")
 			jIsPkg := strings.HasPrefix(jFrom, "github.com/StevenACoffman/simplerr/errors.")
 			switch {
 			case iIsPkg == jIsPkg:
 				// either both are in pkg/lib or both are not. In that case
-				// both i and j are in the same group and we can just sort them
-				// alpha.
-				return i < j
+				// both i and j are in the same group, so we keep them in the
+				// order they're declared (which SliceStable does as long as
+				// neither is less than the other).
+				return false
 			case iIsPkg:
 				// only i is in pkg/lib, so we want it to go last
 				return false
@@ -492,23 +1270,23 @@ func _sortAutoMapForSwitchOrder(mappers []*_automapper) {
 	}
 }
 
-// GenerateCode is gqlgen's entrypoint to the plugin, and as the name
-// suggests, generates the automapping code.
-func (p Automap) GenerateCode(cfg *codegen.Data) error {
+// _getAutomapTemplateData builds the automappers for the given schema. It
+// returns, separately, the errors which should fail generation (those
+// wrapping _incompleteMapping), one per object; errors for which we just skip
+// the object are instead listed in the template data, as are the objects we
+// skip because they have no error field.
+func (p Automap) _getAutomapTemplateData(cfg *codegen.Data) (*_automapTemplateData, []error) {
 	var templateData _automapTemplateData
+	var fatalErrors []error
 
-	// Build a map of name -> object, to make those lookups faster.
-	objects := map[string]*codegen.Object{}
-	for _, obj := range cfg.Objects {
-		objects[obj.Definition.Name] = obj
-	}
+	objects := _objectsByName(cfg)
 
 	// Now actually go through the objects, and build the automappers.
 	for _, obj := range cfg.Objects {
-		automapper, err := _getAutomapData(obj, objects)
+		automapper, err := p._getAutomapData(obj, objects)
 		switch {
 		case errors.Is(err, _incompleteMapping):
-			return err
+			fatalErrors = append(fatalErrors, err)
 		case err != nil:
 			templateData.Errors = append(templateData.Errors,
 				strings.ReplaceAll( // strip newlines
@@ -516,6 +1294,10 @@ func (p Automap) GenerateCode(cfg *codegen.Data) error {
 					"\n", " "))
 		case automapper != nil:
 			templateData.Mappers = append(templateData.Mappers, automapper)
+		case !obj.Root:
+			// No error field; we list these in case someone expected a
+			// mapper.  (Query and Mutation never have one.)
+			templateData.Skipped = append(templateData.Skipped, obj.Definition.Name)
 		}
 	}
 
@@ -528,6 +1310,36 @@ func (p Automap) GenerateCode(cfg *codegen.Data) error {
 	// switch case would produce a case for NotFoundKind before
 	// UserNotFoundError which would make the later unreachable.
 	_sortAutoMapForSwitchOrder(templateData.Mappers)
+	return &templateData, fatalErrors
+}
+
+// GenerateCode is gqlgen's entrypoint to the plugin, and as the name
+// suggests, generates the automapping code.
+func (p Automap) GenerateCode(cfg *codegen.Data) error {
+	templateData, fatalErrors := p._getAutomapTemplateData(cfg)
+	if len(fatalErrors) > 0 {
+		return fatalErrors[0]
+	}
+	if p.VerifySentinels {
+		err := _verifySentinels(cfg.Config.Packages, templateData.Mappers)
+		if err != nil {
+			return err
+		}
+	}
+
+	if p.EmitRegistry {
+		templateData.Registry = _automapRegistry(templateData.Mappers)
+	}
+	templateData.MatchJoinedErrors = p.MatchJoinedErrors
+	templateData.IncludeFieldPath = p.IncludeFieldPath
+	templateData.RecoverPanics = p.RecoverPanics
+	if p.Logger != "" {
+		var err error
+		templateData.LoggerPkgPath, templateData.LoggerName, err = _parseLogger(p.Logger)
+		if err != nil {
+			return err
+		}
+	}
 
 	_, thisFile, _, ok := runtime.Caller(0)
 	if !ok {
@@ -551,8 +1363,95 @@ func (p Automap) GenerateCode(cfg *codegen.Data) error {
 		GeneratedHeader: true, // include "DO NOT EDIT" line
 
 		Template: string(templateBytes),
-		Data:     &templateData,
+		Data:     templateData,
 		Packages: cfg.Config.Packages,
 	})
+	if err != nil || !p.GenerateTests {
+		return errors.WithStack(err)
+	}
+
+	testTemplateBytes, err := os.ReadFile(
+		filepath.Join(filepath.Dir(thisFile), "automap_test.gotpl"))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	err = templates.Render(templates.Options{
+		PackageName:     "automap",
+		Filename:        filepath.Join(p.OutputDir, "automap_test.go"),
+		GeneratedHeader: true,
+		Template:        string(testTemplateBytes),
+		Data:            templateData,
+		Packages:        cfg.Config.Packages,
+	})
 	return errors.WithStack(err)
 }
+
+// AutomapCoverage describes how many of a service's mutation payloads get
+// automappers; see GetAutomapCoverage.
+type AutomapCoverage struct {
+	// Mapped is the (sorted) GraphQL names of the mutation payload types for
+	// which we generate automappers.
+	Mapped []string `json:"mapped"`
+	// Skipped is the (sorted) GraphQL names of the mutation payload types for
+	// which we don't, either because they have no error field or because we
+	// were unable to generate a mapper for them.
+	Skipped []string `json:"skipped"`
+}
+
+// Percent returns the percentage (0-100) of mutation payloads that have
+// automappers.  A service with no mutations is considered fully covered.
+func (c AutomapCoverage) Percent() float64 {
+	total := len(c.Mapped) + len(c.Skipped)
+	if total == 0 {
+		return 100
+	}
+	return 100 * float64(len(c.Mapped)) / float64(total)
+}
+
+// GetAutomapCoverage returns which of the payload types of the mutations in
+// the given schema Automap generates mappers for.  Since each service runs
+// gqlgen (and thus Automap) separately, this is a per-service number; it's
+// meant for CI jobs that track the migration to ADR-303 style errors.
+//
+// Mutations that don't return an object type (say, a Boolean) are counted as
+// skipped, since they can't use ADR-303 style errors either.
+//
+// This assumes the default field names; see Automap.Coverage.
+func GetAutomapCoverage(cfg *codegen.Data) AutomapCoverage {
+	return Automap{}.Coverage(cfg)
+}
+
+// Coverage is like GetAutomapCoverage, but uses the field names configured
+// on the plugin.
+func (p Automap) Coverage(cfg *codegen.Data) AutomapCoverage {
+	var coverage AutomapCoverage
+	if cfg.MutationRoot == nil {
+		return coverage
+	}
+
+	objects := _objectsByName(cfg)
+	seen := map[string]bool{}
+	for _, field := range cfg.MutationRoot.Fields {
+		payloadName := field.FieldDefinition.Type.Name()
+		if seen[payloadName] {
+			continue // several mutations may share a payload type
+		}
+		seen[payloadName] = true
+
+		obj := objects[payloadName]
+		if obj == nil {
+			coverage.Skipped = append(coverage.Skipped, payloadName)
+			continue
+		}
+		automapper, err := p._getAutomapData(obj, objects)
+		if err != nil || automapper == nil {
+			coverage.Skipped = append(coverage.Skipped, payloadName)
+		} else {
+			coverage.Mapped = append(coverage.Mapped, payloadName)
+		}
+	}
+
+	sort.Strings(coverage.Mapped)
+	sort.Strings(coverage.Skipped)
+	return coverage
+}
@@ -0,0 +1,1327 @@
+package gqlgen_plugins
+
+import (
+	"fmt"
+	goast "go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/99designs/gqlgen/codegen"
+	"github.com/99designs/gqlgen/codegen/config"
+	"github.com/99designs/gqlgen/codegen/templates"
+	"github.com/StevenACoffman/simplerr/errors"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+	"golang.org/x/tools/go/packages"
+
+	"github.com/Khan/webapp/dev/khantest"
+)
+
+type automapSuite struct{ khantest.Suite }
+
+var _testGraphQLPkg = types.NewPackage("github.com/Khan/webapp/generated/graphql", "graphql")
+
+// _testNamedType returns a placeholder for a type in the generated graphql
+// package; see _namedType for why that's enough.
+func _testNamedType(name string) types.Type {
+	return types.NewNamed(types.NewTypeName(0, _testGraphQLPkg, name, nil), nil, nil)
+}
+
+// _testField returns a codegen.Field of the given GraphQL name, whose type is
+// the named GraphQL type typeName.
+func _testField(name string, typeName string) *codegen.Field {
+	return &codegen.Field{
+		FieldDefinition: &ast.FieldDefinition{
+			Name: name,
+			Type: ast.NamedType(typeName, nil),
+		},
+		GoFieldName: templates.ToGo(name),
+	}
+}
+
+// _testObject returns a codegen.Object with the given name and fields.
+func _testObject(name string, fields ...*codegen.Field) *codegen.Object {
+	return &codegen.Object{
+		Definition: &ast.Definition{Kind: ast.Object, Name: name},
+		Type:       _testNamedType(name),
+		Fields:     fields,
+	}
+}
+
+// _testPayload returns the objects for a mutation payload type named name,
+// with an ADR-303 style error whose code enum has the given values.
+func _testPayload(name string, codes ...string) []*codegen.Object {
+	enum := &ast.Definition{Kind: ast.Enum, Name: name + "ErrorCode"}
+	for _, code := range codes {
+		enum.EnumValues = append(enum.EnumValues, &ast.EnumValueDefinition{Name: code})
+	}
+	codeField := _testField("code", enum.Name)
+	codeField.TypeReference = &config.TypeReference{
+		Definition: enum,
+		GO:         _testNamedType(enum.Name),
+		Target:     _testNamedType(enum.Name),
+	}
+
+	// As gqlgen generates for a nullable object field.
+	errorField := _testField("error", name+"Error")
+	errorField.TypeReference = &config.TypeReference{
+		GO:     types.NewPointer(_testNamedType(name + "Error")),
+		Target: _testNamedType(name + "Error"),
+	}
+
+	return []*codegen.Object{
+		_testObject(name, errorField),
+		_testObject(name+"Error", codeField),
+	}
+}
+
+// _testMutationData returns codegen data for a schema whose mutation root
+// has the given fields, and which has the given (non-root) objects.
+func _testMutationData(
+	mutationFields []*codegen.Field,
+	objects ...[]*codegen.Object,
+) *codegen.Data {
+	data := &codegen.Data{MutationRoot: _testObject("Mutation", mutationFields...)}
+	data.MutationRoot.Root = true
+	data.Objects = append(data.Objects, data.MutationRoot)
+	for _, objs := range objects {
+		data.Objects = append(data.Objects, objs...)
+	}
+	return data
+}
+
+func (suite *automapSuite) TestCoverageMixedSchema() {
+	data := _testMutationData(
+		[]*codegen.Field{
+			_testField("mapped", "MappedPayload"),
+			_testField("alsoMapped", "MappedPayload"),
+			_testField("noError", "NoErrorPayload"),
+			_testField("incomplete", "IncompletePayload"),
+			_testField("boolean", "Boolean"),
+		},
+		_testPayload("MappedPayload", "NOT_FOUND", "INTERNAL"),
+		[]*codegen.Object{_testObject("NoErrorPayload", _testField("id", "ID"))},
+		// SOMETHING_ELSE is neither @automapped nor a default, so we can't
+		// generate a mapper for this one.
+		_testPayload("IncompletePayload", "SOMETHING_ELSE", "INTERNAL"),
+	)
+
+	coverage := GetAutomapCoverage(data)
+
+	suite.Require().Equal(AutomapCoverage{
+		Mapped:  []string{"MappedPayload"},
+		Skipped: []string{"Boolean", "IncompletePayload", "NoErrorPayload"},
+	}, coverage)
+	suite.Require().InDelta(25.0, coverage.Percent(), 0.001)
+}
+
+func (suite *automapSuite) TestCoverageNoMutations() {
+	coverage := GetAutomapCoverage(&codegen.Data{})
+
+	suite.Require().Equal(AutomapCoverage{}, coverage)
+	suite.Require().InDelta(100.0, coverage.Percent(), 0.001)
+}
+
+func (suite *automapSuite) TestRegistry() {
+	registry := _automapRegistry([]*_automapper{
+		{MapperName: "UpdateUserPayloadErr", GraphQLTypeName: "UpdateUserPayload"},
+		{MapperName: "AddCoursePayloadErr", GraphQLTypeName: "AddCoursePayload"},
+	})
+
+	suite.Require().Equal([]_automapRegistryEntry{
+		{GraphQLTypeName: "AddCoursePayload", MapperName: "AddCoursePayloadErr"},
+		{GraphQLTypeName: "UpdateUserPayload", MapperName: "UpdateUserPayloadErr"},
+	}, registry)
+}
+
+// _automapDirective returns an @automap directive mapping the given Go error.
+func _automapDirective(goError string) *ast.Directive {
+	return &ast.Directive{
+		Name: "automap",
+		Arguments: ast.ArgumentList{{
+			Name:  "go",
+			Value: &ast.Value{Kind: ast.StringValue, Raw: goError},
+		}},
+	}
+}
+
+func (suite *automapSuite) TestUnreachableMapping() {
+	objs := _testPayload("AddCoursePayload", "NOT_FOUND", "COURSE_NOT_FOUND", "INTERNAL")
+	codes := objs[1].Fields[0].TypeReference.Definition.EnumValues
+	codes[0].Directives = ast.DirectiveList{
+		_automapDirective("github.com/Khan/webapp/pkg/courses.ErrNotFound")}
+	codes[1].Directives = ast.DirectiveList{
+		_automapDirective("github.com/Khan/webapp/pkg/courses.ErrNotFound")}
+
+	_, err := Automap{}._getAutomapData(objs[0], _objectsByName(_testMutationData(nil, objs)))
+
+	suite.Require().Error(err)
+	suite.Require().Contains(err.Error(), "this mapping is unreachable")
+	suite.Require().Contains(err.Error(), "to:COURSE_NOT_FOUND")
+	suite.Require().Contains(err.Error(), "mappedTo:NOT_FOUND")
+}
+
+func (suite *automapSuite) TestReachableMappings() {
+	objs := _testPayload("AddCoursePayload", "NOT_FOUND", "COURSE_NOT_FOUND", "INTERNAL")
+	codes := objs[1].Fields[0].TypeReference.Definition.EnumValues
+	codes[1].Directives = ast.DirectiveList{
+		_automapDirective("github.com/Khan/webapp/pkg/courses.ErrNotFound")}
+
+	data, err := Automap{}._getAutomapData(objs[0], _objectsByName(_testMutationData(nil, objs)))
+
+	suite.Require().NoError(err)
+	suite.Require().Equal("COURSE_NOT_FOUND", data.Errors[0].To)
+}
+
+func (suite *automapSuite) TestConfiguredMappingReplacesDefault() {
+	objs := _testPayload("AddCoursePayload", "NOT_FOUND", "COURSE_NOT_FOUND", "INTERNAL")
+	codes := objs[1].Fields[0].TypeReference.Definition.EnumValues
+	codes[1].Directives = ast.DirectiveList{
+		_automapDirective("github.com/StevenACoffman/simplerr/errors.NotFoundKind")}
+
+	data, err := Automap{}._getAutomapData(objs[0], _objectsByName(_testMutationData(nil, objs)))
+
+	// The default NotFoundKind -> NOT_FOUND mapping would be unreachable, so
+	// we omit it.
+	suite.Require().NoError(err)
+	suite.Require().Equal([]AutomapError{{
+		From: "github.com/StevenACoffman/simplerr/errors.NotFoundKind",
+		To:   "COURSE_NOT_FOUND",
+	}}, data.Errors)
+}
+
+func (suite *automapSuite) TestDefaultCodeNames() {
+	objs := _testPayload("AddCoursePayload", "NOT_FOUND", "SERVER_ERROR")
+	objects := _objectsByName(_testMutationData(nil, objs))
+
+	// By default, we don't know SERVER_ERROR is the catch-all.
+	_, err := Automap{}._getAutomapData(objs[0], objects)
+	suite.Require().Error(err)
+	suite.Require().Contains(err.Error(), "Not all values automapped")
+
+	data, err := Automap{
+		DefaultCodeNames: []string{"INTERNAL", "SERVER_ERROR"},
+	}._getAutomapData(objs[0], objects)
+	suite.Require().NoError(err)
+	suite.Require().Equal("SERVER_ERROR", data.DefaultCode)
+}
+
+func (suite *automapSuite) TestNoDefaultCodeNames() {
+	objs := _testPayload("AddCoursePayload", "NOT_FOUND")
+	objects := _objectsByName(_testMutationData(nil, objs))
+
+	// With no default code, we fall back to the GraphQL errors array.
+	data, err := Automap{}._getAutomapData(objs[0], objects)
+	suite.Require().NoError(err)
+	suite.Require().Equal("", data.DefaultCode)
+
+	// With no default-code names, INTERNAL is an ordinary code, which must
+	// be @automapped.
+	objs = _testPayload("AddCoursePayload", "NOT_FOUND", "INTERNAL")
+	objects = _objectsByName(_testMutationData(nil, objs))
+	_, err = Automap{DefaultCodeNames: []string{}}._getAutomapData(objs[0], objects)
+	suite.Require().Error(err)
+	suite.Require().Contains(err.Error(), "Not all values automapped")
+}
+
+func (suite *automapSuite) TestEnumDefaultCode() {
+	objs := _testPayload("AddCoursePayload", "NOT_FOUND", "INTERNAL", "SERVER_ERROR")
+	enum := objs[1].Fields[0].TypeReference.Definition
+	enum.Directives = ast.DirectiveList{{
+		Name: "automap",
+		Arguments: ast.ArgumentList{{
+			Name:  "default",
+			Value: &ast.Value{Kind: ast.StringValue, Raw: "SERVER_ERROR"},
+		}},
+	}}
+	enum.EnumValues[1].Directives = ast.DirectiveList{
+		_automapDirective("github.com/StevenACoffman/simplerr/errors.InternalKind")}
+	objects := _objectsByName(_testMutationData(nil, objs))
+
+	// The explicit default wins over INTERNAL, which now must be @automapped
+	// like any other code.
+	data, err := Automap{}._getAutomapData(objs[0], objects)
+	suite.Require().NoError(err)
+	suite.Require().Equal("SERVER_ERROR", data.DefaultCode)
+
+	enum.Directives[0].Arguments[0].Value.Raw = "UNEXPECTED_ERROR"
+	_, err = Automap{}._getAutomapData(objs[0], objects)
+	suite.Require().Error(err)
+	suite.Require().Contains(err.Error(), "invalid default code: not a value of the enum")
+	suite.Require().Contains(err.Error(), "UNEXPECTED_ERROR")
+}
+
+func (suite *automapSuite) TestMutateConfigIncompleteMapping() {
+	schema, err := gqlparser.LoadSchema(&ast.Source{Input: `
+		directive @automap(go: [String!], log: [String!], severity: String) on ENUM_VALUE
+		type Query { course: AddCoursePayload }
+		type AddCoursePayload { error: AddCoursePayloadError }
+		type AddCoursePayloadError { code: AddCoursePayloadErrorCode! }
+		enum AddCoursePayloadErrorCode { NOT_FOUND SERVER_ERROR }
+	`})
+	suite.Require().NoError(err)
+	cfg := &config.Config{Schema: schema}
+
+	// By default, we don't know SERVER_ERROR is the catch-all, so we fail
+	// before gqlgen gets as far as GenerateCode.
+	p := &Automap{}
+	err = p.MutateConfig(cfg)
+	suite.Require().Error(err)
+	suite.Require().True(errors.Is(err, _incompleteMapping))
+	suite.Require().Contains(err.Error(), "AddCoursePayload")
+	suite.Require().Contains(err.Error(), "SERVER_ERROR")
+
+	// Once we do, GenerateCode reuses the mappings we found.
+	p = &Automap{DefaultCodeNames: []string{"SERVER_ERROR"}}
+	suite.Require().NoError(p.MutateConfig(cfg))
+	p.DefaultCodeNames = nil
+	objs := _testPayload("AddCoursePayload", "NOT_FOUND", "SERVER_ERROR")
+	data, err := p._getAutomapData(objs[0], _objectsByName(_testMutationData(nil, objs)))
+	suite.Require().NoError(err)
+	suite.Require().Equal("SERVER_ERROR", data.DefaultCode)
+}
+
+// _listValue returns a GraphQL list value of the given strings.
+func _listValue(values ...string) *ast.Value {
+	list := &ast.Value{Kind: ast.ListValue}
+	for _, value := range values {
+		list.Children = append(list.Children, &ast.ChildValue{
+			Value: &ast.Value{Kind: ast.StringValue, Raw: value},
+		})
+	}
+	return list
+}
+
+func (suite *automapSuite) TestPerSentinelLogLevels() {
+	objs := _testPayload("AddCoursePayload", "NOT_FOUND", "INTERNAL")
+	codes := objs[1].Fields[0].TypeReference.Definition.EnumValues
+	codes[0].Directives = ast.DirectiveList{{
+		Name: "automap",
+		Arguments: ast.ArgumentList{
+			{Name: "go", Value: _listValue(
+				"github.com/StevenACoffman/simplerr/errors.NotFoundKind",
+				"github.com/Khan/webapp/pkg/users.UserMissingError")},
+			{Name: "log", Value: _listValue("warn", "error")},
+		},
+	}}
+
+	data, err := Automap{}._getAutomapData(objs[0], _objectsByName(_testMutationData(nil, objs)))
+	suite.Require().NoError(err)
+	suite.Require().Equal([]AutomapError{
+		{From: "github.com/StevenACoffman/simplerr/errors.NotFoundKind", To: "NOT_FOUND", Log: "warn"},
+		{From: "github.com/Khan/webapp/pkg/users.UserMissingError", To: "NOT_FOUND", Log: "error"},
+	}, data.Errors[:2])
+
+	// The service-specific error goes first, but keeps its own log level.
+	_sortAutoMapForSwitchOrder([]*_automapper{data})
+	suite.Require().Equal(AutomapError{
+		From: "github.com/Khan/webapp/pkg/users.UserMissingError", To: "NOT_FOUND", Log: "error",
+	}, data.Errors[0])
+	suite.Require().Equal(AutomapError{
+		From: "github.com/StevenACoffman/simplerr/errors.NotFoundKind", To: "NOT_FOUND", Log: "warn",
+	}, data.Errors[1])
+}
+
+func (suite *automapSuite) TestSingleLogLevelForAllSentinels() {
+	objs := _testPayload("AddCoursePayload", "NOT_FOUND", "INTERNAL")
+	codes := objs[1].Fields[0].TypeReference.Definition.EnumValues
+	codes[0].Directives = ast.DirectiveList{{
+		Name: "automap",
+		Arguments: ast.ArgumentList{
+			{Name: "go", Value: _listValue(
+				"github.com/Khan/webapp/pkg/courses.ErrNotFound",
+				"github.com/Khan/webapp/pkg/users.UserMissingError")},
+			{Name: "log", Value: &ast.Value{Kind: ast.StringValue, Raw: "warn"}},
+		},
+	}}
+
+	data, err := Automap{}._getAutomapData(objs[0], _objectsByName(_testMutationData(nil, objs)))
+	suite.Require().NoError(err)
+	suite.Require().Equal("warn", data.Errors[0].Log)
+	suite.Require().Equal("warn", data.Errors[1].Log)
+}
+
+func (suite *automapSuite) TestMismatchedLogLevels() {
+	objs := _testPayload("AddCoursePayload", "NOT_FOUND", "INTERNAL")
+	codes := objs[1].Fields[0].TypeReference.Definition.EnumValues
+	codes[0].Directives = ast.DirectiveList{{
+		Name: "automap",
+		Arguments: ast.ArgumentList{
+			{Name: "go", Value: _listValue(
+				"github.com/Khan/webapp/pkg/courses.ErrNotFound",
+				"github.com/Khan/webapp/pkg/users.UserMissingError",
+				"github.com/Khan/webapp/pkg/users.ErrNoSuchUser")},
+			{Name: "log", Value: _listValue("warn", "error")},
+		},
+	}}
+
+	_, err := Automap{}._getAutomapData(objs[0], _objectsByName(_testMutationData(nil, objs)))
+	suite.Require().Error(err)
+	suite.Require().Contains(err.Error(), "log must be a single level or one level per go sentinel")
+}
+
+func (suite *automapSuite) TestEffectiveSeverity() {
+	tests := []struct {
+		from     string
+		severity string
+		expected string
+	}{
+		{"github.com/StevenACoffman/simplerr/errors.NotFoundKind", "", AutomapSeverityClient},
+		{"github.com/StevenACoffman/simplerr/errors.InvalidInputKind", "", AutomapSeverityClient},
+		{"github.com/StevenACoffman/gqlgen-plugins/errors/kind.Unauthorized", "", AutomapSeverityClient},
+		{"github.com/StevenACoffman/simplerr/errors.InternalKind", "", AutomapSeverityServer},
+		{"github.com/StevenACoffman/gqlgen-plugins/errors/kind.Service", "", AutomapSeverityServer},
+		{"github.com/StevenACoffman/simplerr/errors.NotImplementedKind", "", AutomapSeverityServer},
+		// Not a kind, so we assume it's an expected error.
+		{"github.com/Khan/webapp/pkg/courses.ErrNotFound", "", AutomapSeverityClient},
+		// Explicitly configured.
+		{"github.com/Khan/webapp/pkg/courses.ErrDatastoreDown", AutomapSeverityServer, AutomapSeverityServer},
+		{"github.com/StevenACoffman/simplerr/errors.InternalKind", AutomapSeverityClient, AutomapSeverityClient},
+	}
+	for _, test := range tests {
+		e := AutomapError{From: test.from, Severity: test.severity}
+		suite.Require().Equal(test.expected, e.EffectiveSeverity(), test.from)
+	}
+}
+
+func (suite *automapSuite) TestSeverityFromDirective() {
+	objs := _testPayload("AddCoursePayload", "COURSE_SERVICE_DOWN", "INTERNAL")
+	codes := objs[1].Fields[0].TypeReference.Definition.EnumValues
+	directive := _automapDirective("github.com/Khan/webapp/pkg/courses.ErrServiceDown")
+	directive.Arguments = append(directive.Arguments, &ast.Argument{
+		Name:  "severity",
+		Value: &ast.Value{Kind: ast.StringValue, Raw: "server"},
+	})
+	codes[0].Directives = ast.DirectiveList{directive}
+
+	data, err := Automap{}._getAutomapData(objs[0], _objectsByName(_testMutationData(nil, objs)))
+
+	suite.Require().NoError(err)
+	suite.Require().Equal(AutomapSeverityServer, data.Errors[0].EffectiveSeverity())
+}
+
+func (suite *automapSuite) TestInvalidSeverity() {
+	e := AutomapError{
+		From:     "github.com/StevenACoffman/simplerr/errors.NotFoundKind",
+		To:       "NOT_FOUND",
+		Severity: "user",
+	}
+
+	err := e.Validate(ast.EnumValueList{{Name: "NOT_FOUND"}})
+
+	suite.Require().Error(err)
+	suite.Require().Contains(err.Error(), "severity, if set, must be 'client' or 'server'")
+}
+
+func (suite *automapSuite) TestSeverityInLogs() {
+	rendered := suite._renderAutomapTemplate(&_automapTemplateData{
+		Mappers: []*_automapper{{
+			MapperName:       "MyMutationErr",
+			GraphQLTypeName:  "MyMutation",
+			GraphQLModel:     _testNamedType("MyMutation"),
+			GraphQLError:     _testNamedType("MyMutationError"),
+			GraphQLErrorCode: _testNamedType("MyMutationErrorCode"),
+			ModelIsPointer:   true,
+			ErrorIsPointer:   true,
+			ErrorField:       "Error",
+			ErrorCodeField:   "Code",
+			Errors:           _defaultErrorMappings[:1],
+			DefaultCode:      "INTERNAL",
+		}},
+	})
+
+	suite.Require().Contains(rendered,
+		`ctx.Log().Warn(errors.Wrap(err, "code", graphql.MyMutationErrorCodeNotFound, "severity", "client"))`)
+	suite.Require().Contains(rendered,
+		`ctx.Log().Error(errors.Wrap(err, "code", graphql.MyMutationErrorCodeInternal, "severity", "server"))`)
+}
+
+func (suite *automapSuite) TestSkippedObjects() {
+	data := _testMutationData(nil,
+		_testPayload("AddCoursePayload", "NOT_FOUND", "INTERNAL"),
+		[]*codegen.Object{_testObject("Course", _testField("id", "ID"))})
+
+	templateData, fatalErrors := Automap{}._getAutomapTemplateData(data)
+	suite.Require().Empty(fatalErrors)
+	suite.Require().Empty(templateData.Errors)
+	suite.Require().Len(templateData.Mappers, 1)
+	// The error type has no error field either, so it's listed too; the
+	// Mutation root isn't.
+	suite.Require().Equal([]string{"AddCoursePayloadError", "Course"}, templateData.Skipped)
+
+	rendered := suite._renderAutomapTemplate(templateData)
+	suite.Require().Contains(rendered, "have no error field:")
+	suite.Require().Contains(rendered, "// - Course")
+}
+
+// _testTemplateFuncs are simple equivalents of the gqlgen template functions
+// our templates use; see _renderAutomapTemplate.
+var _testTemplateFuncs = template.FuncMap{
+	"reserveImport": func(string) string { return "" },
+	"lookupImport":  path.Base,
+	"ref": func(t types.Type) string {
+		return types.TypeString(t, func(pkg *types.Package) string { return pkg.Name() })
+	},
+	"go":    templates.ToGo,
+	"quote": strconv.Quote,
+}
+
+// _renderAutomapTemplate executes automap.gotpl on the given data.  Rather
+// than going through gqlgen's templates.Render, which needs the full
+// package-loading machinery, we stub out the template functions with
+// simple equivalents.
+func (suite *automapSuite) _renderAutomapTemplate(data *_automapTemplateData) string {
+	return suite._renderTemplate("automap.gotpl", data)
+}
+
+// _renderTemplate executes the given template file on the given data, like
+// _renderAutomapTemplate.
+func (suite *automapSuite) _renderTemplate(filename string, data *_automapTemplateData) string {
+	src, err := os.ReadFile(filename)
+	suite.Require().NoError(err)
+
+	tmpl, err := template.New(filename).Funcs(_testTemplateFuncs).Parse(string(src))
+	suite.Require().NoError(err)
+
+	var out strings.Builder
+	suite.Require().NoError(tmpl.Execute(&out, data))
+	return out.String()
+}
+
+func (suite *automapSuite) TestGeneratedTests() {
+	mapper := &_automapper{
+		MapperName:       "MyMutationErr",
+		GraphQLTypeName:  "MyMutation",
+		GraphQLModel:     _testNamedType("MyMutation"),
+		GraphQLError:     _testNamedType("MyMutationError"),
+		GraphQLErrorCode: _testNamedType("MyMutationErrorCode"),
+		ModelIsPointer:   true,
+		ErrorIsPointer:   true,
+		ErrorField:       "Error",
+		ErrorCodeField:   "Code",
+		Errors: []AutomapError{
+			{From: "github.com/Khan/webapp/pkg/courses.ErrNotFound", To: "COURSE_NOT_FOUND"},
+			_defaultErrorMappings[0],
+		},
+		DefaultCode: "INTERNAL",
+	}
+
+	rendered := suite._renderTemplate("automap_test.gotpl", &_automapTemplateData{
+		Mappers: []*_automapper{mapper},
+	})
+
+	suite.Require().Contains(rendered, "func (suite *automapSuite) TestMyMutationErr() {")
+	suite.Require().Contains(rendered, `"github.com/Khan/webapp/pkg/courses.ErrNotFound",
+                    courses.ErrNotFound,
+                    graphql.MyMutationErrorCodeCourseNotFound,`)
+	suite.Require().Contains(rendered, `"github.com/StevenACoffman/simplerr/errors.NotFoundKind",
+                    errors.NotFoundKind,
+                    graphql.MyMutationErrorCodeNotFound,`)
+	suite.Require().Contains(rendered, `errors.Internal("unmapped error"),
+                    graphql.MyMutationErrorCodeInternal,`)
+	suite.Require().Contains(rendered,
+		"suite.Require().Equal(testCase.code, result.Error.Code)")
+}
+
+func (suite *automapSuite) TestIncludeFieldPath() {
+	mapper := &_automapper{
+		MapperName:        "MyMutationErr",
+		GraphQLTypeName:   "MyMutation",
+		GraphQLModel:      _testNamedType("MyMutation"),
+		GraphQLError:      _testNamedType("MyMutationError"),
+		GraphQLErrorCode:  _testNamedType("MyMutationErrorCode"),
+		ModelIsPointer:    true,
+		ErrorIsPointer:    true,
+		ErrorField:        "Error",
+		ErrorCodeField:    "Code",
+		DebugMessageField: "DebugMessage",
+		Errors:            _defaultErrorMappings[:1],
+		DefaultCode:       "INTERNAL",
+	}
+
+	withPath := suite._renderAutomapTemplate(&_automapTemplateData{
+		Mappers:          []*_automapper{mapper},
+		IncludeFieldPath: true,
+	})
+	suite.Require().Contains(withPath, "graphql.GetFieldContext(ctx)")
+	suite.Require().Contains(withPath, `msg = path + ": " + msg`)
+	suite.Require().Contains(withPath, `"path", path`)
+
+	withoutPath := suite._renderAutomapTemplate(&_automapTemplateData{
+		Mappers: []*_automapper{mapper},
+	})
+	suite.Require().NotContains(withoutPath, "GetFieldContext")
+	suite.Require().NotContains(withoutPath, `"path", path`)
+}
+
+func (suite *automapSuite) TestMatchJoinedErrors() {
+	mapper := &_automapper{
+		MapperName:       "MyMutationErr",
+		GraphQLTypeName:  "MyMutation",
+		GraphQLModel:     _testNamedType("MyMutation"),
+		GraphQLError:     _testNamedType("MyMutationError"),
+		GraphQLErrorCode: _testNamedType("MyMutationErrorCode"),
+		ModelIsPointer:   true,
+		ErrorIsPointer:   true,
+		ErrorField:       "Error",
+		ErrorCodeField:   "Code",
+		Errors: []AutomapError{
+			{From: "github.com/Khan/webapp/pkg/courses.ErrNotFound", To: "COURSE_NOT_FOUND"},
+			_defaultErrorMappings[0],
+			_defaultErrorMappings[1],
+		},
+		DefaultCode: "INTERNAL",
+	}
+
+	// Like _renderAutomapTemplate, but recording the imports reserved.
+	src, err := os.ReadFile("automap.gotpl")
+	suite.Require().NoError(err)
+	var imports []string
+	funcs := template.FuncMap{}
+	for name, f := range _testTemplateFuncs {
+		funcs[name] = f
+	}
+	funcs["reserveImport"] = func(pkgPath string) string {
+		imports = append(imports, pkgPath)
+		return ""
+	}
+	tmpl, err := template.New("automap.gotpl").Funcs(funcs).Parse(string(src))
+	suite.Require().NoError(err)
+
+	var out strings.Builder
+	suite.Require().NoError(tmpl.Execute(&out, &_automapTemplateData{
+		Mappers:           []*_automapper{mapper},
+		MatchJoinedErrors: true,
+	}))
+	rendered := out.String()
+
+	suite.Require().Contains(imports, "github.com/StevenACoffman/gqlgen-plugins/errors/kind")
+	suite.Require().NotContains(rendered, "errors.Is(")
+	// The cases must stay in order of precedence, so that the
+	// highest-precedence error among those joined wins.
+	cases := []string{
+		"case kind.IsInTree(err, courses.ErrNotFound):",
+		"case kind.IsInTree(err, errors.NotFoundKind):",
+		"case kind.IsInTree(err, errors.InvalidInputKind):",
+	}
+	prev := -1
+	for _, c := range cases {
+		index := strings.Index(rendered, c)
+		suite.Require().True(index > prev, c)
+		prev = index
+	}
+
+	imports = nil
+	out.Reset()
+	suite.Require().NoError(tmpl.Execute(&out, &_automapTemplateData{
+		Mappers: []*_automapper{mapper},
+	}))
+	suite.Require().NotContains(imports, "github.com/StevenACoffman/gqlgen-plugins/errors/kind")
+	suite.Require().NotContains(out.String(), "kind.IsInTree")
+	suite.Require().Contains(out.String(), "case errors.Is(err, courses.ErrNotFound):")
+}
+
+func (suite *automapSuite) TestRecoverPanics() {
+	mapper := &_automapper{
+		MapperName:       "MyMutationErr",
+		GraphQLTypeName:  "MyMutation",
+		GraphQLModel:     _testNamedType("MyMutation"),
+		GraphQLError:     _testNamedType("MyMutationError"),
+		GraphQLErrorCode: _testNamedType("MyMutationErrorCode"),
+		ModelIsPointer:   true,
+		ErrorIsPointer:   true,
+		ErrorField:       "Error",
+		ErrorCodeField:   "Code",
+		Errors:           _defaultErrorMappings[:1],
+		DefaultCode:      "INTERNAL",
+	}
+
+	withRecover := suite._renderAutomapTemplate(&_automapTemplateData{
+		Mappers:       []*_automapper{mapper},
+		RecoverPanics: true,
+	})
+	suite.Require().Contains(withRecover,
+		"(result *graphql.MyMutation, resultErr error)")
+	suite.Require().Contains(withRecover, "recovered := recover()")
+	suite.Require().Contains(withRecover, "Code: graphql.MyMutationErrorCodeInternal,")
+
+	withoutRecover := suite._renderAutomapTemplate(&_automapTemplateData{
+		Mappers: []*_automapper{mapper},
+	})
+	suite.Require().NotContains(withoutRecover, "recover()")
+}
+
+func (suite *automapSuite) TestLogger() {
+	pkgPath, name, err := _parseLogger("github.com/myorg/mylib/logging.LogError")
+	suite.Require().NoError(err)
+	suite.Require().Equal("github.com/myorg/mylib/logging", pkgPath)
+	suite.Require().Equal("LogError", name)
+
+	rendered := suite._renderAutomapTemplate(&_automapTemplateData{
+		Mappers: []*_automapper{{
+			MapperName:       "MyMutationErr",
+			GraphQLTypeName:  "MyMutation",
+			GraphQLModel:     _testNamedType("MyMutation"),
+			GraphQLError:     _testNamedType("MyMutationError"),
+			GraphQLErrorCode: _testNamedType("MyMutationErrorCode"),
+			ModelIsPointer:   true,
+			ErrorIsPointer:   true,
+			ErrorField:       "Error",
+			ErrorCodeField:   "Code",
+			Errors:           _defaultErrorMappings[:1],
+			DefaultCode:      "INTERNAL",
+		}},
+		RecoverPanics: true,
+		LoggerPkgPath: pkgPath,
+		LoggerName:    name,
+	})
+
+	suite.Require().Contains(rendered, "ctx context.Context,")
+	suite.Require().Contains(rendered, `logging.LogError(ctx, "error", panicErr)`)
+	suite.Require().Contains(rendered,
+		`logging.LogError(ctx, "warn", errors.Wrap(err, "code", graphql.MyMutationErrorCodeNotFound, "severity", "client"))`)
+	suite.Require().Contains(rendered,
+		`logging.LogError(ctx, "error", errors.Wrap(err, "code", graphql.MyMutationErrorCodeInternal, "severity", "server"))`)
+	suite.Require().NotContains(rendered, "Log()")
+	suite.Require().NotContains(rendered, "KAContext")
+}
+
+func (suite *automapSuite) TestInvalidLogger() {
+	for _, logger := range []string{
+		"LogError",
+		"github.com/myorg/mylib/logging",
+		".LogError",
+		"github.com/myorg/mylib/.LogError",
+		"./logging.LogError",
+		"github.com/myorg/mylib/logging.logError",
+		"github.com/myorg/mylib/logging.Log-Error",
+	} {
+		_, _, err := _parseLogger(logger)
+		suite.Require().Error(err, logger)
+		suite.Require().Contains(err.Error(), "invalid logger", logger)
+	}
+}
+
+func (suite *automapSuite) TestNullableErrorNonNullCode() {
+	// type MyMutation { error: MyMutationError }
+	// type MyMutationError { code: MyMutationErrorCode!, debugMessage: String }
+	objs := _testPayload("MyMutation", "NOT_FOUND", "INTERNAL")
+	debugMessageField := _testField("debugMessage", "String")
+	debugMessageField.TypeReference = &config.TypeReference{
+		GO: types.NewPointer(types.Typ[types.String]),
+	}
+	objs[1].Fields = append(objs[1].Fields, debugMessageField)
+
+	data, err := Automap{}._getAutomapData(objs[0], _objectsByName(_testMutationData(nil, objs)))
+	suite.Require().NoError(err)
+	suite.Require().True(data.DebugMessageIsPointer)
+
+	for _, recoverPanics := range []bool{false, true} {
+		rendered := suite._renderAutomapTemplate(&_automapTemplateData{
+			Mappers:       []*_automapper{data},
+			RecoverPanics: recoverPanics,
+		})
+		suite.Require().NoError(_typeCheckAutomappers(rendered, `
+			package graphql
+
+			type MyMutation struct {
+				Error *MyMutationError
+			}
+
+			type MyMutationError struct {
+				Code         MyMutationErrorCode
+				DebugMessage *string
+			}
+
+			type MyMutationErrorCode string
+
+			const (
+				MyMutationErrorCodeNotFound MyMutationErrorCode = "NOT_FOUND"
+				MyMutationErrorCodeInternal MyMutationErrorCode = "INTERNAL"
+			)
+		`), "RecoverPanics: %v\n%s", recoverPanics, rendered)
+	}
+}
+
+func (suite *automapSuite) TestConfiguredFieldNames() {
+	// type MyMutation { failure: MyMutationError }
+	// type MyMutationError { reason: MyMutationErrorCode!, details: String! }
+	objs := _testPayload("MyMutation", "NOT_FOUND", "INTERNAL")
+	objs[0].Fields[0].GoFieldName = "Failure"
+	objs[1].Fields[0].GoFieldName = "Reason"
+	detailsField := _testField("details", "String")
+	detailsField.TypeReference = &config.TypeReference{GO: types.Typ[types.String]}
+	objs[1].Fields = append(objs[1].Fields, detailsField)
+	objects := _objectsByName(_testMutationData(nil, objs))
+
+	// By default, we skip the type, since it has no Error field.
+	data, err := Automap{}._getAutomapData(objs[0], objects)
+	suite.Require().NoError(err)
+	suite.Require().Nil(data)
+
+	plugin := Automap{
+		ErrorFieldName:        "Failure",
+		CodeFieldName:         "Reason",
+		DebugMessageFieldName: "Details",
+	}
+	data, err = plugin._getAutomapData(objs[0], objects)
+	suite.Require().NoError(err)
+	suite.Require().Equal("Failure", data.ErrorField)
+	suite.Require().Equal("Reason", data.ErrorCodeField)
+	suite.Require().Equal("Details", data.DebugMessageField)
+
+	rendered := suite._renderAutomapTemplate(&_automapTemplateData{
+		Mappers: []*_automapper{data},
+	})
+	suite.Require().NoError(_typeCheckAutomappers(rendered, `
+		package graphql
+
+		type MyMutation struct {
+			Failure *MyMutationError
+		}
+
+		type MyMutationError struct {
+			Reason  MyMutationErrorCode
+			Details string
+		}
+
+		type MyMutationErrorCode string
+
+		const (
+			MyMutationErrorCodeNotFound MyMutationErrorCode = "NOT_FOUND"
+			MyMutationErrorCodeInternal MyMutationErrorCode = "INTERNAL"
+		)
+	`), rendered)
+
+	// Only the error field's name is configured, so we look for Code.
+	_, err = Automap{ErrorFieldName: "Failure"}._getAutomapData(objs[0], objects)
+	suite.Require().Error(err)
+	suite.Require().Contains(err.Error(), "no error-code field found")
+}
+
+func (suite *automapSuite) TestListErrorField() {
+	// type MyMutation { errors: [MyMutationError!] }
+	// type MyMutationError { code: MyMutationErrorCode! }
+	objs := _testPayload("MyMutation", "NOT_FOUND", "INTERNAL")
+	objs[0].Fields[0] = _testField("errors", "MyMutationError")
+	objs[0].Fields[0].FieldDefinition.Type = ast.ListType(
+		ast.NonNullNamedType("MyMutationError", nil), nil)
+	objs[0].Fields[0].TypeReference = &config.TypeReference{
+		GO:     types.NewSlice(types.NewPointer(_testNamedType("MyMutationError"))),
+		Target: _testNamedType("MyMutationError"),
+	}
+
+	data, err := Automap{ErrorFieldName: "Errors"}._getAutomapData(
+		objs[0], _objectsByName(_testMutationData(nil, objs)))
+	suite.Require().NoError(err)
+	suite.Require().True(data.ErrorFieldIsList)
+	suite.Require().Equal("Errors", data.ErrorField)
+
+	for _, recoverPanics := range []bool{false, true} {
+		rendered := suite._renderAutomapTemplate(&_automapTemplateData{
+			Mappers:       []*_automapper{data},
+			RecoverPanics: recoverPanics,
+		})
+		suite.Require().Contains(rendered, "Errors: []*graphql.MyMutationError{}")
+		suite.Require().NoError(_typeCheckAutomappers(rendered, `
+			package graphql
+
+			type MyMutation struct {
+				Errors []*MyMutationError
+			}
+
+			type MyMutationError struct {
+				Code MyMutationErrorCode
+			}
+
+			type MyMutationErrorCode string
+
+			const (
+				MyMutationErrorCodeNotFound MyMutationErrorCode = "NOT_FOUND"
+				MyMutationErrorCodeInternal MyMutationErrorCode = "INTERNAL"
+			)
+		`), "RecoverPanics: %v\n%s", recoverPanics, rendered)
+	}
+}
+
+func (suite *automapSuite) TestListErrorFieldOfValues() {
+	objs := _testPayload("MyMutation", "NOT_FOUND", "INTERNAL")
+	errorField := objs[0].Fields[0]
+	errorField.FieldDefinition.Type = ast.ListType(
+		ast.NonNullNamedType("MyMutationError", nil), nil)
+	errorField.TypeReference.GO = types.NewSlice(_testNamedType("MyMutationError"))
+
+	data, err := Automap{}._getAutomapData(objs[0], _objectsByName(_testMutationData(nil, objs)))
+	suite.Require().NoError(err)
+	suite.Require().True(data.ErrorFieldIsList)
+	suite.Require().False(data.ErrorIsPointer)
+
+	rendered := suite._renderAutomapTemplate(&_automapTemplateData{
+		Mappers: []*_automapper{data},
+	})
+	suite.Require().Contains(rendered, "Error: []graphql.MyMutationError{}")
+	suite.Require().NoError(_typeCheckAutomappers(rendered, `
+		package graphql
+
+		type MyMutation struct {
+			Error []MyMutationError
+		}
+
+		type MyMutationError struct {
+			Code MyMutationErrorCode
+		}
+
+		type MyMutationErrorCode string
+
+		const (
+			MyMutationErrorCodeNotFound MyMutationErrorCode = "NOT_FOUND"
+			MyMutationErrorCodeInternal MyMutationErrorCode = "INTERNAL"
+		)
+	`), rendered)
+}
+
+func (suite *automapSuite) TestListErrorFieldOfOtherType() {
+	objs := _testPayload("MyMutation", "NOT_FOUND", "INTERNAL")
+	errorField := objs[0].Fields[0]
+	errorField.FieldDefinition.Type = ast.ListType(
+		ast.NonNullNamedType("MyMutationError", nil), nil)
+	errorField.TypeReference.GO = types.NewSlice(types.Typ[types.String])
+
+	_, err := Automap{}._getAutomapData(objs[0], _objectsByName(_testMutationData(nil, objs)))
+	suite.Require().Error(err)
+	suite.Require().Contains(err.Error(),
+		"error field's Go type must be the error type or a pointer to it")
+}
+
+func (suite *automapSuite) TestNullableCode() {
+	objs := _testPayload("MyMutation", "NOT_FOUND", "INTERNAL")
+	codeField := objs[1].Fields[0]
+	codeField.TypeReference.GO = types.NewPointer(codeField.TypeReference.Target)
+
+	_, err := Automap{}._getAutomapData(objs[0], _objectsByName(_testMutationData(nil, objs)))
+	suite.Require().Error(err)
+	suite.Require().Contains(err.Error(), "error-code field must be non-null")
+}
+
+// _testErrorUnion returns the objects for a mutation payload type named
+// name, whose error field is a union of error types with the given names,
+// each @automapped from the given sentinel, and with a nullable
+// debugMessage.
+func _testErrorUnion(name string, members map[string]string) []*codegen.Object {
+	union := &ast.Definition{Kind: ast.Union, Name: name + "Error"}
+	errorField := _testField("error", union.Name)
+	// gqlgen generates an interface for the union, with no pointer.
+	errorField.TypeReference = &config.TypeReference{
+		Definition: union,
+		GO:         _testNamedType(union.Name),
+		Target:     _testNamedType(union.Name),
+	}
+	objs := []*codegen.Object{_testObject(name, errorField)}
+
+	memberNames := make([]string, 0, len(members))
+	for member := range members {
+		memberNames = append(memberNames, member)
+	}
+	sort.Strings(memberNames)
+	for _, member := range memberNames {
+		debugMessageField := _testField("debugMessage", "String")
+		debugMessageField.TypeReference = &config.TypeReference{
+			GO: types.NewPointer(types.Typ[types.String]),
+		}
+		obj := _testObject(member, debugMessageField)
+		obj.Definition.Directives = ast.DirectiveList{_automapDirective(members[member])}
+		union.Types = append(union.Types, member)
+		objs = append(objs, obj)
+	}
+	return objs
+}
+
+func (suite *automapSuite) TestErrorUnion() {
+	// union MyMutationError = NotFoundError | UnauthorizedError
+	objs := _testErrorUnion("MyMutation", map[string]string{
+		"NotFoundError":     "github.com/StevenACoffman/simplerr/errors.NotFoundKind",
+		"UnauthorizedError": "github.com/StevenACoffman/simplerr/errors.UnauthorizedKind",
+	})
+
+	data, err := Automap{}._getAutomapData(objs[0], _objectsByName(_testMutationData(nil, objs)))
+	suite.Require().NoError(err)
+	suite.Require().Equal([]AutomapError{
+		{From: "github.com/StevenACoffman/simplerr/errors.NotFoundKind", To: "NotFoundError"},
+		{From: "github.com/StevenACoffman/simplerr/errors.UnauthorizedKind", To: "UnauthorizedError"},
+	}, data.Errors)
+	suite.Require().Equal("", data.DefaultCode)
+	suite.Require().Len(data.UnionMembers, 2)
+	suite.Require().True(data.UnionDebugMessage)
+
+	for _, recoverPanics := range []bool{false, true} {
+		rendered := suite._renderAutomapTemplate(&_automapTemplateData{
+			Mappers:       []*_automapper{data},
+			RecoverPanics: recoverPanics,
+		})
+		suite.Require().Contains(rendered,
+			"return makeErr(&graphql.UnauthorizedError{DebugMessage: &msg}), nil")
+		suite.Require().NoError(_typeCheckAutomappers(rendered, `
+			package graphql
+
+			type MyMutation struct {
+				Error MyMutationError
+			}
+
+			type MyMutationError interface{ IsMyMutationError() }
+
+			type NotFoundError struct {
+				DebugMessage *string
+			}
+
+			func (NotFoundError) IsMyMutationError() {}
+
+			type UnauthorizedError struct {
+				DebugMessage *string
+			}
+
+			func (UnauthorizedError) IsMyMutationError() {}
+		`), "RecoverPanics: %v\n%s", recoverPanics, rendered)
+	}
+}
+
+func (suite *automapSuite) TestErrorUnionMemberNotAutomapped() {
+	objs := _testErrorUnion("MyMutation", map[string]string{
+		"NotFoundError":     "github.com/StevenACoffman/simplerr/errors.NotFoundKind",
+		"UnauthorizedError": "github.com/StevenACoffman/simplerr/errors.UnauthorizedKind",
+	})
+	objs[2].Definition.Directives = nil
+
+	_, err := Automap{}._getAutomapData(objs[0], _objectsByName(_testMutationData(nil, objs)))
+	suite.Require().Error(err)
+	suite.Require().Contains(err.Error(), "Not all union members automapped")
+	suite.Require().Contains(err.Error(), "UnauthorizedError")
+}
+
+func (suite *automapSuite) TestValueModelAndError() {
+	// As gqlgen generates for
+	//	type Mutation { myMutation: MyMutation! }
+	//	type MyMutation { error: MyMutationError! }
+	// with resolvers_always_return_pointers and
+	// struct_fields_always_pointers false.
+	objs := _testPayload("MyMutation", "NOT_FOUND", "INTERNAL")
+	errorField := objs[0].Fields[0]
+	errorField.TypeReference.GO = errorField.TypeReference.Target
+	mutationField := _testField("myMutation", "MyMutation")
+	mutationField.TypeReference = &config.TypeReference{
+		GO:     _testNamedType("MyMutation"),
+		Target: _testNamedType("MyMutation"),
+	}
+
+	data, err := Automap{}._getAutomapData(objs[0],
+		_objectsByName(_testMutationData([]*codegen.Field{mutationField}, objs)))
+	suite.Require().NoError(err)
+	suite.Require().False(data.ModelIsPointer)
+	suite.Require().False(data.ErrorIsPointer)
+
+	for _, recoverPanics := range []bool{false, true} {
+		rendered := suite._renderAutomapTemplate(&_automapTemplateData{
+			Mappers:       []*_automapper{data},
+			RecoverPanics: recoverPanics,
+		})
+		suite.Require().Contains(rendered, "return graphql.MyMutation{}, nil")
+		suite.Require().Contains(rendered, "Error: graphql.MyMutationError{")
+		suite.Require().NoError(_typeCheckAutomappers(rendered, `
+			package graphql
+
+			type MyMutation struct {
+				Error MyMutationError
+			}
+
+			type MyMutationError struct {
+				Code MyMutationErrorCode
+			}
+
+			type MyMutationErrorCode string
+
+			const (
+				MyMutationErrorCodeNotFound MyMutationErrorCode = "NOT_FOUND"
+				MyMutationErrorCodeInternal MyMutationErrorCode = "INTERNAL"
+			)
+		`), "RecoverPanics: %v\n%s", recoverPanics, rendered)
+	}
+
+	// Without a default code, unmapped errors return the zero model.
+	data.DefaultCode = ""
+	rendered := suite._renderAutomapTemplate(&_automapTemplateData{
+		Mappers: []*_automapper{data},
+	})
+	suite.Require().Contains(rendered, "return graphql.MyMutation{}, err")
+}
+
+func (suite *automapSuite) TestPointerModelByDefault() {
+	objs := _testPayload("MyMutation", "NOT_FOUND", "INTERNAL")
+	mutationField := _testField("myMutation", "MyMutation")
+	mutationField.TypeReference = &config.TypeReference{
+		GO:     types.NewPointer(_testNamedType("MyMutation")),
+		Target: _testNamedType("MyMutation"),
+	}
+
+	for _, mutationFields := range [][]*codegen.Field{nil, {mutationField}} {
+		data, err := Automap{}._getAutomapData(objs[0],
+			_objectsByName(_testMutationData(mutationFields, objs)))
+		suite.Require().NoError(err)
+		suite.Require().True(data.ModelIsPointer)
+		suite.Require().True(data.ErrorIsPointer)
+	}
+}
+
+func (suite *automapSuite) TestMixedPointerAndValueModel() {
+	objs := _testPayload("MyMutation", "NOT_FOUND", "INTERNAL")
+	pointerField := _testField("myMutation", "MyMutation")
+	pointerField.TypeReference = &config.TypeReference{
+		GO:     types.NewPointer(_testNamedType("MyMutation")),
+		Target: _testNamedType("MyMutation"),
+	}
+	valueField := _testField("myOtherMutation", "MyMutation")
+	valueField.TypeReference = &config.TypeReference{
+		GO:     _testNamedType("MyMutation"),
+		Target: _testNamedType("MyMutation"),
+	}
+
+	_, err := Automap{}._getAutomapData(objs[0], _objectsByName(
+		_testMutationData([]*codegen.Field{pointerField, valueField}, objs)))
+	suite.Require().Error(err)
+	suite.Require().Contains(err.Error(),
+		"some fields return the model as a pointer and some as a value")
+	suite.Require().Contains(err.Error(), "Mutation.myOtherMutation")
+}
+
+// _chdirToRelpathTree changes to a temporary directory containing schema/,
+// out/, and shared/ directories, each with an errors/ package, for tests of
+// _relpathToPackage, and returns the definition of an object type in
+// schema/mutation.graphql.
+// The working directory is restored at the end of the test.
+func (suite *automapSuite) _chdirToRelpathTree() *ast.Definition {
+	root := suite.T().TempDir()
+	for _, dir := range []string{"schema", "out", "shared"} {
+		suite.Require().NoError(os.MkdirAll(filepath.Join(root, dir, "errors"), 0o755))
+	}
+
+	wd, err := os.Getwd()
+	suite.Require().NoError(err)
+	suite.Require().NoError(os.Chdir(root))
+	suite.T().Cleanup(func() { _ = os.Chdir(wd) })
+
+	return &ast.Definition{
+		Kind: ast.Object,
+		Name: "MyMutation",
+		Position: &ast.Position{
+			Src: &ast.Source{Name: filepath.Join("schema", "mutation.graphql")},
+		},
+	}
+}
+
+func (suite *automapSuite) TestRelpathRelativeToSchema() {
+	def := suite._chdirToRelpathTree()
+
+	for _, base := range []string{"", AutomapRelativeToSchema} {
+		p := Automap{OutputDir: "out", RelativePathBase: base}
+		pkg, err := p._relpathToPackage(def, "./errors.ErrNotFound")
+		suite.Require().NoError(err)
+		suite.Require().Equal(PackageRoot+"schema/errors.ErrNotFound", pkg)
+	}
+}
+
+func (suite *automapSuite) TestRelpathRelativeToOutput() {
+	def := suite._chdirToRelpathTree()
+
+	p := Automap{OutputDir: "out", RelativePathBase: AutomapRelativeToOutput}
+	pkg, err := p._relpathToPackage(def, "./errors.ErrNotFound")
+	suite.Require().NoError(err)
+	suite.Require().Equal(PackageRoot+"out/errors.ErrNotFound", pkg)
+
+	pkg, err = p._relpathToPackage(def, "../shared/errors.ErrNotFound")
+	suite.Require().NoError(err)
+	suite.Require().Equal(PackageRoot+"shared/errors.ErrNotFound", pkg)
+}
+
+func (suite *automapSuite) TestRelpathRelativeToRoot() {
+	def := suite._chdirToRelpathTree()
+
+	p := Automap{
+		OutputDir:        "out",
+		RelativePathBase: AutomapRelativeToRoot,
+		RelativePathRoot: "shared",
+	}
+	pkg, err := p._relpathToPackage(def, "./errors.ErrNotFound")
+	suite.Require().NoError(err)
+	suite.Require().Equal(PackageRoot+"shared/errors.ErrNotFound", pkg)
+
+	p.RelativePathRoot = ""
+	_, err = p._relpathToPackage(def, "./errors.ErrNotFound")
+	suite.Require().Error(err)
+	suite.Require().Contains(err.Error(), "RelativePathRoot must be set")
+}
+
+func (suite *automapSuite) TestRelpathNotFound() {
+	def := suite._chdirToRelpathTree()
+	wd, err := os.Getwd()
+	suite.Require().NoError(err)
+
+	p := Automap{OutputDir: "out"}
+	_, err = p._relpathToPackage(def, "./mutation.ErrNotFound")
+	suite.Require().Error(err)
+	suite.Require().Contains(err.Error(), "invalid package-path: nonexistent directory")
+	suite.Require().Contains(err.Error(), filepath.Join(wd, "schema", "mutation"))
+	suite.Require().Contains(err.Error(), filepath.Join(wd, "out", "mutation"))
+
+	p.RelativePathBase = "elsewhere"
+	_, err = p._relpathToPackage(def, "./errors.ErrNotFound")
+	suite.Require().Error(err)
+	suite.Require().Contains(err.Error(), "invalid RelativePathBase")
+}
+
+// _fakePackageLoader "loads" the given packages, by path; others fail to
+// load.
+type _fakePackageLoader map[string]*types.Package
+
+func (l _fakePackageLoader) LoadAll(importPaths ...string) []*packages.Package {
+	pkgs := make([]*packages.Package, len(importPaths))
+	for i, importPath := range importPaths {
+		pkgs[i] = &packages.Package{PkgPath: importPath, Types: l[importPath]}
+		if pkgs[i].Types == nil {
+			pkgs[i].Errors = []packages.Error{{Msg: "cannot find package"}}
+		}
+	}
+	return pkgs
+}
+
+func (suite *automapSuite) TestVerifySentinels() {
+	errorsPkg := types.NewPackage("github.com/myorg/myservice/errors", "errors")
+	errorsPkg.Scope().Insert(types.NewVar(
+		token.NoPos, errorsPkg, "NotFoundKind", types.Universe.Lookup("error").Type()))
+	loader := _fakePackageLoader{errorsPkg.Path(): errorsPkg}
+	mappers := func(from string) []*_automapper {
+		return []*_automapper{{Errors: []AutomapError{{From: from, To: "NOT_FOUND"}}}}
+	}
+
+	err := _verifySentinels(loader, mappers("github.com/myorg/myservice/errors.NotFoundKind"))
+	suite.Require().NoError(err)
+
+	err = _verifySentinels(loader, mappers("github.com/myorg/myservice/errors.NotFoudKind"))
+	suite.Require().Error(err)
+	suite.Require().Contains(err.Error(),
+		"automap sentinel's package does not export a variable of that name")
+	suite.Require().Contains(err.Error(),
+		"sentinel:github.com/myorg/myservice/errors.NotFoudKind")
+
+	err = _verifySentinels(loader, mappers("github.com/myorg/myservic/errors.NotFoundKind"))
+	suite.Require().Error(err)
+	suite.Require().Contains(err.Error(), "automap sentinel's package could not be loaded")
+	suite.Require().Contains(err.Error(), "package:github.com/myorg/myservic/errors")
+}
+
+// _automapStubPackages are minimal stand-ins, by import path, for the
+// packages used by the code automap.gotpl generates; see
+// _typeCheckAutomappers.
+var _automapStubPackages = map[string]string{
+	"context": `
+		package context
+
+		type Context interface{ Done() <-chan struct{} }
+	`,
+	"github.com/StevenACoffman/simplerr/errors": `
+		package errors
+
+		type Fields map[string]interface{}
+
+		type Presented struct{ Message string }
+
+		var NotFoundKind error
+		var UnauthorizedKind error
+
+		func Internal(message string, fields ...Fields) error { return nil }
+		func Wrap(err error, args ...interface{}) error     { return nil }
+		func Is(err, target error) bool                      { return false }
+		func ErrorPresenter(ctx interface{}, err error, redact bool) Presented {
+			return Presented{}
+		}
+	`,
+	"github.com/Khan/webapp/pkg/lib/log": `
+		package log
+
+		type Logger interface {
+			Error(err error)
+			Warn(err error)
+		}
+
+		type KAContext interface{ Log() Logger }
+	`,
+}
+
+// _typeCheckAutomappers type-checks the given output of automap.gotpl (for
+// mappers that don't use the kind or graphql packages) against the given
+// source of the generated graphql package, and stubs of the other packages
+// it uses.  This is what gqlgen's compile step would catch, without needing
+// the real packages.
+func _typeCheckAutomappers(rendered string, graphqlSource string) error {
+	sources := map[string]string{_testGraphQLPkg.Path(): graphqlSource}
+	for path, source := range _automapStubPackages {
+		sources[path] = source
+	}
+
+	fset := token.NewFileSet()
+	packages := map[string]*types.Package{}
+	var importer _importerFunc
+	importer = func(path string) (*types.Package, error) {
+		if pkg, ok := packages[path]; ok {
+			return pkg, nil
+		}
+		source, ok := sources[path]
+		if !ok {
+			return nil, fmt.Errorf("no stub for package %v", path)
+		}
+		pkg, err := _typeCheckSource(fset, importer, path, source)
+		packages[path] = pkg
+		return pkg, err
+	}
+
+	_, err := _typeCheckSource(fset, importer, "automap", `
+		package automap
+
+		import (
+			"context"
+
+			"github.com/Khan/webapp/generated/graphql"
+			"github.com/Khan/webapp/pkg/lib/log"
+			"github.com/StevenACoffman/simplerr/errors"
+		)
+	`+rendered)
+	return err
+}
+
+type _importerFunc func(path string) (*types.Package, error)
+
+func (f _importerFunc) Import(path string) (*types.Package, error) { return f(path) }
+
+func _typeCheckSource(
+	fset *token.FileSet,
+	importer types.Importer,
+	path string,
+	source string,
+) (*types.Package, error) {
+	file, err := parser.ParseFile(fset, path+".go", source, 0)
+	if err != nil {
+		return nil, err
+	}
+	conf := types.Config{Importer: importer}
+	return conf.Check(path, fset, []*goast.File{file}, nil)
+}
+
+func TestAutomap(t *testing.T) {
+	khantest.Run(t, new(automapSuite))
+}
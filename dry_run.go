@@ -0,0 +1,70 @@
+package gqlgen_plugins
+
+// This file contains DryRun, which checks a schema against all our plugins
+// without generating any code.
+
+import (
+	"github.com/99designs/gqlgen/codegen"
+	"github.com/vektah/gqlparser/v2/ast"
+
+	"github.com/StevenACoffman/gqlgen-plugins/graphqltools"
+)
+
+// DryRunReport describes the problems the ReplacesDirective and Automap
+// plugins would report for a schema; see DryRun.
+type DryRunReport struct {
+	// ReplacesErrors are the problems with the schema's @replaces directives
+	// (as reported by get-replaces-directive-updates) or with the
+	// corresponding gqlgen config, any of which fail the ReplacesDirective
+	// plugin.
+	ReplacesErrors []error
+	// AutomapErrors are the problems which fail the Automap plugin, one per
+	// payload type.
+	AutomapErrors []error
+	// AutomapSkips describe the payload types for which Automap doesn't
+	// generate mappers, as "<type-name>: <reason>".  The plugin lists these
+	// in a comment in the generated file rather than failing.
+	AutomapSkips []string
+}
+
+// HasProblems returns whether the report lists any problems.
+func (r *DryRunReport) HasProblems() bool {
+	return len(r.ReplacesErrors) > 0 || len(r.AutomapErrors) > 0 || len(r.AutomapSkips) > 0
+}
+
+// DryRun runs the validation and generation steps of the ReplacesDirective
+// and Automap plugins for the given schema and gqlgen data, without writing
+// any files, and reports all the problems it finds, rather than stopping at
+// the first.  schema is the service's schema without the additions from
+// get-replaces-directive-updates; cfg is what gqlgen passes to plugins, for
+// the full schema.  This is meant for pre-merge checks.
+//
+// It runs the plugins with their default options; in particular it doesn't
+// check ReplacesDirective.ForwardingResolvers.
+func DryRun(schema *ast.Schema, cfg *codegen.Data) *DryRunReport {
+	var report DryRunReport
+
+	// The plugin's checks assume the directives themselves are valid, so we
+	// only run them if they are.
+	_, err := graphqltools.GetReplacesDirectiveUpdates(schema)
+	if err != nil {
+		report.ReplacesErrors = append(report.ReplacesErrors, err)
+	} else if schemaInfo, err := _getSchemaInfo(cfg.Schema); err != nil {
+		report.ReplacesErrors = append(report.ReplacesErrors, err)
+	} else {
+		err = _validateConfig(cfg.Config, schemaInfo)
+		if err != nil {
+			report.ReplacesErrors = append(report.ReplacesErrors, err)
+		}
+		_, err = _constructTemplateData(cfg, schemaInfo)
+		if err != nil {
+			report.ReplacesErrors = append(report.ReplacesErrors, err)
+		}
+	}
+
+	templateData, fatalErrors := Automap{}._getAutomapTemplateData(cfg)
+	report.AutomapErrors = fatalErrors
+	report.AutomapSkips = templateData.Errors
+
+	return &report
+}
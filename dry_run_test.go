@@ -0,0 +1,118 @@
+package gqlgen_plugins
+
+import (
+	"go/types"
+	"testing"
+
+	"github.com/99designs/gqlgen/codegen"
+	"github.com/99designs/gqlgen/codegen/config"
+
+	"github.com/Khan/webapp/dev/khantest"
+)
+
+type dryRunSuite struct{ khantest.Suite }
+
+func (suite *dryRunSuite) TestReportsProblemsFromBothPlugins() {
+	schema, err := parse(`
+		type User {
+			kaLocale: String @replaces(name: "locale")
+			locale: String
+		}
+	`)
+	suite.Require().NoError(err)
+
+	// A payload whose codes aren't all @automapped (SOMETHING_ELSE is
+	// neither @automapped nor a default), and one whose error code is
+	// nullable.
+	incomplete := _testPayload("AddCoursePayload", "SOMETHING_ELSE", "INTERNAL")
+	nullableCode := _testPayload("RemoveCoursePayload", "INTERNAL")
+	// The error object's (only) field is its code.
+	codeField := nullableCode[1].Fields[0]
+	suite.Require().Equal("code", codeField.Name)
+	codeField.TypeReference.GO = types.NewPointer(codeField.TypeReference.Target)
+
+	data := _testMutationData(nil, incomplete, nullableCode)
+	data.Schema = schema
+	data.Config = &config.Config{}
+
+	report := DryRun(schema, data)
+
+	suite.Require().True(report.HasProblems())
+	suite.Require().Len(report.ReplacesErrors, 1)
+	suite.Require().Contains(report.ReplacesErrors[0].Error(),
+		"@replaces directive old name collides with an existing name")
+	suite.Require().Empty(report.AutomapErrors)
+	suite.Require().Len(report.AutomapSkips, 2)
+	suite.Require().Contains(report.AutomapSkips[0], "AddCoursePayload: ")
+	suite.Require().Contains(report.AutomapSkips[0], "Not all values automapped")
+	suite.Require().Contains(report.AutomapSkips[1], "RemoveCoursePayload: ")
+	suite.Require().Contains(report.AutomapSkips[1], "error-code field must be non-null")
+}
+
+func (suite *dryRunSuite) TestReportsConfigProblems() {
+	schema, err := parse(`
+		type Classroom @replaces(name: "StudentList") {
+			id: ID!
+		}
+	`)
+	suite.Require().NoError(err)
+
+	// The full schema, with the old type, as get-replaces-directive-updates
+	// would add it.
+	fullSchema, err := parse(`
+		type Classroom @replaces(name: "StudentList") {
+			id: ID!
+		}
+
+		type StudentList {
+			id: ID!
+		}
+	`)
+	suite.Require().NoError(err)
+
+	// The old type's object also doesn't match the new one's.
+	data := _testMutationData(nil, []*codegen.Object{
+		_testObject("Classroom", _testField("id", "ID")),
+		_testObject("StudentList", _testField("id", "ID"), _testField("name", "String")),
+	})
+	data.Schema = fullSchema
+	data.Config = &config.Config{
+		Models: config.TypeMap{
+			"Classroom": config.TypeMapEntry{
+				Fields: map[string]config.TypeMapField{"id": {FieldName: "ID"}},
+			},
+		},
+	}
+
+	report := DryRun(schema, data)
+
+	suite.Require().True(report.HasProblems())
+	suite.Require().Len(report.ReplacesErrors, 2)
+	suite.Require().Contains(report.ReplacesErrors[0].Error(),
+		"model configs don't match for renamed object")
+	suite.Require().Contains(report.ReplacesErrors[1].Error(),
+		"could not generate mapper for renamed type; fields do not match")
+	suite.Require().Empty(report.AutomapErrors)
+	suite.Require().Empty(report.AutomapSkips)
+}
+
+func (suite *dryRunSuite) TestNoProblems() {
+	schema, err := parse(`
+		type User {
+			kaLocale: String @replaces(name: "locale")
+		}
+	`)
+	suite.Require().NoError(err)
+
+	data := _testMutationData(nil, _testPayload("AddCoursePayload", "INTERNAL"))
+	data.Schema = schema
+	data.Config = &config.Config{}
+
+	report := DryRun(schema, data)
+
+	suite.Require().False(report.HasProblems())
+}
+
+func TestDryRun(t *testing.T) {
+	khantest.Run(t, new(dryRunSuite))
+}
@@ -0,0 +1,39 @@
+// Package grpckind maps the sentinel errors of package kind to gRPC status
+// codes.  It's separate from package kind so that only callers which need it
+// depend on gRPC.
+package grpckind
+
+import (
+	"google.golang.org/grpc/codes"
+
+	"github.com/StevenACoffman/gqlgen-plugins/errors/kind"
+)
+
+// Code returns the gRPC status code corresponding to err's kind (per
+// kind.AsKind), the inverse of how the kinds were derived from them.  Like
+// gRPC's status.Code, it returns codes.OK for nil and codes.Unknown for an
+// error without a kind.
+func Code(err error) codes.Code {
+	if err == nil {
+		return codes.OK
+	}
+	k, _ := kind.AsKind(err)
+	switch k {
+	case kind.NotFound:
+		return codes.NotFound
+	case kind.InvalidInput:
+		return codes.InvalidArgument
+	case kind.NotAllowed:
+		return codes.FailedPrecondition
+	case kind.Unauthorized:
+		return codes.PermissionDenied
+	case kind.Internal, kind.GraphqlResponse, kind.KhanService, kind.Service:
+		return codes.Internal
+	case kind.NotImplemented:
+		return codes.Unimplemented
+	case kind.TransientKhanService, kind.TransientService:
+		return codes.Unavailable
+	default: // Unspecified, or not a kind
+		return codes.Unknown
+	}
+}
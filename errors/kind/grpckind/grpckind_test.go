@@ -0,0 +1,41 @@
+package grpckind_test
+
+import (
+	stderrs "errors"
+	"fmt"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+
+	"github.com/StevenACoffman/gqlgen-plugins/errors/kind"
+	"github.com/StevenACoffman/gqlgen-plugins/errors/kind/grpckind"
+)
+
+func TestCode(t *testing.T) {
+	testCases := []struct {
+		err  error
+		want codes.Code
+	}{
+		{kind.NotFound, codes.NotFound},
+		{kind.InvalidInput, codes.InvalidArgument},
+		{kind.NotAllowed, codes.FailedPrecondition},
+		{kind.Unauthorized, codes.PermissionDenied},
+		{kind.Internal, codes.Internal},
+		{kind.NotImplemented, codes.Unimplemented},
+		{kind.GraphqlResponse, codes.Internal},
+		{kind.TransientKhanService, codes.Unavailable},
+		{kind.KhanService, codes.Internal},
+		{kind.TransientService, codes.Unavailable},
+		{kind.Service, codes.Internal},
+		{kind.Unspecified, codes.Unknown},
+		{fmt.Errorf("wrapped: %w", kind.NotFound), codes.NotFound},
+		{stderrs.New("not found"), codes.Unknown},
+		{nil, codes.OK},
+	}
+	for _, testCase := range testCases {
+		actual := grpckind.Code(testCase.err)
+		if actual != testCase.want {
+			t.Errorf("Code(%v): got %v, wanted %v", testCase.err, actual, testCase.want)
+		}
+	}
+}
@@ -13,6 +13,7 @@ package kind
 
 import (
 	stderrs "errors"
+	"net/http"
 )
 
 var (
@@ -127,6 +128,88 @@ func AsKind(e error) (error, bool) {
 	return nil, false
 }
 
+// IsTransient reports whether err's kind (per AsKind) is TransientKhanService
+// or TransientService, i.e. whether it may be worth retrying whatever
+// returned it.
+func IsTransient(err error) bool {
+	k, _ := AsKind(err)
+	return k == TransientKhanService || k == TransientService
+}
+
+// HTTPStatus returns the HTTP status code corresponding to err's kind (per
+// AsKind), or 500 Internal Server Error if it doesn't have one.  Errors from
+// other services are 502 Bad Gateway, or 503 Service Unavailable if
+// transient.  Like grpckind.Code, it returns 200 OK for nil.
+func HTTPStatus(err error) int {
+	if err == nil {
+		return http.StatusOK
+	}
+	k, _ := AsKind(err)
+	switch k {
+	case NotFound:
+		return http.StatusNotFound
+	case InvalidInput:
+		return http.StatusBadRequest
+	case NotAllowed:
+		return http.StatusConflict
+	case Unauthorized:
+		return http.StatusForbidden
+	case NotImplemented:
+		return http.StatusNotImplemented
+	case GraphqlResponse, KhanService, Service:
+		return http.StatusBadGateway
+	case TransientKhanService, TransientService:
+		return http.StatusServiceUnavailable
+	default: // Internal, Unspecified, or not a kind
+		return http.StatusInternalServerError
+	}
+}
+
+// Severity returns the level at which to log err, based on its kind (per
+// AsKind): "error" for problems on our end, "warn" for problems with the
+// request, "info" for transient problems which retrying may resolve, and ""
+// if err doesn't have a kind.  These mostly follow the Log levels of
+// Automap's default error mappings.
+func Severity(err error) string {
+	k, ok := AsKind(err)
+	if !ok {
+		return ""
+	}
+	switch k {
+	case NotFound, InvalidInput, NotAllowed, Unauthorized, NotImplemented:
+		return "warn"
+	case TransientKhanService, TransientService:
+		return "info"
+	default: // Internal, KhanService, Service, GraphqlResponse, Unspecified
+		return "error"
+	}
+}
+
+// IsInTree reports whether target is anywhere in err's tree.  It's like
+// errors.Is, but also follows Cause() chains, and looks inside errors
+// combined with errors.Join (or anything else with an Unwrap() []error
+// method) at any depth, even if they're wrapped by an error that only knows
+// about Cause().
+func IsInTree(err, target error) bool {
+	if stderrs.Is(err, target) {
+		return true
+	}
+	for ; err != nil; err = unwrapOnce(err) {
+		if err == target {
+			return true
+		}
+		if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			for _, e := range joined.Unwrap() {
+				if IsInTree(e, target) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	return false
+}
+
 func unwrapOnce(err error) (cause error) {
 	switch e := err.(type) {
 	case interface{ Cause() error }:
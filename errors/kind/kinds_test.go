@@ -5,7 +5,7 @@ import (
 	"fmt"
 	"testing"
 
-	"github.com/Khan/shared-go/errors/kind"
+	"github.com/StevenACoffman/gqlgen-plugins/errors/kind"
 )
 
 func TestIsKind(t *testing.T) {
@@ -77,3 +77,144 @@ func TestAsKind(t *testing.T) {
 		}
 	}
 }
+
+// causeError wraps an error using only the (older) Cause() convention.
+type causeError struct{ cause error }
+
+func (e causeError) Error() string { return "wrapped: " + e.cause.Error() }
+func (e causeError) Cause() error  { return e.cause }
+
+func TestIsInTree(t *testing.T) {
+	joined := stderrs.Join(fmt.Errorf("oops: %w", kind.NotFound), kind.Unauthorized)
+	tests := []struct {
+		name     string
+		err      error
+		target   error
+		expected bool
+	}{
+		{"plain", kind.NotFound, kind.NotFound, true},
+		{"different kind", kind.NotFound, kind.Internal, false},
+		{"joined, first", joined, kind.NotFound, true},
+		{"joined, second", joined, kind.Unauthorized, true},
+		{"joined, missing", joined, kind.Internal, false},
+		{"joined inside Cause", causeError{joined}, kind.Unauthorized, true},
+		{"Cause", causeError{kind.NotAllowed}, kind.NotAllowed, true},
+		{"nil", nil, kind.NotFound, false},
+	}
+	for _, test := range tests {
+		actual := kind.IsInTree(test.err, test.target)
+		if actual != test.expected {
+			t.Fatalf("%s: IsInTree(%v, %v) = %t, wanted %t",
+				test.name, test.err, test.target, actual, test.expected)
+		}
+	}
+}
+
+// TestIsInTreePrecedence feeds a joined error through a switch shaped like
+// the ones Automap generates with MatchJoinedErrors set: the code of the
+// first (highest-precedence) case matching any of the joined errors wins,
+// regardless of the order in which the errors were joined.
+func TestIsInTreePrecedence(t *testing.T) {
+	mapper := func(err error) string {
+		switch {
+		case kind.IsInTree(err, kind.Unauthorized):
+			return "UNAUTHORIZED"
+		case kind.IsInTree(err, kind.NotFound):
+			return "NOT_FOUND"
+		case err != nil:
+			return "INTERNAL"
+		default:
+			return ""
+		}
+	}
+
+	for _, err := range []error{
+		stderrs.Join(kind.NotFound, kind.Unauthorized),
+		stderrs.Join(kind.Unauthorized, kind.NotFound),
+		causeError{stderrs.Join(kind.NotFound, causeError{kind.Unauthorized})},
+	} {
+		if code := mapper(err); code != "UNAUTHORIZED" {
+			t.Fatalf("mapper(%v) = %s, wanted UNAUTHORIZED", err, code)
+		}
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	errs := map[error]bool{
+		kind.TransientKhanService:                                                   true,
+		kind.TransientService:                                                       true,
+		fmt.Errorf("fetching user: %w", kind.TransientService):                      true,
+		fmt.Errorf("fetching user: %w", kind.Service):                               false,
+		fmt.Errorf("fetching user: %w", kind.NotFound):                              false,
+		stderrs.New(kind.TransientService.Error()):                                  false,
+		fmt.Errorf("outer: %w", fmt.Errorf("inner: %w", kind.TransientKhanService)): true,
+	}
+	for err, expected := range errs {
+		actual := kind.IsTransient(err)
+		if actual != expected {
+			t.Errorf("IsTransient(%v): got %t, wanted %t", err, actual, expected)
+		}
+	}
+
+	if kind.IsTransient(nil) {
+		t.Errorf("IsTransient(nil): got true, wanted false")
+	}
+}
+
+func TestHTTPStatus(t *testing.T) {
+	testCases := []struct {
+		err  error
+		want int
+	}{
+		{kind.NotFound, 404},
+		{kind.InvalidInput, 400},
+		{kind.NotAllowed, 409},
+		{kind.Unauthorized, 403},
+		{kind.Internal, 500},
+		{kind.NotImplemented, 501},
+		{kind.GraphqlResponse, 502},
+		{kind.TransientKhanService, 503},
+		{kind.KhanService, 502},
+		{kind.TransientService, 503},
+		{kind.Service, 502},
+		{kind.Unspecified, 500},
+		{fmt.Errorf("wrapped: %w", kind.NotFound), 404},
+		{stderrs.New("not found"), 500},
+		{nil, 200},
+	}
+	for _, testCase := range testCases {
+		actual := kind.HTTPStatus(testCase.err)
+		if actual != testCase.want {
+			t.Errorf("HTTPStatus(%v): got %d, wanted %d", testCase.err, actual, testCase.want)
+		}
+	}
+}
+
+func TestSeverity(t *testing.T) {
+	testCases := []struct {
+		err  error
+		want string
+	}{
+		{kind.NotFound, "warn"},
+		{kind.InvalidInput, "warn"},
+		{kind.NotAllowed, "warn"},
+		{kind.Unauthorized, "warn"},
+		{kind.Internal, "error"},
+		{kind.NotImplemented, "warn"},
+		{kind.GraphqlResponse, "error"},
+		{kind.TransientKhanService, "info"},
+		{kind.KhanService, "error"},
+		{kind.TransientService, "info"},
+		{kind.Service, "error"},
+		{kind.Unspecified, "error"},
+		{fmt.Errorf("wrapped: %w", kind.TransientService), "info"},
+		{stderrs.New("internal error"), ""},
+		{nil, ""},
+	}
+	for _, testCase := range testCases {
+		actual := kind.Severity(testCase.err)
+		if actual != testCase.want {
+			t.Errorf("Severity(%v): got %q, wanted %q", testCase.err, actual, testCase.want)
+		}
+	}
+}
@@ -6,10 +6,18 @@ package gqlgen_plugins
 
 import (
 	"go/types"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/99designs/gqlgen/plugin"
 	"github.com/99designs/gqlgen/plugin/modelgen"
+	"github.com/StevenACoffman/simplerr/errors"
+	"golang.org/x/tools/go/packages"
+
+	"github.com/StevenACoffman/gqlgen-plugins/errors/kind"
 )
 
 // ExtraFieldConfig describes an extra field added to a GraphQL model -- see
@@ -34,12 +42,45 @@ type ExtraFieldConfig struct {
 	//
 	// Note that the type will be referenced from the generated/graphql, which
 	// means the package it lives in must not reference the generated/graphql
-	// package to avoid circular imports.
-	// restrictions.
+	// package to avoid circular imports.  WrapModelgenWithExtraFields rejects
+	// types in that package itself (see GeneratedPackagePrefix), but can't
+	// check for indirect references.
 	Type string `yaml:"type"`
 
 	// Description will be used as the doc-comment for the Go field.
 	Description string `yaml:"description"`
+
+	// Tag, if set, is the struct tag of the Go field, like
+	//  json:"kaid" db:"kaid"
+	// (without the backticks).  It defaults to json:"-", since the field
+	// isn't in the schema.  See ValidateExtraFieldTags.
+	Tag string `yaml:"tag"`
+
+	// SkipIfExists, if set, says to not add this field to models which
+	// already have a field of the same Go name (typically one from the
+	// schema).  By default that's an error, since the model would have two
+	// fields of the same name; see WrapModelgenWithExtraFields.
+	SkipIfExists bool `yaml:"skip_if_exists"`
+}
+
+// GeneratedPackagePrefix is the path of the package of the models gqlgen
+// generates, from which (or from whose subpackages) extra fields' types may
+// not come; see ExtraFieldConfig.Type.  If unset, it's PackageRoot +
+// "generated/graphql", as of when WrapModelgenWithExtraFields is called.  Set
+// it to "-" to disable the check.
+var GeneratedPackagePrefix string
+
+// _generatedPackagePrefix returns the prefix to check extra fields' types
+// against, per GeneratedPackagePrefix, or "" to not check.
+func _generatedPackagePrefix() string {
+	switch GeneratedPackagePrefix {
+	case "":
+		return PackageRoot + "generated/graphql"
+	case "-":
+		return ""
+	default:
+		return GeneratedPackagePrefix
+	}
 }
 
 // _namedType returns the specified named or builtin type.
@@ -83,12 +124,303 @@ func _buildType(typeString string) types.Type {
 	}
 }
 
+// _baseTypeName returns the named or builtin type inside the given type
+// string (using the syntax from ExtraFieldConfig.Type above), e.g.
+// "github.com/Khan/webapp/pkg/web.Date" for "[]*github.com/Khan/webapp/pkg/web.Date".
+func _baseTypeName(typeString string) string {
+	for {
+		switch {
+		case strings.HasPrefix(typeString, "*"):
+			typeString = typeString[1:]
+		case strings.HasPrefix(typeString, "[]"):
+			typeString = typeString[2:]
+		default:
+			return typeString
+		}
+	}
+}
+
+// ValidateExtraFieldTypes returns an error if any of the named types
+// referenced by the given extra-field config don't exist, i.e. if the package
+// can't be loaded or doesn't export a type of that name.
+//
+// _namedType doesn't (and can't) check this, so without this validation a
+// typo in a type only surfaces when the generated models fail to compile.
+// Loading the packages is slow-ish, so this is optional: call it before
+// WrapModelgenWithExtraFields if you want the clearer error.
+func ValidateExtraFieldTypes(cfg map[string][]ExtraFieldConfig) error {
+	// A map from package path to the names of the types (and the models that
+	// use them, for the error message) we need from that package.
+	typeNamesByPkgPath := map[string]map[string]string{}
+	for modelName, fieldConfigs := range cfg {
+		for _, fieldConfig := range fieldConfigs {
+			fullName := _baseTypeName(fieldConfig.Type)
+			dotIndex := strings.LastIndex(fullName, ".")
+			if dotIndex == -1 {
+				if types.Universe.Lookup(fullName) == nil {
+					return errors.WrapWithFields(kind.InvalidInput, errors.Fields{
+						"message": "extra field type is not a builtin type or package-qualified",
+						"model":   modelName,
+						"field":   fieldConfig.Name,
+						"type":    fieldConfig.Type,
+					})
+				}
+				continue
+			}
+			pkgPath := fullName[:dotIndex]
+			if typeNamesByPkgPath[pkgPath] == nil {
+				typeNamesByPkgPath[pkgPath] = map[string]string{}
+			}
+			typeNamesByPkgPath[pkgPath][fullName[dotIndex+1:]] = modelName
+		}
+	}
+	if len(typeNamesByPkgPath) == 0 {
+		return nil
+	}
+
+	pkgPaths := make([]string, 0, len(typeNamesByPkgPath))
+	for pkgPath := range typeNamesByPkgPath {
+		pkgPaths = append(pkgPaths, pkgPath)
+	}
+	sort.Strings(pkgPaths)
+
+	pkgs, err := packages.Load(
+		&packages.Config{Mode: packages.NeedName | packages.NeedTypes}, pkgPaths...)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	pkgsByPath := make(map[string]*packages.Package, len(pkgs))
+	for _, pkg := range pkgs {
+		pkgsByPath[pkg.PkgPath] = pkg
+	}
+
+	for _, pkgPath := range pkgPaths {
+		pkg := pkgsByPath[pkgPath]
+		if pkg == nil || len(pkg.Errors) > 0 || pkg.Types == nil {
+			fields := errors.Fields{
+				"message": "extra field type's package could not be loaded",
+				"package": pkgPath,
+			}
+			if pkg != nil && len(pkg.Errors) > 0 {
+				fields["originErr"] = pkg.Errors[0]
+			}
+			return errors.WrapWithFields(kind.InvalidInput, fields)
+		}
+
+		typeNames := make([]string, 0, len(typeNamesByPkgPath[pkgPath]))
+		for typeName := range typeNamesByPkgPath[pkgPath] {
+			typeNames = append(typeNames, typeName)
+		}
+		sort.Strings(typeNames)
+		for _, typeName := range typeNames {
+			obj := pkg.Types.Scope().Lookup(typeName)
+			if _, ok := obj.(*types.TypeName); !ok || !obj.Exported() {
+				return errors.WrapWithFields(kind.InvalidInput, errors.Fields{
+					"message": "extra field type's package does not export a type of that name",
+					"package": pkgPath,
+					"type":    typeName,
+					"model":   typeNamesByPkgPath[pkgPath][typeName],
+				})
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateExtraFieldTags returns an error if any of the tags in the given
+// extra-field config are malformed, i.e. aren't a space-separated list of
+// key:"value" pairs (per the reflect.StructTag conventions), or contain
+// backticks, which would break the generated code.
+func ValidateExtraFieldTags(cfg map[string][]ExtraFieldConfig) error {
+	for modelName, fieldConfigs := range cfg {
+		for _, fieldConfig := range fieldConfigs {
+			if fieldConfig.Tag == "" || _isWellFormedTag(fieldConfig.Tag) {
+				continue
+			}
+			return errors.WrapWithFields(kind.InvalidInput, errors.Fields{
+				"message": "extra field tag is malformed: should be like key:\"value\" other:\"value\"",
+				"model":   modelName,
+				"field":   fieldConfig.Name,
+				"tag":     fieldConfig.Tag,
+			})
+		}
+	}
+	return nil
+}
+
+// _isWellFormedTag returns whether tag is a space-separated list of
+// key:"value" pairs, and can be put in backticks.
+func _isWellFormedTag(tag string) bool {
+	if strings.ContainsAny(tag, "`\n") {
+		return false
+	}
+	for tag = strings.TrimLeft(tag, " "); tag != ""; tag = strings.TrimLeft(tag, " ") {
+		colonIndex := strings.Index(tag, ":")
+		if colonIndex <= 0 || strings.ContainsAny(tag[:colonIndex], " \"") {
+			return false
+		}
+		value, err := strconv.QuotedPrefix(tag[colonIndex+1:])
+		if err != nil || value[0] != '"' {
+			return false
+		}
+		tag = tag[colonIndex+1+len(value):]
+		if tag != "" && tag[0] != ' ' {
+			return false
+		}
+	}
+	return true
+}
+
+// _validateExtraFieldPackages returns an error if any of the types
+// referenced by the given extra-field config come from the package with the
+// given path prefix, or one of its subpackages (typically per
+// GeneratedPackagePrefix), which would make for a circular import.
+func _validateExtraFieldPackages(cfg map[string][]ExtraFieldConfig, prefix string) error {
+	if prefix == "" {
+		return nil
+	}
+
+	modelNames := make([]string, 0, len(cfg))
+	for modelName := range cfg {
+		modelNames = append(modelNames, modelName)
+	}
+	sort.Strings(modelNames)
+
+	for _, modelName := range modelNames {
+		for _, fieldConfig := range cfg[modelName] {
+			fullName := _baseTypeName(fieldConfig.Type)
+			dotIndex := strings.LastIndex(fullName, ".")
+			if dotIndex == -1 {
+				continue
+			}
+			pkg := fullName[:dotIndex]
+			if pkg != prefix && !strings.HasPrefix(pkg, prefix+"/") {
+				continue
+			}
+			return errors.WrapWithFields(kind.InvalidInput, errors.Fields{
+				"message": "extra field type is from the generated package, " +
+					"which would be a circular import",
+				"model":  modelName,
+				"field":  fieldConfig.Name,
+				"type":   fieldConfig.Type,
+				"prefix": prefix,
+			})
+		}
+	}
+	return nil
+}
+
+// _extraFieldsPattern is a key of the config to WrapModelgenWithExtraFields
+// which may match several models, and the extra fields for those models.
+type _extraFieldsPattern struct {
+	matches      func(modelName string) bool
+	fieldConfigs []ExtraFieldConfig
+}
+
+// _compileExtraFieldsPatterns returns the patterns among the keys of the
+// given config, sorted by key, or an error if any are malformed.  Keys of
+// the form /regexp/ are regular expressions, and other keys containing any
+// of *?[ are globs per path.Match; either must match the whole model name.
+// Other keys are just model names.
+func _compileExtraFieldsPatterns(cfg map[string][]ExtraFieldConfig) ([]_extraFieldsPattern, error) {
+	keys := make([]string, 0, len(cfg))
+	for key := range cfg {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var patterns []_extraFieldsPattern
+	for _, key := range keys {
+		var matches func(string) bool
+		switch {
+		case len(key) > 1 && strings.HasPrefix(key, "/") && strings.HasSuffix(key, "/"):
+			re, err := regexp.Compile("^(?:" + key[1:len(key)-1] + ")$")
+			if err != nil {
+				return nil, errors.WrapWithFields(kind.InvalidInput, errors.Fields{
+					"message":   "extra fields model pattern is not a valid regexp",
+					"pattern":   key,
+					"originErr": err,
+				})
+			}
+			matches = re.MatchString
+		case strings.ContainsAny(key, "*?["):
+			if _, err := path.Match(key, ""); err != nil {
+				return nil, errors.WrapWithFields(kind.InvalidInput, errors.Fields{
+					"message":   "extra fields model pattern is not a valid glob",
+					"pattern":   key,
+					"originErr": err,
+				})
+			}
+			glob := key
+			matches = func(modelName string) bool {
+				ok, _ := path.Match(glob, modelName)
+				return ok
+			}
+		default:
+			continue // an exact model name
+		}
+		patterns = append(patterns, _extraFieldsPattern{matches, cfg[key]})
+	}
+	return patterns, nil
+}
+
+// _extraFieldConfigsFor returns the extra fields for the given model: those
+// configured for its exact name, then those for each pattern it matches,
+// omitting any with the same name as one already included, so that
+// exact-name config takes precedence, followed by patterns in order of key.
+func _extraFieldConfigsFor(
+	modelName string,
+	cfg map[string][]ExtraFieldConfig,
+	patterns []_extraFieldsPattern,
+) []ExtraFieldConfig {
+	// (We copy, so as not to append to the exact-name config's array.)
+	fieldConfigs := append([]ExtraFieldConfig(nil), cfg[modelName]...)
+	for _, pattern := range patterns {
+		if !pattern.matches(modelName) {
+			continue
+		}
+		for _, fieldConfig := range pattern.fieldConfigs {
+			if !_hasFieldConfig(fieldConfigs, fieldConfig.Name) {
+				fieldConfigs = append(fieldConfigs, fieldConfig)
+			}
+		}
+	}
+	return fieldConfigs
+}
+
+// _hasFieldConfig returns whether fieldConfigs configures a field of the
+// given Go name.
+func _hasFieldConfig(fieldConfigs []ExtraFieldConfig, goName string) bool {
+	for _, fieldConfig := range fieldConfigs {
+		if fieldConfig.Name == goName {
+			return true
+		}
+	}
+	return false
+}
+
+// _hasField returns whether the given model has a field of the given Go name.
+func _hasField(model *modelgen.Object, goName string) bool {
+	for _, field := range model.Fields {
+		if field.GoName == goName {
+			return true
+		}
+	}
+	return false
+}
+
 // _makeExtraFieldsMutateHook returns a gqlgen MutateHook which adds extra
 // fields described by WrapModelgenWithExtraFields to the GraphQL schema.
 func _makeExtraFieldsMutateHook(
 	cfg map[string][]ExtraFieldConfig,
 	oldMutateHook modelgen.BuildMutateHook,
 ) func(*modelgen.ModelBuild) *modelgen.ModelBuild {
+	// WrapModelgenWithExtraFields already checked the patterns.
+	patterns, err := _compileExtraFieldsPatterns(cfg)
+	if err != nil {
+		panic(err)
+	}
+
 	return func(b *modelgen.ModelBuild) *modelgen.ModelBuild {
 		// We apply upstream's mutate-hook, then add in ours.
 		b = oldMutateHook(b)
@@ -98,17 +430,36 @@ func _makeExtraFieldsMutateHook(
 		}
 
 		for _, model := range b.Models {
-			fieldConfigs, ok := cfg[model.Name]
-			if !ok {
+			fieldConfigs := _extraFieldConfigsFor(model.Name, cfg, patterns)
+			if len(fieldConfigs) == 0 {
 				continue // no modifications requested for this model
 			}
 
 			for _, fieldConfig := range fieldConfigs {
+				if _hasField(model, fieldConfig.Name) {
+					if fieldConfig.SkipIfExists {
+						continue
+					}
+					// gqlgen gives us no way to return an error here, so
+					// we panic; the alternative is models that don't
+					// compile.
+					panic(errors.WrapWithFields(kind.InvalidInput, errors.Fields{
+						"message": "extra field has the same name as an existing field; " +
+							"rename it or set skip_if_exists",
+						"model": model.Name,
+						"field": fieldConfig.Name,
+					}))
+				}
+
+				tag := fieldConfig.Tag
+				if tag == "" {
+					tag = `json:"-"`
+				}
 				model.Fields = append(model.Fields, &modelgen.Field{
 					Name:        fieldConfig.Name,
 					GoName:      fieldConfig.Name,
 					Type:        _buildType(fieldConfig.Type),
-					Tag:         `json:"-"`,
+					Tag:         tag,
 					Description: strings.TrimSpace(fieldConfig.Description),
 				})
 			}
@@ -149,10 +500,35 @@ func _makeExtraFieldsMutateHook(
 // circular imports, which makes it a bigger problem.  So we offer adding
 // custom fields to the autogenerated models as an alternative.
 //
-// See ExtraFieldConfig for configuration details.
+// The keys of cfg are model names, or patterns matching several models:
+// either globs like *Payload (per path.Match), or regular expressions like
+// /.*Payload/, which must match the whole name.  A model gets the extra
+// fields for its name and for each pattern it matches; if several of those
+// configure fields of the same name, the exact-name config wins, and then
+// the pattern which sorts first.
+//
+// See ExtraFieldConfig for configuration details.  Since this runs when
+// gqlgen is being configured, before there's anywhere to return an error, it
+// panics if any pattern or tag is malformed or any extra field's type is in
+// GeneratedPackagePrefix.  Likewise, the returned plugin panics when
+// generating models if an extra field collides with an existing field of the
+// model, unless the extra field sets SkipIfExists.
 func WrapModelgenWithExtraFields(
 	cfg map[string][]ExtraFieldConfig,
 ) func(plugin.Plugin) plugin.Plugin {
+	_, err := _compileExtraFieldsPatterns(cfg)
+	if err != nil {
+		panic(err)
+	}
+	err = ValidateExtraFieldTags(cfg)
+	if err != nil {
+		panic(err)
+	}
+	err = _validateExtraFieldPackages(cfg, _generatedPackagePrefix())
+	if err != nil {
+		panic(err)
+	}
+
 	return func(p plugin.Plugin) plugin.Plugin {
 		modelgenPlugin, _ := p.(*modelgen.Plugin)
 		modelgenPlugin.MutateHook = _makeExtraFieldsMutateHook(
@@ -0,0 +1,241 @@
+package gqlgen_plugins
+
+import (
+	"testing"
+
+	"github.com/99designs/gqlgen/plugin/modelgen"
+
+	"github.com/Khan/webapp/dev/khantest"
+)
+
+type extraFieldsSuite struct{ khantest.Suite }
+
+func (suite *extraFieldsSuite) TestValidateExtraFieldTypesOkay() {
+	err := ValidateExtraFieldTypes(map[string][]ExtraFieldConfig{
+		"User": {
+			{Name: "Kaid", Type: "string"},
+			{Name: "Pkgs", Type: "[]*go/types.Package"},
+		},
+	})
+	suite.Require().NoError(err)
+}
+
+func (suite *extraFieldsSuite) TestValidateExtraFieldTypesNonexistentPackage() {
+	err := ValidateExtraFieldTypes(map[string][]ExtraFieldConfig{
+		"User": {
+			{Name: "Date", Type: "*github.com/StevenACoffman/gqlgen-plugins/nonexistent.Date"},
+		},
+	})
+	suite.Require().Error(err)
+	suite.Require().Contains(err.Error(), "extra field type's package could not be loaded")
+}
+
+func (suite *extraFieldsSuite) TestValidateExtraFieldTypesNonexistentType() {
+	err := ValidateExtraFieldTypes(map[string][]ExtraFieldConfig{
+		"User": {
+			// kind.NotFound is a variable, not a type.
+			{Name: "Kind", Type: "github.com/StevenACoffman/gqlgen-plugins/errors/kind.NotFound"},
+		},
+	})
+	suite.Require().Error(err)
+	suite.Require().Contains(
+		err.Error(), "extra field type's package does not export a type of that name")
+}
+
+func (suite *extraFieldsSuite) TestValidateExtraFieldTypesNonexistentBuiltin() {
+	err := ValidateExtraFieldTypes(map[string][]ExtraFieldConfig{
+		"User": {{Name: "Kaid", Type: "strng"}},
+	})
+	suite.Require().Error(err)
+	suite.Require().Contains(
+		err.Error(), "extra field type is not a builtin type or package-qualified")
+}
+
+func (suite *extraFieldsSuite) TestExtraFieldTag() {
+	hook := _makeExtraFieldsMutateHook(map[string][]ExtraFieldConfig{
+		"User": {
+			{Name: "Kaid", Type: "string", Tag: `json:"kaid" db:"kaid"`},
+			{Name: "Parent", Type: "*string"},
+		},
+	}, func(b *modelgen.ModelBuild) *modelgen.ModelBuild { return b })
+
+	b := hook(&modelgen.ModelBuild{Models: []*modelgen.Object{{Name: "User"}}})
+
+	fields := b.Models[0].Fields
+	suite.Require().Len(fields, 2)
+	suite.Require().Equal(`json:"kaid" db:"kaid"`, fields[0].Tag)
+	suite.Require().Equal(`json:"-"`, fields[1].Tag)
+}
+
+func (suite *extraFieldsSuite) TestExtraFieldCollision() {
+	identity := func(b *modelgen.ModelBuild) *modelgen.ModelBuild { return b }
+	build := func() *modelgen.ModelBuild {
+		return &modelgen.ModelBuild{Models: []*modelgen.Object{{
+			Name:   "User",
+			Fields: []*modelgen.Field{{Name: "kaid", GoName: "Kaid", Tag: `json:"kaid"`}},
+		}}}
+	}
+
+	hook := _makeExtraFieldsMutateHook(map[string][]ExtraFieldConfig{
+		"User": {{Name: "Kaid", Type: "string"}},
+	}, identity)
+	suite.Require().Panics(func() { hook(build()) })
+
+	hook = _makeExtraFieldsMutateHook(map[string][]ExtraFieldConfig{
+		"User": {
+			{Name: "Kaid", Type: "string", SkipIfExists: true},
+			{Name: "Parent", Type: "string"},
+		},
+	}, identity)
+	b := hook(build())
+	fields := b.Models[0].Fields
+	suite.Require().Len(fields, 2)
+	suite.Require().Equal(`json:"kaid"`, fields[0].Tag)
+	suite.Require().Equal("Parent", fields[1].GoName)
+}
+
+func (suite *extraFieldsSuite) TestExtraFieldPatterns() {
+	hook := _makeExtraFieldsMutateHook(map[string][]ExtraFieldConfig{
+		"*Payload": {{Name: "TraceID", Type: "string"}},
+		"/Add.*/":  {{Name: "Source", Type: "string"}},
+	}, func(b *modelgen.ModelBuild) *modelgen.ModelBuild { return b })
+
+	b := hook(&modelgen.ModelBuild{Models: []*modelgen.Object{
+		{Name: "AddCoursePayload"},
+		{Name: "RemoveCoursePayload"},
+		{Name: "PayloadHistory"},
+		{Name: "AddressBook"},
+	}})
+
+	fieldNames := func(model *modelgen.Object) []string {
+		var names []string
+		for _, field := range model.Fields {
+			names = append(names, field.GoName)
+		}
+		return names
+	}
+	suite.Require().Equal([]string{"TraceID", "Source"}, fieldNames(b.Models[0]))
+	suite.Require().Equal([]string{"TraceID"}, fieldNames(b.Models[1]))
+	suite.Require().Empty(fieldNames(b.Models[2]))
+	suite.Require().Equal([]string{"Source"}, fieldNames(b.Models[3]))
+}
+
+func (suite *extraFieldsSuite) TestExtraFieldPatternPrecedence() {
+	cfg := map[string][]ExtraFieldConfig{
+		"*Payload":         {{Name: "TraceID", Type: "string"}, {Name: "Source", Type: "string"}},
+		"AddCoursePayload": {{Name: "TraceID", Type: "*string", Tag: `json:"traceId"`}},
+	}
+	hook := _makeExtraFieldsMutateHook(
+		cfg, func(b *modelgen.ModelBuild) *modelgen.ModelBuild { return b })
+
+	b := hook(&modelgen.ModelBuild{Models: []*modelgen.Object{
+		{Name: "AddCoursePayload"},
+		{Name: "RemoveCoursePayload"},
+	}})
+
+	fields := b.Models[0].Fields
+	suite.Require().Len(fields, 2)
+	suite.Require().Equal("TraceID", fields[0].GoName)
+	suite.Require().Equal(`json:"traceId"`, fields[0].Tag)
+	suite.Require().Equal("Source", fields[1].GoName)
+
+	fields = b.Models[1].Fields
+	suite.Require().Len(fields, 2)
+	suite.Require().Equal(`json:"-"`, fields[0].Tag)
+
+	// The exact-name config is unchanged.
+	suite.Require().Len(cfg["AddCoursePayload"], 1)
+}
+
+func (suite *extraFieldsSuite) TestInvalidExtraFieldPatterns() {
+	for _, pattern := range []string{"/(/", "[Payload"} {
+		_, err := _compileExtraFieldsPatterns(map[string][]ExtraFieldConfig{
+			pattern: {{Name: "TraceID", Type: "string"}},
+		})
+		suite.Require().Error(err, pattern)
+		suite.Require().Contains(err.Error(), "extra fields model pattern is not a valid", pattern)
+	}
+}
+
+func (suite *extraFieldsSuite) TestValidateExtraFieldTags() {
+	for _, tag := range []string{
+		"",
+		`json:"kaid"`,
+		`json:"kaid,omitempty" db:"kaid"`,
+		` json:"a b"  db:"\"quoted\""`,
+	} {
+		err := ValidateExtraFieldTags(map[string][]ExtraFieldConfig{
+			"User": {{Name: "Kaid", Type: "string", Tag: tag}},
+		})
+		suite.Require().NoError(err, tag)
+	}
+
+	for _, tag := range []string{
+		"json:\"kaid\" `",
+		`json:kaid`,
+		`json:"kaid`,
+		`json:"kaid"db:"kaid"`,
+		`:"kaid"`,
+		`json :"kaid"`,
+		`json:'kaid'`,
+	} {
+		err := ValidateExtraFieldTags(map[string][]ExtraFieldConfig{
+			"User": {{Name: "Kaid", Type: "string", Tag: tag}},
+		})
+		suite.Require().Error(err, tag)
+		suite.Require().Contains(err.Error(), "extra field tag is malformed", tag)
+	}
+
+	suite.Require().Panics(func() {
+		WrapModelgenWithExtraFields(map[string][]ExtraFieldConfig{
+			"User": {{Name: "Kaid", Type: "string", Tag: `json:kaid`}},
+		})
+	})
+}
+
+func (suite *extraFieldsSuite) TestGeneratedPackageRejected() {
+	cfg := map[string][]ExtraFieldConfig{
+		"User": {
+			{Name: "Kaid", Type: "string"},
+			{Name: "Parent", Type: "[]*github.com/Khan/webapp/generated/graphql.User"},
+		},
+	}
+
+	err := _validateExtraFieldPackages(cfg, "github.com/Khan/webapp/generated/graphql")
+	suite.Require().Error(err)
+	suite.Require().Contains(err.Error(), "extra field type is from the generated package")
+	suite.Require().Contains(err.Error(), "Parent")
+
+	suite.Require().NoError(
+		_validateExtraFieldPackages(cfg, "github.com/Khan/webapp/generated/other"))
+	// The prefix only matches whole path components.
+	suite.Require().NoError(
+		_validateExtraFieldPackages(cfg, "github.com/Khan/webapp/generated/graph"))
+	suite.Require().Error(_validateExtraFieldPackages(cfg, "github.com/Khan/webapp/generated"))
+	suite.Require().NoError(_validateExtraFieldPackages(cfg, ""))
+
+	suite.Require().Panics(func() {
+		WrapModelgenWithExtraFields(map[string][]ExtraFieldConfig{
+			"User": {{Name: "Parent", Type: "*" + PackageRoot + "generated/graphql.User"}},
+		})
+	})
+}
+
+func (suite *extraFieldsSuite) TestGeneratedPackagePrefixDefault() {
+	defer func(packageRoot string) { PackageRoot = packageRoot }(PackageRoot)
+	defer func(prefix string) { GeneratedPackagePrefix = prefix }(GeneratedPackagePrefix)
+
+	// By default, the prefix follows PackageRoot, even if it's set later.
+	PackageRoot = "example.com/monorepo/"
+	cfg := map[string][]ExtraFieldConfig{
+		"User": {{Name: "Parent", Type: "*example.com/monorepo/generated/graphql.User"}},
+	}
+	suite.Require().Panics(func() { WrapModelgenWithExtraFields(cfg) })
+
+	GeneratedPackagePrefix = "-"
+	WrapModelgenWithExtraFields(cfg) // doesn't panic
+}
+
+func TestExtraFields(t *testing.T) {
+	khantest.Run(t, new(extraFieldsSuite))
+}
@@ -10,6 +10,8 @@ require (
 	github.com/Khan/webapp v0.0.0-00010101000000-000000000000
 	github.com/StevenACoffman/simplerr v0.0.0-20230419164504-91cf1c91bd28
 	github.com/vektah/gqlparser/v2 v2.5.1
+	golang.org/x/tools v0.8.0
+	google.golang.org/grpc v1.55.0
 )
 
 require (
@@ -20,6 +22,7 @@ require (
 	github.com/felixge/httpsnoop v1.0.3 // indirect
 	github.com/go-logr/logr v1.2.3 // indirect
 	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/golang/protobuf v1.5.3 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/sirupsen/logrus v1.9.0 // indirect
 	github.com/stretchr/testify v1.8.2 // indirect
@@ -32,6 +35,6 @@ require (
 	golang.org/x/mod v0.10.0 // indirect
 	golang.org/x/sys v0.8.0 // indirect
 	golang.org/x/text v0.9.0 // indirect
-	golang.org/x/tools v0.8.0 // indirect
+	google.golang.org/protobuf v1.30.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
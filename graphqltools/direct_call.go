@@ -0,0 +1,107 @@
+package graphqltools
+
+// This file contains tools for deciding whether an operation can be sent
+// directly to the service that resolves it, bypassing the graphql-gateway.
+
+import (
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// Reasons DirectCallPlan may give for an operation having to go through the
+// graphql-gateway.
+const (
+	// The operation needs more than one service.
+	DirectCallMultiService = "multi-service"
+	// The operation doesn't need any particular service, e.g. it only
+	// selects __typename, so there's no service to call.
+	DirectCallNoService = "no-service"
+	// The operation selects a side-by-side field; see OperationMetadata.
+	DirectCallSideBySide = "side-by-side"
+	// The operation selects a canary field; see OperationMetadata.
+	DirectCallCanary = "canary"
+	// The operation has mixed aliases; see OperationMetadata.
+	DirectCallMixedAlias = "mixed-alias"
+	// The operation selects a field by its old (@replaces) name.
+	DirectCallUsesOldName = "uses-old-name"
+)
+
+// DirectCallPlan returns whether the operation in the given query text is
+// safe to send directly to the service that resolves it, rather than through
+// the graphql-gateway, according to the given composed schema (see
+// ServicesForOperation). If it is, it returns that service; otherwise it
+// returns the (DirectCall*) reasons it isn't, in the order they're declared.
+//
+// renames maps each renamed field, by its new name, to its old name, as for
+// FieldsSelectedWithOldName. Operations using an old name must go through
+// the gateway, since the service may no longer know it.
+func DirectCallPlan(
+	schema *ast.Schema,
+	queryText string,
+	renames map[FieldRef]string,
+) (service string, ok bool, reasons []string, err error) {
+	operation, err := loadFederatedOperation(schema, queryText, false)
+	if err != nil {
+		return "", false, nil, err
+	}
+
+	services := newUniqueServices()
+	NewOperationAnalyzer(schema).processSelectionSet(operation.SelectionSet, nil,
+		func(_ []string, service string) { services.add(service) })
+	switch len(services.ordered) {
+	case 0:
+		reasons = append(reasons, DirectCallNoService)
+	case 1:
+		service = services.ordered[0]
+	default:
+		reasons = append(reasons, DirectCallMultiService)
+	}
+
+	metadata := DefaultMetadataConfig.processSelectionSetMetadata(
+		operation.SelectionSet, nil, new(_aliasFields), make(map[string]bool))
+	if metadata.HasSideBySideFields {
+		reasons = append(reasons, DirectCallSideBySide)
+	}
+	if metadata.HasCanaryFields {
+		reasons = append(reasons, DirectCallCanary)
+	}
+	if metadata.HasMixedAliases {
+		reasons = append(reasons, DirectCallMixedAlias)
+	}
+
+	oldFields := make(map[FieldRef]bool, len(renames))
+	for newField, oldName := range renames {
+		oldFields[FieldRef{TypeName: newField.TypeName, FieldName: oldName}] = true
+	}
+	if selectsAnyField(operation.SelectionSet, oldFields) {
+		reasons = append(reasons, DirectCallUsesOldName)
+	}
+
+	if len(reasons) > 0 {
+		return "", false, reasons, nil
+	}
+	return service, true, nil, nil
+}
+
+// selectsAnyField returns whether the given selection set (including fields
+// in fragments and inline fragments recursively) selects any of the given
+// fields.
+func selectsAnyField(selectionSet ast.SelectionSet, fields map[FieldRef]bool) bool {
+	for _, selection := range selectionSet {
+		switch v := selection.(type) {
+		case *ast.Field:
+			if fields[FieldRef{TypeName: v.ObjectDefinition.Name, FieldName: v.Name}] ||
+				selectsAnyField(v.SelectionSet, fields) {
+				return true
+			}
+		case *ast.FragmentSpread:
+			if selectsAnyField(v.Definition.SelectionSet, fields) {
+				return true
+			}
+		case *ast.InlineFragment:
+			if selectsAnyField(v.SelectionSet, fields) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,270 @@
+package graphqltools
+
+import (
+	"testing"
+
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+
+	"github.com/Khan/webapp/dev/khantest"
+)
+
+const directCallSchema = `
+schema {
+  query: Query
+}
+
+directive @join__graph(name: String!, url: String!) on ENUM_VALUE
+directive @join__owner(graph: join__Graph!) on INTERFACE | OBJECT
+directive @join__type(graph: join__Graph!, key: String) repeatable on INTERFACE | OBJECT
+directive @join__field(graph: join__Graph) on FIELD_DEFINITION
+directive @migrate(from: String!, state: String!) on FIELD_DEFINITION
+
+enum join__Graph {
+  SERVICE_A @join__graph(name: "serviceA", url: "http://service-a")
+  SERVICE_B @join__graph(name: "serviceB", url: "http://service-b")
+}
+
+type Query {
+  classroom: Classroom! @join__field(graph: SERVICE_A)
+}
+
+type Classroom
+  @join__owner(graph: SERVICE_A)
+  @join__type(key: "id", graph: SERVICE_A)
+{
+  id: ID!
+  teacherKaid: String!
+  # The old name of teacherKaid.
+  coachKaid: String!
+  sideBySideField: String! @migrate(from: "python", state: "side-by-side")
+  canaryField: String! @migrate(from: "python", state: "canary")
+  course: Course!
+}
+
+type Course
+  @join__owner(graph: SERVICE_B)
+  @join__type(key: "id", graph: SERVICE_A)
+  @join__type(key: "id", graph: SERVICE_B)
+{
+  id: ID!
+  title: String!
+}
+`
+
+type directCallSuite struct {
+	khantest.Suite
+	schema *ast.Schema
+}
+
+func (suite *directCallSuite) SetupSuite() {
+	suite.Suite.SetupSuite()
+
+	schema, err := gqlparser.LoadSchema(&ast.Source{
+		Name:  "<inline>",
+		Input: directCallSchema,
+	})
+	suite.Require().NoError(err)
+
+	suite.schema = schema
+}
+
+func (suite *directCallSuite) TestSafe() {
+	const query = `
+		query {
+			classroom {
+				id
+				teacherKaid
+				course {
+					# Only the key, which serviceA knows.
+					id
+				}
+			}
+		}
+	`
+
+	service, ok, reasons, err := DirectCallPlan(suite.schema, query, _testRenames)
+	suite.Require().NoError(err)
+
+	suite.Require().True(ok)
+	suite.Require().Equal("serviceA", service)
+	suite.Require().Empty(reasons)
+}
+
+func (suite *directCallSuite) TestMultiService() {
+	const query = `
+		query {
+			classroom {
+				course {
+					title
+				}
+			}
+		}
+	`
+
+	service, ok, reasons, err := DirectCallPlan(suite.schema, query, _testRenames)
+	suite.Require().NoError(err)
+
+	suite.Require().False(ok)
+	suite.Require().Equal("", service)
+	suite.Require().Equal([]string{DirectCallMultiService}, reasons)
+}
+
+func (suite *directCallSuite) TestNoService() {
+	const query = `
+		query {
+			__typename
+		}
+	`
+
+	_, ok, reasons, err := DirectCallPlan(suite.schema, query, _testRenames)
+	suite.Require().NoError(err)
+
+	suite.Require().False(ok)
+	suite.Require().Equal([]string{DirectCallNoService}, reasons)
+}
+
+func (suite *directCallSuite) TestSideBySide() {
+	const query = `
+		query {
+			classroom {
+				sideBySideField
+			}
+		}
+	`
+
+	_, ok, reasons, err := DirectCallPlan(suite.schema, query, _testRenames)
+	suite.Require().NoError(err)
+
+	suite.Require().False(ok)
+	suite.Require().Equal([]string{DirectCallSideBySide}, reasons)
+}
+
+func (suite *directCallSuite) TestCanary() {
+	const query = `
+		query {
+			classroom {
+				canaryField
+			}
+		}
+	`
+
+	_, ok, reasons, err := DirectCallPlan(suite.schema, query, _testRenames)
+	suite.Require().NoError(err)
+
+	suite.Require().False(ok)
+	suite.Require().Equal([]string{DirectCallCanary}, reasons)
+}
+
+func (suite *directCallSuite) TestMixedAlias() {
+	const query = `
+		query {
+			classroom {
+				teacherKaid
+				kaid: teacherKaid
+			}
+		}
+	`
+
+	_, ok, reasons, err := DirectCallPlan(suite.schema, query, _testRenames)
+	suite.Require().NoError(err)
+
+	suite.Require().False(ok)
+	suite.Require().Equal([]string{DirectCallMixedAlias}, reasons)
+}
+
+func (suite *directCallSuite) TestUsesOldName() {
+	const query = `
+		query {
+			classroom {
+				coachKaid
+			}
+		}
+	`
+
+	_, ok, reasons, err := DirectCallPlan(suite.schema, query, _testRenames)
+	suite.Require().NoError(err)
+
+	suite.Require().False(ok)
+	suite.Require().Equal([]string{DirectCallUsesOldName}, reasons)
+}
+
+func (suite *directCallSuite) TestUsesOldNameInFragment() {
+	const query = `
+		query {
+			classroom {
+				...ClassroomFields
+			}
+		}
+
+		fragment ClassroomFields on Classroom {
+			coachKaid
+		}
+	`
+
+	_, ok, reasons, err := DirectCallPlan(suite.schema, query, _testRenames)
+	suite.Require().NoError(err)
+
+	suite.Require().False(ok)
+	suite.Require().Equal([]string{DirectCallUsesOldName}, reasons)
+}
+
+func (suite *directCallSuite) TestOldNameAllowedWithoutRenames() {
+	const query = `
+		query {
+			classroom {
+				coachKaid
+			}
+		}
+	`
+
+	service, ok, _, err := DirectCallPlan(suite.schema, query, nil)
+	suite.Require().NoError(err)
+
+	suite.Require().True(ok)
+	suite.Require().Equal("serviceA", service)
+}
+
+func (suite *directCallSuite) TestMultipleReasons() {
+	const query = `
+		query {
+			classroom {
+				coachKaid
+				canaryField
+				course {
+					title
+				}
+			}
+		}
+	`
+
+	_, ok, reasons, err := DirectCallPlan(suite.schema, query, _testRenames)
+	suite.Require().NoError(err)
+
+	suite.Require().False(ok)
+	suite.Require().Equal([]string{
+		DirectCallMultiService,
+		DirectCallCanary,
+		DirectCallUsesOldName,
+	}, reasons)
+}
+
+func (suite *directCallSuite) TestNotFederated() {
+	schema, err := gqlparser.LoadSchema(&ast.Source{
+		Name:  "<inline>",
+		Input: renamesSchema,
+	})
+	suite.Require().NoError(err)
+
+	_, _, _, err = DirectCallPlan(schema, `query { classroom { id } }`, _testRenames)
+	suite.Require().ErrorIs(err, ErrNotFederated)
+}
+
+func (suite *directCallSuite) TestInvalidQuery() {
+	_, _, _, err := DirectCallPlan(suite.schema, `query { noSuchField }`, _testRenames)
+	suite.Require().Error(err)
+}
+
+func TestDirectCall(t *testing.T) {
+	khantest.Run(t, new(directCallSuite))
+}
@@ -0,0 +1,69 @@
+package graphqltools
+
+// This file contains tools for auditing which directives a schema uses.
+
+import (
+	"sort"
+
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// DirectiveUsages returns the positions of each use of each directive in the
+// given schema, keyed by directive name (without the "@"). It looks at the
+// directives on types, fields (including input fields), arguments of fields
+// and of directive definitions, and enum values. Uses in gqlparser's
+// built-in prelude, e.g. the @deprecated on __Field, aren't included.
+//
+// The positions for each directive are sorted by source, then by offset
+// within the source.
+//
+// Note: gqlparser doesn't keep the directives on the schema definition
+// itself (e.g. `schema @link(...)`), so those aren't included either.
+func DirectiveUsages(schema *ast.Schema) map[string][]ast.Position {
+	usages := make(map[string][]ast.Position)
+	add := func(directives ast.DirectiveList) {
+		for _, directive := range directives {
+			if directive.Position == nil {
+				continue
+			}
+			usages[directive.Name] = append(usages[directive.Name], *directive.Position)
+		}
+	}
+	addArguments := func(arguments ast.ArgumentDefinitionList) {
+		for _, argument := range arguments {
+			add(argument.Directives)
+		}
+	}
+
+	for _, definition := range schema.Types {
+		if definition.BuiltIn {
+			continue
+		}
+		add(definition.Directives)
+		for _, field := range definition.Fields {
+			add(field.Directives)
+			addArguments(field.Arguments)
+		}
+		for _, enumValue := range definition.EnumValues {
+			add(enumValue.Directives)
+		}
+	}
+	for _, directive := range schema.Directives {
+		if directive.Position != nil && directive.Position.Src != nil &&
+			directive.Position.Src.BuiltIn {
+			continue
+		}
+		addArguments(directive.Arguments)
+	}
+
+	for _, positions := range usages {
+		sort.Slice(positions, func(i, j int) bool {
+			a, b := positions[i], positions[j]
+			if a.Src.Name != b.Src.Name {
+				return a.Src.Name < b.Src.Name
+			}
+			return a.Start < b.Start
+		})
+	}
+	return usages
+}
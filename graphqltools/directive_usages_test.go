@@ -0,0 +1,77 @@
+package graphqltools
+
+import (
+	"testing"
+
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+
+	"github.com/Khan/webapp/dev/khantest"
+)
+
+type directiveUsagesSuite struct{ khantest.Suite }
+
+func (suite *directiveUsagesSuite) TestDirectiveUsages() {
+	schema, err := gqlparser.LoadSchema(&ast.Source{
+		Name: "schema.graphql",
+		Input: `directive @owner(team: String!) on OBJECT | ENUM
+directive @cacheControl(maxAge: Int @unstable) on FIELD_DEFINITION
+directive @unstable on FIELD_DEFINITION | ARGUMENT_DEFINITION | ENUM_VALUE | INPUT_FIELD_DEFINITION
+
+type Query @owner(team: "content") {
+  course(id: ID, slug: String @deprecated(reason: "Use id.")): Course @cacheControl(maxAge: 60)
+}
+
+type Course @owner(team: "content") {
+  id: ID!
+  kind: CourseKind @unstable
+}
+
+enum CourseKind @owner(team: "content") {
+  DOMAIN
+  COURSE @unstable
+}
+
+input CourseInput {
+  title: String @unstable
+}
+`,
+	})
+	suite.Require().NoError(err)
+
+	usages := DirectiveUsages(schema)
+
+	counts := make(map[string]int, len(usages))
+	for name, positions := range usages {
+		counts[name] = len(positions)
+	}
+	suite.Require().Equal(map[string]int{
+		"owner":        3,
+		"cacheControl": 1,
+		// On a directive argument, a field, an enum value and an input
+		// field.
+		"unstable":   4,
+		"deprecated": 1,
+	}, counts)
+
+	// Positions are sorted, and point at the uses.
+	var lines []int
+	for _, position := range usages["unstable"] {
+		suite.Require().Equal("schema.graphql", position.Src.Name)
+		lines = append(lines, position.Line)
+	}
+	suite.Require().Equal([]int{2, 11, 16, 20}, lines)
+}
+
+func (suite *directiveUsagesSuite) TestNoDirectives() {
+	schema, err := gqlparser.LoadSchema(&ast.Source{
+		Input: `type Query { name: String }`,
+	})
+	suite.Require().NoError(err)
+
+	suite.Require().Empty(DirectiveUsages(schema))
+}
+
+func TestDirectiveUsages(t *testing.T) {
+	khantest.Run(t, new(directiveUsagesSuite))
+}
@@ -1,12 +1,41 @@
 package graphqltools
 
 // This file contains types related to JSON serialization of operation services
-// and metadata.
+// and metadata, of rename manifests, and of error lists.
 
 type OperationServices struct {
-	From                string   `json:"from"`
-	To                  []string `json:"to"`
-	HasSideBySideFields bool     `json:"hasSideBySideFields"`
-	HasCanaryFields     bool     `json:"hasCanaryFields"`
-	HasMixedAliases     bool     `json:"hasMixedAliases"`
+	From                 string   `json:"from"`
+	To                   []string `json:"to"`
+	HasSideBySideFields  bool     `json:"hasSideBySideFields"`
+	HasCanaryFields      bool     `json:"hasCanaryFields"`
+	HasMixedAliases      bool     `json:"hasMixedAliases"`
+	CrossServiceMutation bool     `json:"crossServiceMutation"`
+}
+
+// RenameManifestEntry is one rename in the manifest returned by
+// Replacer.RenameManifest.
+type RenameManifestEntry struct {
+	// Kind is the kind of the renamed node: a definition kind (e.g. "object"
+	// or "enum") or one of the RenameKind constants.
+	Kind string `json:"kind"`
+	// Parent is the enclosing type of a renamed field, enum value or union
+	// member, or "Type.field" for a renamed argument. It's empty for renamed
+	// definitions.
+	Parent  string `json:"parent,omitempty"`
+	OldName string `json:"oldName"`
+	NewName string `json:"newName"`
+	// OldType and NewType are set if the rename also changed the type.
+	OldType string `json:"oldType,omitempty"`
+	NewType string `json:"newType,omitempty"`
+	// Reason is the reason given for deprecating the old name, via the
+	// `reason` or `reasons` argument of @replaces, if any.
+	Reason string `json:"reason,omitempty"`
+}
+
+// ErrorListEntry is one error in the JSON serialization of an ErrorList.
+type ErrorListEntry struct {
+	Message string `json:"message"`
+	// Fields are the simplerr errors.Fields attached to the error, like the
+	// names of the type and field it's about, if any.
+	Fields map[string]interface{} `json:"fields,omitempty"`
 }
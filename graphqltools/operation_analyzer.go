@@ -0,0 +1,207 @@
+package graphqltools
+
+// This file contains OperationAnalyzer, which analyzes many operations
+// against the same schema.
+
+import (
+	"sort"
+
+	"github.com/StevenACoffman/simplerr/errors"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// OperationAnalyzer analyzes operations against a single composed schema,
+// like ServicesForOperation and MetadataForOperation, but looks up which
+// services own each type and interface field once, up front, rather than
+// once per field per operation. Use it when analyzing many operations
+// against the same (large) schema, e.g. in a build step.
+//
+// An OperationAnalyzer is safe for concurrent use. The schema must not be
+// modified after the analyzer is constructed.
+type OperationAnalyzer struct {
+	schema *ast.Schema
+	// typeOwners maps each object, interface, and union type name to the
+	// services owning it (for abstract types, the owners of each concrete
+	// type); see servicesForType.
+	typeOwners map[string][]string
+	// interfaceFieldOwners maps each interface name, then field name, to
+	// the service owning that field; see serviceForInterfaceField. Fields
+	// whose concrete implementations have different owners are omitted, so
+	// that we report them (by panicking) only if an operation selects them,
+	// as ServicesForOperation does.
+	interfaceFieldOwners map[string]map[string]string
+}
+
+// NewOperationAnalyzer returns an OperationAnalyzer for the given schema. If
+// the schema isn't a composed schema, the analyzer's methods return errors
+// wrapping ErrNotFederated, like the package-level functions.
+func NewOperationAnalyzer(schema *ast.Schema) *OperationAnalyzer {
+	a := &OperationAnalyzer{
+		schema:               schema,
+		typeOwners:           make(map[string][]string),
+		interfaceFieldOwners: make(map[string]map[string]string),
+	}
+	if !isFederatedSchema(schema) {
+		return a
+	}
+
+	for name, definition := range schema.Types {
+		switch definition.Kind {
+		case ast.Object, ast.Union:
+			a.typeOwners[name] = servicesForType(schema, definition)
+		case ast.Interface:
+			a.typeOwners[name] = servicesForType(schema, definition)
+			fieldOwners := make(map[string]string, len(definition.Fields))
+			for _, field := range definition.Fields {
+				service, err := interfaceFieldOwner(schema, definition, field.Name)
+				if err == nil {
+					fieldOwners[field.Name] = service
+				}
+			}
+			a.interfaceFieldOwners[name] = fieldOwners
+		}
+	}
+	return a
+}
+
+// Services is like ServicesForOperation, for the analyzer's schema.
+func (a *OperationAnalyzer) Services(queryText string) ([]string, error) {
+	return a.ServicesWithOptions(queryText, ServicesOptions{})
+}
+
+// ServicesWithOptions is like ServicesForOperationWithOptions, for the
+// analyzer's schema.
+func (a *OperationAnalyzer) ServicesWithOptions(
+	queryText string,
+	options ServicesOptions,
+) ([]string, error) {
+	schema := a.schema
+	if options.AllowDeferAndStream {
+		schema = withDeferAndStream(schema)
+	}
+	operation, err := loadFederatedOperation(schema, queryText, options.AllowUnusedFragments)
+	if err != nil {
+		return nil, err
+	}
+	return a.servicesForOperation(operation, options.InEncounterOrder), nil
+}
+
+// Metadata is like MetadataForOperation, for the analyzer's schema.
+func (a *OperationAnalyzer) Metadata(queryText string) (OperationMetadata, error) {
+	return a.MetadataWithOptions(queryText, MetadataOptions{})
+}
+
+// MetadataWithOptions is like MetadataForOperationWithOptions, for the
+// analyzer's schema. (Unless options.IncludeServices is set, metadata doesn't
+// depend on which services own what, so this is just for convenience.)
+func (a *OperationAnalyzer) MetadataWithOptions(
+	queryText string,
+	options MetadataOptions,
+) (OperationMetadata, error) {
+	if !options.IncludeServices {
+		return MetadataForOperationWithOptions(a.schema, queryText, options)
+	}
+	operation, err := loadFederatedOperation(a.schema, queryText, options.AllowUnusedFragments)
+	if err != nil {
+		return OperationMetadata{}, err
+	}
+	metadata := DefaultMetadataConfig.processSelectionSetMetadata(
+		operation.SelectionSet, nil, new(_aliasFields), make(map[string]bool))
+	metadata.CrossServiceMutation = isCrossServiceMutation(
+		operation, a.servicesForOperation(operation, false))
+	return metadata, nil
+}
+
+// AnalyzeOperation returns the services used by, and the metadata of, the
+// operation in the given query text, as for ServicesForOperation and
+// MetadataForOperation, combined for serialization. from identifies the
+// operation, e.g. by name, and is just copied to the result.
+//
+// It parses the query only once, so is faster than calling both.
+func AnalyzeOperation(schema *ast.Schema, from, queryText string) (OperationServices, error) {
+	return NewOperationAnalyzer(schema).Analyze(from, queryText)
+}
+
+// Analyze is like AnalyzeOperation, for the analyzer's schema.
+func (a *OperationAnalyzer) Analyze(from, queryText string) (OperationServices, error) {
+	operation, err := loadFederatedOperation(a.schema, queryText, false)
+	if err != nil {
+		return OperationServices{}, err
+	}
+	metadata := DefaultMetadataConfig.processSelectionSetMetadata(
+		operation.SelectionSet, nil, new(_aliasFields), make(map[string]bool))
+	services := a.servicesForOperation(operation, false)
+	return OperationServices{
+		From:                 from,
+		To:                   services,
+		HasSideBySideFields:  metadata.HasSideBySideFields,
+		HasCanaryFields:      metadata.HasCanaryFields,
+		HasMixedAliases:      metadata.HasMixedAliases,
+		CrossServiceMutation: isCrossServiceMutation(operation, services),
+	}, nil
+}
+
+// isCrossServiceMutation returns whether the given operation, which uses the
+// given services, is a mutation which uses more than one of them; see
+// OperationMetadata.CrossServiceMutation.
+func isCrossServiceMutation(operation *ast.OperationDefinition, services []string) bool {
+	return operation.Operation == ast.Mutation && len(services) > 1
+}
+
+// OperationServicesFromManifest returns the AnalyzeOperation results for
+// each operation in the given manifest, which maps each operation's name to
+// its query text, e.g. a client build's persisted-query manifest. The results
+// are sorted by name, which is used as their From.
+//
+// If any of the operations can't be processed, it returns an ErrorList with
+// one error per such operation, so callers can report them all at once.
+func OperationServicesFromManifest(
+	schema *ast.Schema,
+	manifest map[string]string,
+) ([]OperationServices, error) {
+	names := make([]string, 0, len(manifest))
+	for name := range manifest {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs ErrorList
+	analyzer := NewOperationAnalyzer(schema)
+	results := make([]OperationServices, 0, len(names))
+	for _, name := range names {
+		result, err := analyzer.Analyze(name, manifest[name])
+		if err != nil {
+			errs = append(errs, errors.WrapWithFields(err, errors.Fields{"operation": name}))
+			continue
+		}
+		results = append(results, result)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return results, nil
+}
+
+// servicesForType is like the package-level function of the same name, but
+// uses the precomputed owners if possible.
+func (a *OperationAnalyzer) servicesForType(objectDefinition *ast.Definition) []string {
+	if services, ok := a.typeOwners[objectDefinition.Name]; ok {
+		return services
+	}
+	return servicesForType(a.schema, objectDefinition)
+}
+
+// serviceForField is like the package-level function of the same name, but
+// uses the precomputed owners of interface fields if possible.
+func (a *OperationAnalyzer) serviceForField(
+	objectDefinition *ast.Definition,
+	fieldDefinition *ast.FieldDefinition,
+) string {
+	if objectDefinition.Kind == ast.Interface {
+		service, ok := a.interfaceFieldOwners[objectDefinition.Name][fieldDefinition.Name]
+		if ok {
+			return service
+		}
+	}
+	return serviceForField(a.schema, objectDefinition, fieldDefinition)
+}
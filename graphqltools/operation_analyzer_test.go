@@ -0,0 +1,216 @@
+package graphqltools
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+
+	"github.com/Khan/webapp/dev/khantest"
+)
+
+type operationAnalyzerSuite struct {
+	khantest.Suite
+	schema *ast.Schema
+}
+
+func (suite *operationAnalyzerSuite) SetupSuite() {
+	suite.Suite.SetupSuite()
+
+	schema, err := _loadTestdataSchema("schema.graphql")
+	suite.Require().NoError(err)
+	suite.schema = schema
+}
+
+// _loadTestdataSchema loads the named schema from testdata.
+func _loadTestdataSchema(name string) (*ast.Schema, error) {
+	schemaContent, err := os.ReadFile(path.Join(khantest.TestdataDir(), name))
+	if err != nil {
+		return nil, err
+	}
+	return gqlparser.LoadSchema(&ast.Source{Name: name, Input: string(schemaContent)})
+}
+
+// _interfaceQuery selects interface fields, which are the most expensive to
+// attribute to services without an OperationAnalyzer.
+const _interfaceQuery = `
+	query {
+		sameServiceOwnerInterface {
+			serviceAField
+			serviceBField
+		}
+		mixedServiceOwnerInterface {
+			mixedOwnershipField
+		}
+	}
+`
+
+func (suite *operationAnalyzerSuite) TestMatchesPackageFunctions() {
+	queries := []string{
+		_interfaceQuery,
+		`query { serviceAThing { name } }`,
+		`query { serviceAFederatedThing { serviceBFederatedThing { id } } }`,
+		`query { serviceBFederatedThing { requiresServiceAField } }`,
+	}
+
+	analyzer := NewOperationAnalyzer(suite.schema)
+	for _, query := range queries {
+		expected, err := ServicesForOperation(suite.schema, query)
+		suite.Require().NoError(err)
+		services, err := analyzer.Services(query)
+		suite.Require().NoError(err)
+		suite.Require().Equal(expected, services, query)
+
+		expectedMetadata, err := MetadataForOperation(suite.schema, query)
+		suite.Require().NoError(err)
+		metadata, err := analyzer.Metadata(query)
+		suite.Require().NoError(err)
+		suite.Require().Equal(expectedMetadata, metadata, query)
+	}
+}
+
+func (suite *operationAnalyzerSuite) TestPrecomputesInterfaceFieldOwners() {
+	analyzer := NewOperationAnalyzer(suite.schema)
+
+	suite.Require().Equal(map[string]string{
+		"id":            "",
+		"serviceAField": "",
+		"serviceBField": "serviceB",
+	}, analyzer.interfaceFieldOwners["SameServiceOwnerInterface"])
+	suite.Require().ElementsMatch([]string{"serviceA", "serviceB"},
+		analyzer.typeOwners["MixedServiceOwnerInterface"])
+}
+
+func (suite *operationAnalyzerSuite) TestNonFederatedSchema() {
+	schema, err := gqlparser.LoadSchema(&ast.Source{
+		Input: `type Query { name: String }`,
+	})
+	suite.Require().NoError(err)
+
+	_, err = NewOperationAnalyzer(schema).Services(`query { name }`)
+	suite.Require().ErrorIs(err, ErrNotFederated)
+}
+
+func (suite *operationAnalyzerSuite) TestAnalyzeOperation() {
+	schema, err := gqlparser.LoadSchema(&ast.Source{
+		Name:  "<inline>",
+		Input: directCallSchema,
+	})
+	suite.Require().NoError(err)
+
+	const query = `
+		query {
+			classroom {
+				sideBySideField
+				course {
+					# Resolved by serviceB.
+					title
+				}
+			}
+		}
+	`
+
+	result, err := AnalyzeOperation(schema, "ClassroomQuery", query)
+	suite.Require().NoError(err)
+	suite.Require().Equal(OperationServices{
+		From:                "ClassroomQuery",
+		To:                  []string{"serviceA", "serviceB"},
+		HasSideBySideFields: true,
+		HasCanaryFields:     false,
+		HasMixedAliases:     false,
+	}, result)
+
+	// It agrees with the separate functions.
+	services, err := ServicesForOperation(schema, query)
+	suite.Require().NoError(err)
+	suite.Require().Equal(services, result.To)
+	metadata, err := MetadataForOperation(schema, query)
+	suite.Require().NoError(err)
+	suite.Require().Equal(metadata.HasSideBySideFields, result.HasSideBySideFields)
+}
+
+func (suite *operationAnalyzerSuite) TestAnalyzeOperationInvalid() {
+	_, err := NewOperationAnalyzer(suite.schema).Analyze("Bad", `query { nonexistentField }`)
+	suite.Require().Error(err)
+}
+
+func (suite *operationAnalyzerSuite) TestOperationServicesFromManifest() {
+	schema, err := gqlparser.LoadSchema(&ast.Source{
+		Name:  "<inline>",
+		Input: directCallSchema,
+	})
+	suite.Require().NoError(err)
+
+	results, err := OperationServicesFromManifest(schema, map[string]string{
+		"TeacherQuery": `query TeacherQuery { classroom { teacherKaid } }`,
+		"CourseQuery":  `query CourseQuery { classroom { canaryField course { title } } }`,
+	})
+	suite.Require().NoError(err)
+	suite.Require().Equal([]OperationServices{
+		{
+			From:            "CourseQuery",
+			To:              []string{"serviceA", "serviceB"},
+			HasCanaryFields: true,
+		},
+		{
+			From: "TeacherQuery",
+			To:   []string{"serviceA"},
+		},
+	}, results)
+}
+
+func (suite *operationAnalyzerSuite) TestOperationServicesFromManifestErrors() {
+	_, err := OperationServicesFromManifest(suite.schema, map[string]string{
+		"Good":  `query Good { serviceAThing { name } }`,
+		"Bad":   `query Bad { nonexistentField }`,
+		"Worse": `query Worse {`,
+	})
+	suite.Require().Error(err)
+
+	var errs ErrorList
+	suite.Require().ErrorAs(err, &errs)
+	suite.Require().Len(errs, 2)
+	suite.Require().Contains(errs[0].Error(), "operation:Bad")
+	suite.Require().Contains(errs[1].Error(), "operation:Worse")
+}
+
+func TestOperationAnalyzer(t *testing.T) {
+	khantest.Run(t, new(operationAnalyzerSuite))
+}
+
+// BenchmarkServicesForOperation and BenchmarkOperationAnalyzer compare
+// analyzing the same operation repeatedly with and without reusing an
+// OperationAnalyzer; the latter avoids rescanning the concrete types of each
+// interface for every interface field selected.
+func BenchmarkServicesForOperation(b *testing.B) {
+	schema, err := _loadTestdataSchema("schema.graphql")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := ServicesForOperation(schema, _interfaceQuery)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkOperationAnalyzer(b *testing.B) {
+	schema, err := _loadTestdataSchema("schema.graphql")
+	if err != nil {
+		b.Fatal(err)
+	}
+	analyzer := NewOperationAnalyzer(schema)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := analyzer.Services(_interfaceQuery)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
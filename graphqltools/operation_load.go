@@ -0,0 +1,205 @@
+package graphqltools
+
+// This file contains helpers for loading the operations analyzed by the other
+// tools in this package.
+
+import (
+	"sort"
+
+	"github.com/StevenACoffman/gqlgen-plugins/errors/kind"
+	"github.com/StevenACoffman/simplerr/errors"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/parser"
+	"github.com/vektah/gqlparser/v2/validator"
+	// Registers the standard validation rules, as gqlparser.LoadQuery does.
+	_ "github.com/vektah/gqlparser/v2/validator/rules"
+)
+
+// loadOperation parses and validates the single operation in the given query
+// text against the given schema, like gqlparser.LoadQuery.
+//
+// If allowUnusedFragments is set, fragments which the operation doesn't use
+// (directly or via other fragments) are dropped before validation, which
+// would otherwise reject them. This is useful for documents which bundle a
+// shared library of fragments.
+func loadOperation(
+	schema *ast.Schema,
+	queryText string,
+	allowUnusedFragments bool,
+) (*ast.OperationDefinition, error) {
+	query, err := loadQuery(schema, queryText, allowUnusedFragments)
+	if err != nil {
+		return nil, err
+	}
+	if len(query.Operations) != 1 {
+		return nil, errors.Wrap(kind.Internal,
+			"each query must contain exactly one operation")
+	}
+	return query.Operations[0], nil
+}
+
+// loadQuery is like loadOperation, but returns the whole query document,
+// which may contain any number of operations.
+func loadQuery(
+	schema *ast.Schema,
+	queryText string,
+	allowUnusedFragments bool,
+) (*ast.QueryDocument, error) {
+	query, err := parser.ParseQuery(&ast.Source{Input: queryText})
+	if err != nil {
+		return nil, err
+	}
+	if allowUnusedFragments {
+		query.Fragments = usedFragments(query)
+	}
+	// We check this before validating, which would reject the same
+	// documents with a less helpful message.
+	if err := checkSubscriptionRoots(schema, query); err != nil {
+		return nil, err
+	}
+	errList := validator.Validate(schema, query)
+	if errList != nil {
+		return nil, errList
+	}
+	return query, nil
+}
+
+// deferAndStreamDirectives are the definitions of @defer and @stream from
+// the incremental delivery RFC:
+// https://github.com/graphql/graphql-spec/pull/742
+var deferAndStreamDirectives = mustParseDirectives(`
+	directive @defer(
+		if: Boolean! = true
+		label: String
+	) on FRAGMENT_SPREAD | INLINE_FRAGMENT
+
+	directive @stream(
+		if: Boolean! = true
+		label: String
+		initialCount: Int = 0
+	) on FIELD
+`)
+
+// mustParseDirectives returns the directive definitions in the given schema
+// source, which must contain only directive definitions.
+func mustParseDirectives(source string) ast.DirectiveDefinitionList {
+	doc, err := parser.ParseSchema(&ast.Source{Name: "<directives>", Input: source})
+	if err != nil {
+		panic(err)
+	}
+	return doc.Directives
+}
+
+// withDeferAndStream returns the given schema, or a shallow copy of it, which
+// declares the @defer and @stream directives, so that operations using them
+// validate. Any existing declarations are kept.
+func withDeferAndStream(schema *ast.Schema) *ast.Schema {
+	updated := *schema
+	updated.Directives = make(map[string]*ast.DirectiveDefinition, len(schema.Directives)+2)
+	for name, directive := range schema.Directives {
+		updated.Directives[name] = directive
+	}
+	for _, directive := range deferAndStreamDirectives {
+		if _, ok := updated.Directives[directive.Name]; !ok {
+			updated.Directives[directive.Name] = directive
+		}
+	}
+	return &updated
+}
+
+// checkSubscriptionRoots returns an InvalidInput error if any subscription in
+// the given (unvalidated) query selects root fields resolved by more than one
+// service, which the gateway doesn't support: each subscription must be
+// served by one service, although it may then fetch nested fields from
+// others as usual. (The GraphQL spec requires subscriptions to have a single
+// root field, which implies this, but we want to say which services are
+// involved.)
+func checkSubscriptionRoots(schema *ast.Schema, query *ast.QueryDocument) error {
+	if schema.Subscription == nil {
+		return nil
+	}
+	for _, operation := range query.Operations {
+		if operation.Operation != ast.Subscription {
+			continue
+		}
+		services := newUniqueServices()
+		visitedFragments := make(map[string]bool)
+		var visit func(selectionSet ast.SelectionSet)
+		visit = func(selectionSet ast.SelectionSet) {
+			for _, selection := range selectionSet {
+				switch v := selection.(type) {
+				case *ast.Field:
+					// Unknown fields are reported by the validator.
+					field := schema.Subscription.Fields.ForName(v.Name)
+					if field == nil {
+						continue
+					}
+					if service := serviceForField(schema, schema.Subscription, field); service != "" {
+						services.add(service)
+					}
+				case *ast.FragmentSpread:
+					if visitedFragments[v.Name] {
+						continue
+					}
+					visitedFragments[v.Name] = true
+					if fragment := query.Fragments.ForName(v.Name); fragment != nil {
+						visit(fragment.SelectionSet)
+					}
+				case *ast.InlineFragment:
+					visit(v.SelectionSet)
+				}
+			}
+		}
+		visit(operation.SelectionSet)
+
+		if len(services.ordered) > 1 {
+			servicesList := services.ordered
+			sort.Strings(servicesList)
+			return errors.WrapWithFields(kind.InvalidInput, errors.Fields{
+				"message":   "subscription root fields must be resolved by a single service",
+				"operation": operation.Name,
+				"services":  servicesList,
+			})
+		}
+	}
+	return nil
+}
+
+// usedFragments returns the fragments in the given (unvalidated) query which
+// are spread into one of its operations, directly or via other fragments, in
+// the order they're defined.
+func usedFragments(query *ast.QueryDocument) ast.FragmentDefinitionList {
+	used := make(map[string]bool)
+	var visit func(selectionSet ast.SelectionSet)
+	visit = func(selectionSet ast.SelectionSet) {
+		for _, selection := range selectionSet {
+			switch v := selection.(type) {
+			case *ast.Field:
+				visit(v.SelectionSet)
+			case *ast.FragmentSpread:
+				if used[v.Name] {
+					continue
+				}
+				used[v.Name] = true
+				// Spreads of undefined fragments are reported by the
+				// validator.
+				if fragment := query.Fragments.ForName(v.Name); fragment != nil {
+					visit(fragment.SelectionSet)
+				}
+			case *ast.InlineFragment:
+				visit(v.SelectionSet)
+			}
+		}
+	}
+	for _, operation := range query.Operations {
+		visit(operation.SelectionSet)
+	}
+
+	var fragments ast.FragmentDefinitionList
+	for _, fragment := range query.Fragments {
+		if used[fragment.Name] {
+			fragments = append(fragments, fragment)
+		}
+	}
+	return fragments
+}
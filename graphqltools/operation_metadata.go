@@ -4,9 +4,11 @@ package graphqltools
 // operation. See the OperationMetadata type for metadata that's available.
 
 import (
+	"sort"
+	"strings"
+
 	"github.com/StevenACoffman/gqlgen-plugins/errors/kind"
 	"github.com/StevenACoffman/simplerr/errors"
-	"github.com/vektah/gqlparser/v2"
 	"github.com/vektah/gqlparser/v2/ast"
 )
 
@@ -23,6 +25,60 @@ type OperationMetadata struct {
 	// Note(marksandstrom) This can be removed once we're using a version of
 	// gqlgen that fixes https://github.com/99designs/gqlgen/issues/1271.
 	HasMixedAliases bool
+	// The distinct (sorted) `from` arguments of the @migrate directives on
+	// side-by-side and canary fields in the operation, e.g. ["python"]; that
+	// is, the systems the operation still depends on.
+	FromSystems []string
+	// The dotted response paths, e.g. "testType.objectField.canaryField", of
+	// the fields in the operation which have canary enabled, in the order
+	// they're selected. HasCanaryFields is set if this is non-empty.
+	CanaryFields []string
+	// The dotted response paths of the fields in the operation which have
+	// side-by-side enabled, like CanaryFields. HasSideBySideFields is set if
+	// this is non-empty.
+	SideBySideFields []string
+	// At least one field or enum value selected by the operation is
+	// @deprecated.
+	UsesDeprecatedFields bool
+	// The distinct (sorted) schema coordinates of the @deprecated fields and
+	// enum values the operation uses, e.g. "Classroom.coachKaid" or
+	// "ContentKind.TOPIC". Enum values are only included if they're written
+	// in the operation, not passed in variables. UsesDeprecatedFields is set
+	// if this is non-empty.
+	DeprecatedFields []string
+	// The depth of the operation's most deeply nested field, e.g. 2 for
+	// `query { testType { id } }`. Fields in fragments count from where the
+	// fragment is spread.
+	MaxDepth int
+	// The number of distinct fields in the operation's response, i.e. of
+	// distinct response paths: a field selected both directly and via a
+	// fragment at the same place counts once.
+	FieldCount int
+	// Set if the operation is a mutation which uses more than one service,
+	// and so isn't applied atomically. This is only computed if
+	// MetadataOptions.IncludeServices is set, since it needs a composed
+	// schema.
+	CrossServiceMutation bool
+}
+
+// merge adds the metadata for other, e.g. for a subselection or fragment, to
+// the given metadata.
+func (metadata *OperationMetadata) merge(other OperationMetadata) {
+	metadata.CanaryFields = _appendMissing(metadata.CanaryFields, other.CanaryFields...)
+	metadata.HasCanaryFields = len(metadata.CanaryFields) > 0
+	metadata.SideBySideFields = _appendMissing(
+		metadata.SideBySideFields, other.SideBySideFields...)
+	metadata.HasSideBySideFields = len(metadata.SideBySideFields) > 0
+	metadata.HasMixedAliases = metadata.HasMixedAliases || other.HasMixedAliases
+	metadata.FromSystems = _mergeSorted(metadata.FromSystems, other.FromSystems)
+	metadata.DeprecatedFields = _mergeSorted(metadata.DeprecatedFields, other.DeprecatedFields)
+	metadata.UsesDeprecatedFields = len(metadata.DeprecatedFields) > 0
+	if other.MaxDepth > metadata.MaxDepth {
+		metadata.MaxDepth = other.MaxDepth
+	}
+	// Each field is counted only by the first selection of its path, so we
+	// can just add.
+	metadata.FieldCount += other.FieldCount
 }
 
 type _aliasFields struct {
@@ -35,22 +91,177 @@ type _aliasFields struct {
 // for operations that must go through the graphql-gateway for reasons other
 // than the services that resolve the operations.
 func MetadataForOperation(schema *ast.Schema, queryText string) (OperationMetadata, error) {
-	query, errList := gqlparser.LoadQuery(schema, queryText)
-	if errList != nil {
-		return OperationMetadata{}, errList
+	return MetadataForOperationWithOptions(schema, queryText, MetadataOptions{})
+}
+
+// MetadataOptions configures MetadataForOperationWithOptions. The zero value
+// gives the behavior of MetadataForOperation.
+type MetadataOptions struct {
+	// AllowUnusedFragments, if set, ignores fragments the operation doesn't
+	// use. By default, like any GraphQL server, we reject documents with
+	// unused fragments, but some documents bundle a shared library of
+	// fragments, only some of which each operation uses.
+	AllowUnusedFragments bool
+	// IncludeServices, if set, also works out which services the operation
+	// uses, as ServicesForOperation does, to set
+	// OperationMetadata.CrossServiceMutation. The schema must then be a
+	// composed schema; if it isn't, we return an error wrapping
+	// ErrNotFederated.
+	IncludeServices bool
+}
+
+// MetadataForOperationWithOptions is like MetadataForOperation, but
+// configurable; see MetadataOptions.
+func MetadataForOperationWithOptions(
+	schema *ast.Schema,
+	queryText string,
+	options MetadataOptions,
+) (OperationMetadata, error) {
+	if options.IncludeServices {
+		return NewOperationAnalyzer(schema).MetadataWithOptions(queryText, options)
 	}
-	if len(query.Operations) != 1 {
-		return OperationMetadata{}, errors.Wrap(kind.Internal, "each query must contain exactly one operation")
+	operation, err := loadOperation(schema, queryText, options.AllowUnusedFragments)
+	if err != nil {
+		return OperationMetadata{}, err
 	}
-	operation := query.Operations[0]
-	return processSelectionSetMetadata(operation.SelectionSet, new(_aliasFields)), nil
+	return DefaultMetadataConfig.processSelectionSetMetadata(
+		operation.SelectionSet, nil, new(_aliasFields), make(map[string]bool)), nil
 }
 
-// selection set (including fields in fragments and inline fragments
-// recursively).
-func processSelectionSetMetadata(
+// MigrationState is a state of a field's migration which is reflected in
+// OperationMetadata; see MetadataConfig.
+type MigrationState int
+
+const (
+	// MigrationCanary marks canary fields: see
+	// OperationMetadata.HasCanaryFields.
+	MigrationCanary MigrationState = iota + 1
+	// MigrationSideBySide marks side-by-side fields: see
+	// OperationMetadata.HasSideBySideFields.
+	MigrationSideBySide
+)
+
+// MetadataConfig describes the directive which marks fields' migration
+// states, for MetadataForOperationWithConfig.
+type MetadataConfig struct {
+	// DirectiveName is the name of the directive, without the "@", e.g.
+	// "migrate".
+	DirectiveName string
+	// StateArgument is the name of the directive's argument giving the
+	// field's state, e.g. "state".
+	StateArgument string
+	// FromArgument is the name of the directive's argument giving the system
+	// the field is being migrated from, for OperationMetadata.FromSystems,
+	// or "" if there is no such argument.
+	FromArgument string
+	// States maps each value of the state argument which is reflected in
+	// OperationMetadata to the corresponding MigrationState, e.g. "canary"
+	// to MigrationCanary. Other values, e.g. "manual", are ignored.
+	States map[string]MigrationState
+}
+
+// DefaultMetadataConfig is the configuration MetadataForOperation uses: for
+//
+//	@migrate(from: String!, state: String!)
+//
+// where state is "manual", "side-by-side", "canary", or "migrated".
+var DefaultMetadataConfig = &MetadataConfig{
+	DirectiveName: "migrate",
+	StateArgument: "state",
+	FromArgument:  "from",
+	States: map[string]MigrationState{
+		"canary":       MigrationCanary,
+		"side-by-side": MigrationSideBySide,
+	},
+}
+
+// MetadataForOperationWithConfig is like MetadataForOperation, but reads
+// fields' migration states from the directive described by the given config.
+// It returns an InvalidInput error if the schema doesn't define the directive
+// or its arguments.
+func MetadataForOperationWithConfig(
+	schema *ast.Schema,
+	queryText string,
+	config *MetadataConfig,
+) (OperationMetadata, error) {
+	if err := config.validate(schema); err != nil {
+		return OperationMetadata{}, err
+	}
+	operation, err := loadOperation(schema, queryText, false)
+	if err != nil {
+		return OperationMetadata{}, err
+	}
+	return config.processSelectionSetMetadata(
+		operation.SelectionSet, nil, new(_aliasFields), make(map[string]bool)), nil
+}
+
+// validate returns an InvalidInput error if the given schema doesn't define
+// the directive and arguments described by the config.
+func (config *MetadataConfig) validate(schema *ast.Schema) error {
+	directive := schema.Directives[config.DirectiveName]
+	if directive == nil {
+		return errors.WrapWithFields(kind.InvalidInput, errors.Fields{
+			"message":   "metadata directive is not defined in the schema",
+			"directive": config.DirectiveName,
+		})
+	}
+	for _, argument := range []string{config.StateArgument, config.FromArgument} {
+		if argument != "" && directive.Arguments.ForName(argument) == nil {
+			return errors.WrapWithFields(kind.InvalidInput, errors.Fields{
+				"message":   "metadata directive has no such argument",
+				"directive": config.DirectiveName,
+				"argument":  argument,
+			})
+		}
+	}
+	return nil
+}
+
+// FromSystemsForManifest returns, for each system (the `from` argument of an
+// @migrate directive) that operations in the given manifest depend on via
+// side-by-side or canary fields, the (sorted) keys of those operations. The
+// manifest maps each operation's key, e.g. its persisted-query hash, to its
+// query text. This is useful for planning the shutdown of a legacy system.
+//
+// If any of the operations can't be processed, it returns an ErrorList with
+// one error per such operation, so callers can report them all at once.
+func FromSystemsForManifest(
+	schema *ast.Schema,
+	manifest map[string]string,
+) (map[string][]string, error) {
+	keys := make([]string, 0, len(manifest))
+	for key := range manifest {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var errs ErrorList
+	operationsBySystem := make(map[string][]string)
+	for _, key := range keys {
+		metadata, err := MetadataForOperation(schema, manifest[key])
+		if err != nil {
+			errs = append(errs, errors.WrapWithFields(err, errors.Fields{"operation": key}))
+			continue
+		}
+		for _, system := range metadata.FromSystems {
+			operationsBySystem[system] = append(operationsBySystem[system], key)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return operationsBySystem, nil
+}
+
+// processSelectionSetMetadata returns the metadata for the given selection
+// set, at the given response path (including fields in fragments and inline
+// fragments recursively). countedPaths holds the response paths of the fields
+// already counted in FieldCount, anywhere in the operation.
+func (config *MetadataConfig) processSelectionSetMetadata(
 	selectionSet ast.SelectionSet,
+	path []string,
 	aliasInfo *_aliasFields,
+	countedPaths map[string]bool,
 ) OperationMetadata {
 	var metadata OperationMetadata
 
@@ -59,14 +270,18 @@ func processSelectionSetMetadata(
 		case *ast.Field:
 			var isCanary bool
 			var isSideBySide bool
+			var from string
 
 			for _, directive := range v.Definition.Directives {
-				if directive.Name == "migrate" {
+				if directive.Name == config.DirectiveName {
 					for _, argument := range directive.Arguments {
-						if argument.Name == "state" {
-							isCanary = argument.Value.Raw == "canary"
-							isSideBySide = argument.Value.Raw == "side-by-side"
-							break
+						switch argument.Name {
+						case config.StateArgument:
+							state := config.States[argument.Value.Raw]
+							isCanary = state == MigrationCanary
+							isSideBySide = state == MigrationSideBySide
+						case config.FromArgument:
+							from = argument.Value.Raw
 						}
 					}
 				}
@@ -88,26 +303,40 @@ func processSelectionSetMetadata(
 				aliasInfo.nonAliasFields = append(aliasInfo.nonAliasFields, v.Name)
 			}
 
+			// We copy the path so that sibling fields don't share (and
+			// overwrite) the same backing array.
+			fieldPath := append(path[:len(path):len(path)], v.Alias)
+			responsePath := strings.Join(fieldPath, ".")
+			var fieldMetadata OperationMetadata
+			if isCanary {
+				fieldMetadata.CanaryFields = []string{responsePath}
+			}
+			if isSideBySide {
+				fieldMetadata.SideBySideFields = []string{responsePath}
+			}
+			if (isCanary || isSideBySide) && from != "" {
+				fieldMetadata.FromSystems = []string{from}
+			}
+			fieldMetadata.DeprecatedFields = _deprecatedUses(v)
+			fieldMetadata.MaxDepth = len(fieldPath)
+			if !countedPaths[responsePath] {
+				countedPaths[responsePath] = true
+				fieldMetadata.FieldCount = 1
+			}
+			metadata.merge(fieldMetadata)
+
 			// Each object selection should be analyzed separately for "mixed
 			// aliases", so we create new alias info. Fragment alias info is
 			// combined into the parent object selection info, so new info
 			// isn't created for selections (see below).
-			subselectionMetadata := processSelectionSetMetadata(v.SelectionSet, new(_aliasFields))
-
-			metadata.HasSideBySideFields = isSideBySide ||
-				metadata.HasSideBySideFields ||
-				subselectionMetadata.HasSideBySideFields
-
-			metadata.HasCanaryFields = metadata.HasCanaryFields ||
-				isCanary ||
-				subselectionMetadata.HasCanaryFields
-
-			metadata.HasMixedAliases = metadata.HasMixedAliases ||
-				subselectionMetadata.HasMixedAliases
+			metadata.merge(config.processSelectionSetMetadata(
+				v.SelectionSet, fieldPath, new(_aliasFields), countedPaths))
 		case *ast.FragmentSpread:
-			processSelectionSetMetadata(v.Definition.SelectionSet, aliasInfo)
+			metadata.merge(config.processSelectionSetMetadata(
+				v.Definition.SelectionSet, path, aliasInfo, countedPaths))
 		case *ast.InlineFragment:
-			processSelectionSetMetadata(v.SelectionSet, aliasInfo)
+			metadata.merge(config.processSelectionSetMetadata(
+				v.SelectionSet, path, aliasInfo, countedPaths))
 		}
 	}
 
@@ -117,6 +346,71 @@ func processSelectionSetMetadata(
 	return metadata
 }
 
+// _deprecatedUses returns the (sorted) schema coordinates of the @deprecated
+// field, and enum values in its arguments, used by the given field selection.
+func _deprecatedUses(field *ast.Field) []string {
+	var uses []string
+	if field.Definition.Directives.ForName("deprecated") != nil {
+		uses = append(uses, field.ObjectDefinition.Name+"."+field.Name)
+	}
+
+	var addValue func(value *ast.Value)
+	addValue = func(value *ast.Value) {
+		if value == nil {
+			return
+		}
+		if value.Kind == ast.EnumValue && value.Definition != nil {
+			enumValue := value.Definition.EnumValues.ForName(value.Raw)
+			if enumValue != nil && enumValue.Directives.ForName("deprecated") != nil {
+				uses = _mergeSorted(uses, []string{value.Definition.Name + "." + value.Raw})
+			}
+		}
+		for _, child := range value.Children {
+			addValue(child.Value)
+		}
+	}
+	for _, argument := range field.Arguments {
+		addValue(argument.Value)
+	}
+	return uses
+}
+
+// _appendMissing returns values with each of the given additional values
+// which it doesn't already contain appended.
+func _appendMissing(values []string, additional ...string) []string {
+	for _, value := range additional {
+		found := false
+		for _, existing := range values {
+			if existing == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// _mergeSorted returns the sorted union of the given sorted, de-duplicated
+// slices. It returns nil if both are empty.
+func _mergeSorted(a, b []string) []string {
+	if len(b) == 0 {
+		return a
+	}
+	seen := make(map[string]bool, len(a)+len(b))
+	var merged []string
+	for _, x := range append(append([]string{}, a...), b...) {
+		if !seen[x] {
+			seen[x] = true
+			merged = append(merged, x)
+		}
+	}
+	sort.Strings(merged)
+	return merged
+}
+
 func _hasCommonElement(a, b []string) bool {
 	valueInA := make(map[string]bool, len(a))
 
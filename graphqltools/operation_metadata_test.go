@@ -28,6 +28,14 @@ type TestType {
   sideBySideField: String! @migrate(from: "python", state: "side-by-side")
   canaryField: String! @migrate(from: "python", state: "canary")
   migratedField: String! @migrate(from: "python", state: "migrated")
+  legacyCanaryField: String! @migrate(from: "legacy-go", state: "canary")
+  deprecatedField: String! @deprecated(reason: "Use scalarField.")
+  kindField(kinds: [Kind!]): String!
+}
+
+enum Kind {
+  CURRENT
+  OLD @deprecated(reason: "Use CURRENT.")
 }
 `
 
@@ -64,7 +72,7 @@ func (suite *operationMetadataSuite) TestNoMetadata() {
 	metadata, err := MetadataForOperation(suite.schema, query)
 	suite.Require().NoError(err)
 
-	suite.Require().Equal(OperationMetadata{}, metadata)
+	suite.Require().Equal(OperationMetadata{MaxDepth: 2, FieldCount: 2}, metadata)
 }
 
 func (suite *operationMetadataSuite) TestNoMetadataNested() {
@@ -81,7 +89,7 @@ func (suite *operationMetadataSuite) TestNoMetadataNested() {
 	metadata, err := MetadataForOperation(suite.schema, query)
 	suite.Require().NoError(err)
 
-	suite.Require().Equal(OperationMetadata{}, metadata)
+	suite.Require().Equal(OperationMetadata{MaxDepth: 3, FieldCount: 3}, metadata)
 }
 
 func (suite *operationMetadataSuite) TestNoMetadataManual() {
@@ -96,7 +104,7 @@ func (suite *operationMetadataSuite) TestNoMetadataManual() {
 	metadata, err := MetadataForOperation(suite.schema, query)
 	suite.Require().NoError(err)
 
-	suite.Require().Equal(OperationMetadata{}, metadata)
+	suite.Require().Equal(OperationMetadata{MaxDepth: 2, FieldCount: 2}, metadata)
 }
 
 func (suite *operationMetadataSuite) TestNoMetadataMigrated() {
@@ -111,7 +119,7 @@ func (suite *operationMetadataSuite) TestNoMetadataMigrated() {
 	metadata, err := MetadataForOperation(suite.schema, query)
 	suite.Require().NoError(err)
 
-	suite.Require().Equal(OperationMetadata{}, metadata)
+	suite.Require().Equal(OperationMetadata{MaxDepth: 2, FieldCount: 2}, metadata)
 }
 
 func (suite *operationMetadataSuite) TestHasSideBySide() {
@@ -126,7 +134,13 @@ func (suite *operationMetadataSuite) TestHasSideBySide() {
 	metadata, err := MetadataForOperation(suite.schema, query)
 	suite.Require().NoError(err)
 
-	suite.Require().Equal(OperationMetadata{HasSideBySideFields: true}, metadata)
+	suite.Require().Equal(OperationMetadata{
+		HasSideBySideFields: true,
+		FromSystems:         []string{"python"},
+		SideBySideFields:    []string{"testType.sideBySideField"},
+		MaxDepth:            2,
+		FieldCount:          2,
+	}, metadata)
 }
 
 func (suite *operationMetadataSuite) TestHasSideBySideNested() {
@@ -143,7 +157,13 @@ func (suite *operationMetadataSuite) TestHasSideBySideNested() {
 	metadata, err := MetadataForOperation(suite.schema, query)
 	suite.Require().NoError(err)
 
-	suite.Require().Equal(OperationMetadata{HasSideBySideFields: true}, metadata)
+	suite.Require().Equal(OperationMetadata{
+		HasSideBySideFields: true,
+		FromSystems:         []string{"python"},
+		SideBySideFields:    []string{"testType.objectField.sideBySideField"},
+		MaxDepth:            3,
+		FieldCount:          3,
+	}, metadata)
 }
 
 func (suite *operationMetadataSuite) TestHasCanary() {
@@ -158,7 +178,13 @@ func (suite *operationMetadataSuite) TestHasCanary() {
 	metadata, err := MetadataForOperation(suite.schema, query)
 	suite.Require().NoError(err)
 
-	suite.Require().Equal(OperationMetadata{HasCanaryFields: true}, metadata)
+	suite.Require().Equal(OperationMetadata{
+		HasCanaryFields: true,
+		FromSystems:     []string{"python"},
+		CanaryFields:    []string{"testType.canaryField"},
+		MaxDepth:        2,
+		FieldCount:      2,
+	}, metadata)
 }
 
 func (suite *operationMetadataSuite) TestHasCanaryNested() {
@@ -175,7 +201,290 @@ func (suite *operationMetadataSuite) TestHasCanaryNested() {
 	metadata, err := MetadataForOperation(suite.schema, query)
 	suite.Require().NoError(err)
 
-	suite.Require().Equal(OperationMetadata{HasCanaryFields: true}, metadata)
+	suite.Require().Equal(OperationMetadata{
+		HasCanaryFields: true,
+		FromSystems:     []string{"python"},
+		CanaryFields:    []string{"testType.objectField.canaryField"},
+		MaxDepth:        3,
+		FieldCount:      3,
+	}, metadata)
+}
+
+func (suite *operationMetadataSuite) TestFromSystems() {
+	const query = `
+		query {
+			testType {
+				sideBySideField
+				legacyCanaryField
+				# Neither manual nor migrated fields count.
+				manualField
+				migratedField
+				objectField {
+					canaryField
+				}
+			}
+		}
+	`
+
+	metadata, err := MetadataForOperation(suite.schema, query)
+	suite.Require().NoError(err)
+
+	suite.Require().Equal([]string{"legacy-go", "python"}, metadata.FromSystems)
+}
+
+func (suite *operationMetadataSuite) TestNoFromSystemsManualOrMigrated() {
+	const query = `
+		query {
+			testType {
+				manualField
+				migratedField
+			}
+		}
+	`
+
+	metadata, err := MetadataForOperation(suite.schema, query)
+	suite.Require().NoError(err)
+
+	suite.Require().Nil(metadata.FromSystems)
+}
+
+func (suite *operationMetadataSuite) TestMigrationFieldPaths() {
+	const query = `
+		query {
+			testType {
+				sideBySideField
+				legacyCanaryField
+				manualField
+				objectField {
+					canaryField
+					sideBySideField
+				}
+				other: objectField {
+					canary: canaryField
+				}
+			}
+		}
+	`
+
+	metadata, err := MetadataForOperation(suite.schema, query)
+	suite.Require().NoError(err)
+
+	// Paths are of the response, so use aliases.
+	suite.Require().Equal([]string{
+		"testType.legacyCanaryField",
+		"testType.objectField.canaryField",
+		"testType.other.canary",
+	}, metadata.CanaryFields)
+	suite.Require().Equal([]string{
+		"testType.sideBySideField",
+		"testType.objectField.sideBySideField",
+	}, metadata.SideBySideFields)
+	suite.Require().True(metadata.HasCanaryFields)
+	suite.Require().True(metadata.HasSideBySideFields)
+}
+
+func (suite *operationMetadataSuite) TestCanaryInFragments() {
+	const query = `
+		query {
+			testType {
+				...Fields
+				objectField {
+					... on TestType {
+						sideBySideField
+					}
+				}
+			}
+		}
+
+		fragment Fields on TestType {
+			canaryField
+		}
+	`
+
+	metadata, err := MetadataForOperation(suite.schema, query)
+	suite.Require().NoError(err)
+
+	suite.Require().Equal(OperationMetadata{
+		HasCanaryFields:     true,
+		HasSideBySideFields: true,
+		FromSystems:         []string{"python"},
+		CanaryFields:        []string{"testType.canaryField"},
+		SideBySideFields:    []string{"testType.objectField.sideBySideField"},
+		MaxDepth:            3,
+		FieldCount:          4,
+	}, metadata)
+}
+
+func (suite *operationMetadataSuite) TestCanaryOnlyInNestedFragments() {
+	const query = `
+		query {
+			testType {
+				...Outer
+			}
+		}
+
+		fragment Outer on TestType {
+			objectField {
+				...Inner
+			}
+		}
+
+		fragment Inner on TestType {
+			canaryField
+		}
+	`
+
+	metadata, err := MetadataForOperation(suite.schema, query)
+	suite.Require().NoError(err)
+
+	suite.Require().True(metadata.HasCanaryFields)
+	suite.Require().Equal([]string{"testType.objectField.canaryField"}, metadata.CanaryFields)
+	suite.Require().Equal([]string{"python"}, metadata.FromSystems)
+}
+
+func (suite *operationMetadataSuite) TestUsesDeprecatedField() {
+	const query = `
+		query {
+			testType {
+				scalarField
+				deprecatedField
+			}
+		}
+	`
+
+	metadata, err := MetadataForOperation(suite.schema, query)
+	suite.Require().NoError(err)
+
+	suite.Require().Equal(OperationMetadata{
+		UsesDeprecatedFields: true,
+		DeprecatedFields:     []string{"TestType.deprecatedField"},
+		MaxDepth:             2,
+		FieldCount:           3,
+	}, metadata)
+}
+
+func (suite *operationMetadataSuite) TestUsesDeprecatedFieldInFragment() {
+	const query = `
+		query {
+			testType {
+				...Fields
+				objectField {
+					... on TestType {
+						deprecatedField
+					}
+				}
+			}
+		}
+
+		fragment Fields on TestType {
+			deprecatedField
+		}
+	`
+
+	metadata, err := MetadataForOperation(suite.schema, query)
+	suite.Require().NoError(err)
+
+	suite.Require().True(metadata.UsesDeprecatedFields)
+	suite.Require().Equal([]string{"TestType.deprecatedField"}, metadata.DeprecatedFields)
+}
+
+func (suite *operationMetadataSuite) TestUsesDeprecatedEnumValue() {
+	const query = `
+		query($kinds: [Kind!]) {
+			testType {
+				old: kindField(kinds: [CURRENT, OLD])
+				current: kindField(kinds: [CURRENT])
+				# We can't tell what's in variables.
+				variable: kindField(kinds: $kinds)
+			}
+		}
+	`
+
+	metadata, err := MetadataForOperation(suite.schema, query)
+	suite.Require().NoError(err)
+
+	suite.Require().True(metadata.UsesDeprecatedFields)
+	suite.Require().Equal([]string{"Kind.OLD"}, metadata.DeprecatedFields)
+}
+
+func (suite *operationMetadataSuite) TestDepthAndFieldCount() {
+	const query = `
+		query {
+			testType {
+				id
+				objectField {
+					objectField {
+						scalarField
+					}
+					other: objectField {
+						id
+					}
+				}
+			}
+		}
+	`
+
+	metadata, err := MetadataForOperation(suite.schema, query)
+	suite.Require().NoError(err)
+
+	suite.Require().Equal(4, metadata.MaxDepth)
+	suite.Require().Equal(7, metadata.FieldCount)
+}
+
+func (suite *operationMetadataSuite) TestDepthAndFieldCountInFragment() {
+	const query = `
+		query {
+			testType {
+				id
+				objectField {
+					...Nested
+				}
+			}
+		}
+
+		fragment Nested on TestType {
+			id
+			objectField {
+				objectField {
+					scalarField
+				}
+			}
+		}
+	`
+
+	metadata, err := MetadataForOperation(suite.schema, query)
+	suite.Require().NoError(err)
+
+	// The fragment's fields are counted from testType.objectField, so its
+	// deepest field is testType.objectField.objectField.objectField.scalarField.
+	suite.Require().Equal(5, metadata.MaxDepth)
+	suite.Require().Equal(7, metadata.FieldCount)
+}
+
+func (suite *operationMetadataSuite) TestFieldCountCountsFieldsOnce() {
+	const query = `
+		query {
+			testType {
+				id
+				...Fields
+				... on TestType {
+					id
+					scalarField
+				}
+			}
+		}
+
+		fragment Fields on TestType {
+			id
+			scalarField
+		}
+	`
+
+	metadata, err := MetadataForOperation(suite.schema, query)
+	suite.Require().NoError(err)
+
+	suite.Require().Equal(2, metadata.MaxDepth)
+	suite.Require().Equal(3, metadata.FieldCount)
 }
 
 func (suite *operationMetadataSuite) TestNoMetadataAlias() {
@@ -190,7 +499,7 @@ func (suite *operationMetadataSuite) TestNoMetadataAlias() {
 	metadata, err := MetadataForOperation(suite.schema, query)
 	suite.Require().NoError(err)
 
-	suite.Require().Equal(OperationMetadata{}, metadata)
+	suite.Require().Equal(OperationMetadata{MaxDepth: 2, FieldCount: 2}, metadata)
 }
 
 func (suite *operationMetadataSuite) TestNoMetadataMultipleAliases() {
@@ -206,7 +515,7 @@ func (suite *operationMetadataSuite) TestNoMetadataMultipleAliases() {
 	metadata, err := MetadataForOperation(suite.schema, query)
 	suite.Require().NoError(err)
 
-	suite.Require().Equal(OperationMetadata{}, metadata)
+	suite.Require().Equal(OperationMetadata{MaxDepth: 2, FieldCount: 3}, metadata)
 }
 
 func (suite *operationMetadataSuite) TestHasMixedAliases() {
@@ -222,7 +531,11 @@ func (suite *operationMetadataSuite) TestHasMixedAliases() {
 	metadata, err := MetadataForOperation(suite.schema, query)
 	suite.Require().NoError(err)
 
-	suite.Require().Equal(OperationMetadata{HasMixedAliases: true}, metadata)
+	suite.Require().Equal(OperationMetadata{
+		HasMixedAliases: true,
+		MaxDepth:        2,
+		FieldCount:      3,
+	}, metadata)
 }
 
 func (suite *operationMetadataSuite) TestHasMixedAliasesInFragment() {
@@ -240,7 +553,11 @@ func (suite *operationMetadataSuite) TestHasMixedAliasesInFragment() {
 	metadata, err := MetadataForOperation(suite.schema, query)
 	suite.Require().NoError(err)
 
-	suite.Require().Equal(OperationMetadata{HasMixedAliases: true}, metadata)
+	suite.Require().Equal(OperationMetadata{
+		HasMixedAliases: true,
+		MaxDepth:        2,
+		FieldCount:      3,
+	}, metadata)
 }
 
 func (suite *operationMetadataSuite) TestNoMetadataMixedAliasesAtDifferentLevels() {
@@ -258,7 +575,152 @@ func (suite *operationMetadataSuite) TestNoMetadataMixedAliasesAtDifferentLevels
 	metadata, err := MetadataForOperation(suite.schema, query)
 	suite.Require().NoError(err)
 
-	suite.Require().Equal(OperationMetadata{}, metadata)
+	suite.Require().Equal(OperationMetadata{MaxDepth: 3, FieldCount: 4}, metadata)
+}
+
+func (suite *operationMetadataSuite) TestUnusedFragment() {
+	const query = `
+		query {
+			testType {
+				canaryField
+				...Fields
+			}
+		}
+
+		fragment Fields on TestType {
+			scalarField
+		}
+
+		fragment UnusedFields on TestType {
+			sideBySideField
+		}
+	`
+
+	_, err := MetadataForOperation(suite.schema, query)
+	suite.Require().Error(err)
+
+	metadata, err := MetadataForOperationWithOptions(
+		suite.schema, query, MetadataOptions{AllowUnusedFragments: true})
+	suite.Require().NoError(err)
+
+	// Note the unused fragment's side-by-side field doesn't count.
+	suite.Require().Equal(OperationMetadata{
+		HasCanaryFields: true,
+		FromSystems:     []string{"python"},
+		CanaryFields:    []string{"testType.canaryField"},
+		MaxDepth:        2,
+		FieldCount:      3,
+	}, metadata)
+}
+
+func (suite *operationMetadataSuite) TestFromSystemsForManifest() {
+	manifest := map[string]string{
+		"pythonSideBySide": `query { testType { sideBySideField } }`,
+		"pythonCanary":     `query { testType { objectField { canaryField } } }`,
+		"both":             `query { testType { canaryField legacyCanaryField } }`,
+		"noneManual":       `query { testType { manualField migratedField } }`,
+	}
+
+	operationsBySystem, err := FromSystemsForManifest(suite.schema, manifest)
+	suite.Require().NoError(err)
+
+	suite.Require().Equal(map[string][]string{
+		"legacy-go": {"both"},
+		"python":    {"both", "pythonCanary", "pythonSideBySide"},
+	}, operationsBySystem)
+}
+
+func (suite *operationMetadataSuite) TestFromSystemsForManifestErrors() {
+	manifest := map[string]string{
+		"valid":        `query { testType { canaryField } }`,
+		"unknownField": `query { testType { noSuchField } }`,
+		"syntaxError":  `query {`,
+	}
+
+	_, err := FromSystemsForManifest(suite.schema, manifest)
+	suite.Require().Error(err)
+
+	var errs ErrorList
+	suite.Require().ErrorAs(err, &errs)
+	suite.Require().Len(errs, 2)
+	suite.Require().Contains(errs[0].Error(), "operation:syntaxError")
+	suite.Require().Contains(errs[1].Error(), "operation:unknownField")
+}
+
+const rolloutSchema = `
+directive @rollout(phase: String!) on FIELD_DEFINITION
+
+type Query {
+  plainField: String!
+  darkLaunchField: String! @rollout(phase: "dark-launch")
+  shadowField: String! @rollout(phase: "shadow")
+  doneField: String! @rollout(phase: "done")
+}
+`
+
+var _rolloutConfig = &MetadataConfig{
+	DirectiveName: "rollout",
+	StateArgument: "phase",
+	States: map[string]MigrationState{
+		"dark-launch": MigrationCanary,
+		"shadow":      MigrationSideBySide,
+	},
+}
+
+func (suite *operationMetadataSuite) TestCustomDirective() {
+	schema, err := gqlparser.LoadSchema(&ast.Source{Name: "<inline>", Input: rolloutSchema})
+	suite.Require().NoError(err)
+
+	const query = `
+		query {
+			plainField
+			darkLaunchField
+			shadowField
+			doneField
+		}
+	`
+
+	metadata, err := MetadataForOperationWithConfig(schema, query, _rolloutConfig)
+	suite.Require().NoError(err)
+
+	suite.Require().Equal(OperationMetadata{
+		HasCanaryFields:     true,
+		HasSideBySideFields: true,
+		CanaryFields:        []string{"darkLaunchField"},
+		SideBySideFields:    []string{"shadowField"},
+		MaxDepth:            1,
+		FieldCount:          4,
+	}, metadata)
+
+	// The default config doesn't know about @rollout.
+	metadata, err = MetadataForOperation(schema, query)
+	suite.Require().NoError(err)
+	suite.Require().Equal(OperationMetadata{MaxDepth: 1, FieldCount: 4}, metadata)
+}
+
+func (suite *operationMetadataSuite) TestCustomDirectiveNotInSchema() {
+	_, err := MetadataForOperationWithConfig(
+		suite.schema, `query { testType { id } }`, _rolloutConfig)
+	suite.Require().Error(err)
+	suite.Require().Contains(err.Error(), "metadata directive is not defined in the schema")
+	suite.Require().Contains(err.Error(), "directive:rollout")
+}
+
+func (suite *operationMetadataSuite) TestCustomDirectiveArgumentNotInSchema() {
+	config := *DefaultMetadataConfig
+	config.StateArgument = "phase"
+
+	_, err := MetadataForOperationWithConfig(
+		suite.schema, `query { testType { id } }`, &config)
+	suite.Require().Error(err)
+	suite.Require().Contains(err.Error(), "metadata directive has no such argument")
+	suite.Require().Contains(err.Error(), "argument:phase")
+}
+
+func (suite *operationMetadataSuite) TestIncludeServicesNonFederatedSchema() {
+	_, err := MetadataForOperationWithOptions(
+		suite.schema, `query { testType { id } }`, MetadataOptions{IncludeServices: true})
+	suite.Require().ErrorIs(err, ErrNotFederated)
 }
 
 func TestOperationMetadata(t *testing.T) {
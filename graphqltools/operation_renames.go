@@ -0,0 +1,91 @@
+package graphqltools
+
+// This file contains tools for finding operations which select both a renamed
+// field and its old (@replaces) name.
+
+import (
+	"sort"
+
+	"github.com/StevenACoffman/gqlgen-plugins/errors/kind"
+	"github.com/StevenACoffman/simplerr/errors"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// FieldsSelectedWithOldName returns the renamed fields which the given
+// operation selects under both their new and their old name in the same
+// selection set (including any fragments spread into it), sorted by type and
+// then field name. renames maps each renamed field, by its new name, to its
+// old name.
+//
+// Such operations resolve the same data twice, and if one of the selections
+// is aliased, they may run into the same gqlgen bug as
+// OperationMetadata.HasMixedAliases.
+func FieldsSelectedWithOldName(
+	schema *ast.Schema,
+	queryText string,
+	renames map[FieldRef]string,
+) ([]FieldRef, error) {
+	query, errList := gqlparser.LoadQuery(schema, queryText)
+	if errList != nil {
+		return nil, errList
+	}
+	if len(query.Operations) != 1 {
+		return nil, errors.Wrap(kind.Internal, "each query must contain exactly one operation")
+	}
+
+	found := make(map[FieldRef]bool)
+	processSelectionSetRenames(query.Operations[0].SelectionSet, renames, found)
+
+	fields := make([]FieldRef, 0, len(found))
+	for field := range found {
+		fields = append(fields, field)
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		if fields[i].TypeName != fields[j].TypeName {
+			return fields[i].TypeName < fields[j].TypeName
+		}
+		return fields[i].FieldName < fields[j].FieldName
+	})
+	return fields, nil
+}
+
+// processSelectionSetRenames adds the renamed fields selected under both
+// names in the given selection set, or in any of its subselections, to found.
+func processSelectionSetRenames(
+	selectionSet ast.SelectionSet,
+	renames map[FieldRef]string,
+	found map[FieldRef]bool,
+) {
+	selected := make(map[FieldRef]bool)
+	collectSelectedFields(selectionSet, renames, found, selected)
+
+	for newField, oldName := range renames {
+		oldField := FieldRef{TypeName: newField.TypeName, FieldName: oldName}
+		if selected[newField] && selected[oldField] {
+			found[newField] = true
+		}
+	}
+}
+
+// collectSelectedFields adds the fields selected directly in the given
+// selection set, or in fragments spread into it, to selected. Each field's
+// own subselection is processed separately.
+func collectSelectedFields(
+	selectionSet ast.SelectionSet,
+	renames map[FieldRef]string,
+	found map[FieldRef]bool,
+	selected map[FieldRef]bool,
+) {
+	for _, selection := range selectionSet {
+		switch v := selection.(type) {
+		case *ast.Field:
+			selected[FieldRef{TypeName: v.ObjectDefinition.Name, FieldName: v.Name}] = true
+			processSelectionSetRenames(v.SelectionSet, renames, found)
+		case *ast.FragmentSpread:
+			collectSelectedFields(v.Definition.SelectionSet, renames, found, selected)
+		case *ast.InlineFragment:
+			collectSelectedFields(v.SelectionSet, renames, found, selected)
+		}
+	}
+}
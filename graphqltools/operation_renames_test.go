@@ -0,0 +1,137 @@
+package graphqltools
+
+import (
+	"testing"
+
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+
+	"github.com/Khan/webapp/dev/khantest"
+)
+
+const renamesSchema = `
+schema {
+  query: Query
+}
+
+type Query {
+  classroom: Classroom!
+}
+
+type Classroom {
+  id: ID!
+  teacherKaid: String!
+  coachKaid: String!
+  nested: Classroom!
+}
+`
+
+var _testRenames = map[FieldRef]string{
+	{TypeName: "Classroom", FieldName: "teacherKaid"}: "coachKaid",
+}
+
+type operationRenamesSuite struct {
+	khantest.Suite
+	schema *ast.Schema
+}
+
+func (suite *operationRenamesSuite) SetupSuite() {
+	suite.Suite.SetupSuite()
+
+	schema, err := gqlparser.LoadSchema(&ast.Source{
+		Name:  "<inline>",
+		Input: renamesSchema,
+	})
+	suite.Require().NoError(err)
+
+	suite.schema = schema
+}
+
+func (suite *operationRenamesSuite) TestBothSelected() {
+	const query = `
+		query {
+			classroom {
+				teacherKaid
+				coachKaid
+			}
+		}
+	`
+
+	fields, err := FieldsSelectedWithOldName(suite.schema, query, _testRenames)
+	suite.Require().NoError(err)
+
+	suite.Require().Equal(
+		[]FieldRef{{TypeName: "Classroom", FieldName: "teacherKaid"}}, fields)
+}
+
+func (suite *operationRenamesSuite) TestBothSelectedViaFragment() {
+	const query = `
+		query {
+			classroom {
+				teacherKaid
+				...ClassroomFragment
+			}
+		}
+		fragment ClassroomFragment on Classroom {
+			kaid: coachKaid
+		}
+	`
+
+	fields, err := FieldsSelectedWithOldName(suite.schema, query, _testRenames)
+	suite.Require().NoError(err)
+
+	suite.Require().Equal(
+		[]FieldRef{{TypeName: "Classroom", FieldName: "teacherKaid"}}, fields)
+}
+
+func (suite *operationRenamesSuite) TestOnlyNewNameSelected() {
+	const query = `
+		query {
+			classroom {
+				teacherKaid
+			}
+		}
+	`
+
+	fields, err := FieldsSelectedWithOldName(suite.schema, query, _testRenames)
+	suite.Require().NoError(err)
+
+	suite.Require().Empty(fields)
+}
+
+func (suite *operationRenamesSuite) TestOnlyOldNameSelected() {
+	const query = `
+		query {
+			classroom {
+				coachKaid
+			}
+		}
+	`
+
+	fields, err := FieldsSelectedWithOldName(suite.schema, query, _testRenames)
+	suite.Require().NoError(err)
+
+	suite.Require().Empty(fields)
+}
+
+func (suite *operationRenamesSuite) TestNamesSelectedAtDifferentLevels() {
+	const query = `
+		query {
+			classroom {
+				teacherKaid
+				nested {
+					coachKaid
+				}
+			}
+		}
+	`
+
+	fields, err := FieldsSelectedWithOldName(suite.schema, query, _testRenames)
+	suite.Require().NoError(err)
+
+	suite.Require().Empty(fields)
+}
+
+func TestOperationRenames(t *testing.T) {
+	khantest.Run(t, new(operationRenamesSuite))
+}
@@ -5,89 +5,472 @@ import (
 	"fmt"
 	"github.com/StevenACoffman/gqlgen-plugins/errors/kind"
 	"sort"
+	"strings"
 
-	"github.com/vektah/gqlparser/v2"
 	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/parser"
 
 	"github.com/StevenACoffman/simplerr/errors"
 )
 
+// ErrNotFederated is returned (wrapped) by ServicesForOperation when the
+// schema isn't a composed schema, e.g. a service's own schema used for local
+// testing. Such schemas have no join__Graph enum (nor join__* directives), so
+// we can't tell which services own what.
+var ErrNotFederated = errors.Wrap(kind.InvalidInput,
+	"schema has no federation metadata (no join__Graph enum)")
+
+// ServicesOptions configures ServicesForOperationWithOptions. The zero value
+// gives the behavior of ServicesForOperation.
+type ServicesOptions struct {
+	// InEncounterOrder, if set, returns the services in the order they were
+	// first reached while walking the operation, rather than sorted. This
+	// is useful for visualizations; the order is stable for a given schema
+	// and operation.
+	InEncounterOrder bool
+	// AllowUnusedFragments, if set, ignores fragments the operation doesn't
+	// use, rather than returning a validation error; see MetadataOptions.
+	AllowUnusedFragments bool
+	// AllowDeferAndStream, if set, accepts the @defer and @stream
+	// directives even if the schema doesn't declare them, as supergraph
+	// schemas typically don't: the gateway implements them. Deferred and
+	// streamed selections count like any others, since they still need to
+	// be resolved (just later).
+	AllowDeferAndStream bool
+}
+
 // ServicesForOperation returns the services used to resolve the query in the
-// given query text according to the provided composed schema, i.e. a schema in
-// the CSDL format.
-//
-// Note: the CSDL format is deprecated, but adapting this code to the new
-// "join" format should be straight forward: https://specs.apollo.dev/join.
+// given query text according to the provided composed schema, i.e. a
+// supergraph schema using either v0.1 of the join spec (the deprecated CSDL
+// format) or a later version, up to v0.3: https://specs.apollo.dev/join. If
+// the schema isn't a composed schema, it returns an error wrapping
+// ErrNotFederated.
 func ServicesForOperation(schema *ast.Schema, queryText string) ([]string, error) {
-	query, errList := gqlparser.LoadQuery(schema, queryText)
-	if errList != nil {
-		return nil, errList
-	}
-	if len(query.Operations) != 1 {
-		return nil, errors.Wrap(kind.Internal,
-			"each query must contain exactly one operation")
-	}
-	operation := query.Operations[0]
-	services := processSelectionSet(schema, operation.SelectionSet)
-	servicesList := make([]string, 0, len(services))
-	for service := range services {
-		servicesList = append(servicesList, service)
-	}
-	// Sort the list of services so the return order is deterministic for
-	// tests.
+	return ServicesForOperationWithOptions(schema, queryText, ServicesOptions{})
+}
+
+// ServicesForOperationWithOptions is like ServicesForOperation, but
+// configurable; see ServicesOptions.
+//
+// To analyze many operations against the same schema, use an
+// OperationAnalyzer instead.
+func ServicesForOperationWithOptions(
+	schema *ast.Schema,
+	queryText string,
+	options ServicesOptions,
+) ([]string, error) {
+	return NewOperationAnalyzer(schema).ServicesWithOptions(queryText, options)
+}
+
+// servicesForOperation returns the services used to resolve the given
+// operation, sorted unless inEncounterOrder is set.
+func (a *OperationAnalyzer) servicesForOperation(
+	operation *ast.OperationDefinition,
+	inEncounterOrder bool,
+) []string {
+	services := newUniqueServices()
+	a.processSelectionSet(operation.SelectionSet, nil,
+		func(_ []string, service string) { services.add(service) })
+	servicesList := services.ordered
+	if !inEncounterOrder {
+		// Sort the list of services so the return order is deterministic
+		// for tests.
+		sort.Strings(servicesList)
+	}
+	return servicesList
+}
+
+// ServicesForNamedOperations is like ServicesForOperation, but for a query
+// document containing any number of operations, e.g. a client's .graphql
+// file bundling several named queries. It returns the (sorted) services used
+// by each operation, keyed by operation name; an anonymous operation, which
+// must then be the only one, is keyed by "". Each operation is processed
+// independently, including only the fragments it uses.
+func ServicesForNamedOperations(schema *ast.Schema, queryText string) (map[string][]string, error) {
+	if !isFederatedSchema(schema) {
+		return nil, errors.WithStack(ErrNotFederated)
+	}
+	query, err := loadQuery(schema, queryText, false)
+	if err != nil {
+		return nil, err
+	}
+
+	analyzer := NewOperationAnalyzer(schema)
+	servicesByOperation := make(map[string][]string, len(query.Operations))
+	for _, operation := range query.Operations {
+		servicesByOperation[operation.Name] = analyzer.servicesForOperation(operation, false)
+	}
+	return servicesByOperation, nil
+}
+
+// ServiceAttributionForOperation is like ServicesForOperation, but says which
+// field pulled in each service: it returns a map from the dotted path of each
+// field in the query (e.g. "serviceAFederatedThing.serviceBField") to the
+// (sorted) services needed to resolve it. Paths use response keys, i.e.
+// aliases if present; fields in fragments and inline fragments are under the
+// path of the field containing the spread. Fields which don't need any
+// particular service, e.g. fields of value types, are omitted.
+//
+// This is mostly useful for debugging query plans.
+func ServiceAttributionForOperation(schema *ast.Schema, queryText string) (map[string][]string, error) {
+	operation, err := loadFederatedOperation(schema, queryText, false)
+	if err != nil {
+		return nil, err
+	}
+	servicesByPath := make(map[string]*uniqueServices)
+	NewOperationAnalyzer(schema).processSelectionSet(operation.SelectionSet, nil,
+		func(path []string, service string) {
+			key := strings.Join(path, ".")
+			if servicesByPath[key] == nil {
+				servicesByPath[key] = newUniqueServices()
+			}
+			servicesByPath[key].add(service)
+		})
+
+	attribution := make(map[string][]string, len(servicesByPath))
+	for path, services := range servicesByPath {
+		servicesList := services.ordered
+		sort.Strings(servicesList)
+		attribution[path] = servicesList
+	}
+	return attribution, nil
+}
+
+// loadFederatedOperation is like loadOperation, but returns an error
+// wrapping ErrNotFederated if the schema isn't a composed schema.
+func loadFederatedOperation(
+	schema *ast.Schema,
+	queryText string,
+	allowUnusedFragments bool,
+) (*ast.OperationDefinition, error) {
+	if !isFederatedSchema(schema) {
+		return nil, errors.WithStack(ErrNotFederated)
+	}
+	return loadOperation(schema, queryText, allowUnusedFragments)
+}
+
+// ServicesForOperationGroup returns the (sorted) union of the services used
+// to resolve each of the given queries, e.g. all the operations made by a
+// single route. See ServicesForOperation for details.
+//
+// If any of the queries can't be processed, it returns an ErrorList with one
+// error per such query, so callers can report them all at once.
+func ServicesForOperationGroup(schema *ast.Schema, queries []string) ([]string, error) {
+	if !isFederatedSchema(schema) {
+		return nil, errors.WithStack(ErrNotFederated)
+	}
+
+	var errs ErrorList
+	analyzer := NewOperationAnalyzer(schema)
+	services := newUniqueServices()
+	for i, queryText := range queries {
+		queryServices, err := analyzer.Services(queryText)
+		if err != nil {
+			errs = append(errs, errors.WrapWithFields(err, errors.Fields{"queryIndex": i}))
+			continue
+		}
+		for _, service := range queryServices {
+			services.add(service)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	servicesList := services.ordered
+	sort.Strings(servicesList)
+	return servicesList, nil
+}
+
+// OperationTouchesOnly returns an error if resolving the given query uses any
+// services other than the allowed ones, e.g. to check that an operation
+// never reaches certain services. The error wraps kind.NotAllowed and lists
+// the (sorted) offending services. It returns the errors of
+// ServicesForOperation as is.
+func OperationTouchesOnly(schema *ast.Schema, queryText string, allowed []string) error {
+	services, err := ServicesForOperation(schema, queryText)
+	if err != nil {
+		return err
+	}
+
+	allowedServices := make(map[string]bool, len(allowed))
+	for _, service := range allowed {
+		allowedServices[service] = true
+	}
+	var disallowed []string
+	for _, service := range services {
+		if !allowedServices[service] {
+			disallowed = append(disallowed, service)
+		}
+	}
+	if len(disallowed) > 0 {
+		sort.Strings(disallowed)
+		return errors.WrapWithFields(kind.NotAllowed, errors.Fields{
+			"message":  "operation uses services which aren't allowed",
+			"services": disallowed,
+		})
+	}
+	return nil
+}
+
+// AllServices returns the (sorted) names of all the services composed into
+// the given schema, as given by the @join__graph directives on the values of
+// its join__Graph enum, whether or not any operation uses them. If the schema
+// has no join__Graph enum, it returns a kind.NotFound error.
+func AllServices(schema *ast.Schema) ([]string, error) {
+	if !isFederatedSchema(schema) {
+		return nil, errors.Wrap(kind.NotFound, "schema has no join__Graph enum")
+	}
+
+	services := newUniqueServices()
+	for _, enumValue := range schema.Types["join__Graph"].EnumValues {
+		services.add(serviceNameFromEnum(schema, enumValue.Name))
+	}
+	servicesList := services.ordered
 	sort.Strings(servicesList)
 	return servicesList, nil
 }
 
-type uniqueServices map[string]bool
+// isFederatedSchema returns whether the given schema has the join metadata
+// ServicesForOperation needs to attribute fields to services.
+func isFederatedSchema(schema *ast.Schema) bool {
+	return schema.Types["join__Graph"] != nil
+}
+
+// uniqueServices is a set of services, which remembers the order in which
+// they were added.
+type uniqueServices struct {
+	ordered []string
+	seen    map[string]bool
+}
 
-// processSelectionSet returns service ownership for the fields in the given
-// selection set (including fields in fragments and inline fragments
-// recursively).
-func processSelectionSet(schema *ast.Schema, selectionSet ast.SelectionSet) uniqueServices {
-	services := make(uniqueServices)
+func newUniqueServices() *uniqueServices {
+	return &uniqueServices{ordered: []string{}, seen: make(map[string]bool)}
+}
+
+func (s *uniqueServices) add(service string) {
+	if !s.seen[service] {
+		s.seen[service] = true
+		s.ordered = append(s.ordered, service)
+	}
+}
+
+// processSelectionSet calls addService with each service owning the fields
+// in the given selection set (including fields in fragments and inline
+// fragments recursively), along with the path of the field, starting with
+// the given path of the selection set.
+func (a *OperationAnalyzer) processSelectionSet(
+	selectionSet ast.SelectionSet,
+	path []string,
+	addService func(path []string, service string),
+) {
 	for _, selection := range selectionSet {
 		switch v := selection.(type) {
 		case *ast.Field:
+			// We copy the path so that sibling fields don't share (and
+			// overwrite) the same backing array.
+			fieldPath := append(path[:len(path):len(path)], v.Alias)
 			// We include both the owner(s) of the object the field belongs to
 			// and the owner of the field because when a type is federated the
 			// federation keys and @requires fields are selected by the gateway
 			// and these fields are always owned by the object owner.
-			//
-			// Note that this logic doesn't take into account @provides or
-			// @key directives. A query that exclusively selects @provides
-			// and @key fields doesn't need to communicate with the owning
-			// service. We ignore this case, which is okay for our purposes,
-			// because ignoring it is a conservative assumption (i.e. service
-			// mappings may include services that aren't strictly necessary,
-			// but they'll always include services that are necessary).
-			objectServices := servicesForType(schema, v.ObjectDefinition)
+			objectServices := a.servicesForType(v.ObjectDefinition)
 			for _, service := range objectServices {
-				services[service] = true
+				addService(fieldPath, service)
 			}
-			fieldService := serviceForField(schema, v.ObjectDefinition, v.Definition)
+			fieldService := a.serviceForField(v.ObjectDefinition, v.Definition)
 			if fieldService != "" {
-				services[fieldService] = true
+				addService(fieldPath, fieldService)
+			}
+			// Before resolving a field with @requires, the gateway fetches
+			// the required fields, which may be owned by other services.
+			a.processRequiredFields(v.ObjectDefinition,
+				fieldSetForDirective(v.Definition, "requires"), fieldPath, addService)
+			// If the field returns an entity, and we only select fields the
+			// service resolving this field already knows (a complete key,
+			// plus anything it @provides), the gateway doesn't need to talk
+			// to the entity's owner at all.
+			if selectionCoveredByKey(a.schema, v) {
+				continue
+			}
+			a.processSelectionSet(v.SelectionSet, fieldPath, addService)
+		case *ast.FragmentSpread:
+			a.processSelectionSet(v.Definition.SelectionSet, path, addService)
+		case *ast.InlineFragment:
+			a.processSelectionSet(v.SelectionSet, path, addService)
+		}
+	}
+}
+
+// selectionCoveredByKey returns whether the selection set of the given field
+// only selects fields that are part of one complete federation key of the
+// entity the field returns, plus any fields the field @provides.
+//
+// An entity may have several keys (e.g. "id" and "kaid courseId"); the
+// service resolving the parent field can hand the gateway any one of them,
+// but the selection must fit inside a single key: selecting "id courseId"
+// means talking to the owning service, since no one key contains both.
+func selectionCoveredByKey(schema *ast.Schema, field *ast.Field) bool {
+	if len(field.SelectionSet) == 0 {
+		return false
+	}
+	// Interfaces and unions may have concrete types with different keys; we
+	// don't bother pruning those, which is the conservative choice.
+	entity := schema.Types[field.Definition.Type.Name()]
+	if entity == nil || entity.Kind != ast.Object {
+		return false
+	}
+
+	provided := fieldSetForDirective(field.Definition, "provides")
+	for _, key := range entityKeys(entity) {
+		fieldSet := append(parseFieldSet(key), provided...)
+		if selectionCoveredBy(field.SelectionSet, fieldSet) {
+			return true
+		}
+	}
+	return false
+}
+
+// processRequiredFields calls addService with the services owning each
+// field in the given field set (e.g. that of a @requires directive), resolved
+// against the given object type, along with the given path of the field
+// which needs them.
+func (a *OperationAnalyzer) processRequiredFields(
+	objectDefinition *ast.Definition,
+	fieldSet ast.SelectionSet,
+	path []string,
+	addService func(path []string, service string),
+) {
+	for _, selection := range fieldSet {
+		field, ok := selection.(*ast.Field)
+		if !ok {
+			continue
+		}
+		// Field sets aren't validated against the schema; we just skip
+		// fields we don't know.
+		fieldDefinition := objectDefinition.Fields.ForName(field.Name)
+		if fieldDefinition == nil {
+			continue
+		}
+		if service := a.serviceForField(objectDefinition, fieldDefinition); service != "" {
+			addService(path, service)
+		} else {
+			for _, service := range a.servicesForType(objectDefinition) {
+				addService(path, service)
+			}
+		}
+		if fieldType := a.schema.Types[fieldDefinition.Type.Name()]; fieldType != nil {
+			a.processRequiredFields(fieldType, field.SelectionSet, path, addService)
+		}
+	}
+}
+
+// fieldSetForDirective returns the field set of the given federation
+// directive ("provides" or "requires") on the given field. CSDL schemas may
+// keep the subgraph's directive, e.g. @provides(fields: "..."); otherwise
+// it's the argument of the same name on @join__field. If there are several,
+// their field sets are concatenated.
+func fieldSetForDirective(field *ast.FieldDefinition, name string) ast.SelectionSet {
+	var fieldSet ast.SelectionSet
+	for _, directive := range field.Directives {
+		var argumentName string
+		switch directive.Name {
+		case name:
+			argumentName = "fields"
+		case "join__field":
+			argumentName = name
+		default:
+			continue
+		}
+		if argument := directive.Arguments.ForName(argumentName); argument != nil {
+			fieldSet = append(fieldSet, parseFieldSet(argument.Value.Raw)...)
+		}
+	}
+	return fieldSet
+}
+
+// entityKeys returns the distinct keys of the given entity, as they appear in
+// the "key" argument of its @join__type directives, e.g. "id" or
+// "kaid courseId" or "course { id }".
+func entityKeys(definition *ast.Definition) []string {
+	var keys []string
+	seen := make(map[string]bool)
+	for _, directive := range definition.Directives {
+		if directive.Name == "join__type" {
+			for _, argument := range directive.Arguments {
+				if argument.Name == "key" && !seen[argument.Value.Raw] {
+					seen[argument.Value.Raw] = true
+					keys = append(keys, argument.Value.Raw)
+				}
+			}
+		}
+	}
+	return keys
+}
+
+// parseFieldSet parses a federation field set, like the fields of a key or
+// of @provides, into an (unvalidated) selection set. Field sets may or may
+// not be wrapped in braces, i.e. "id" and "{ id }" are equivalent. If the
+// field set can't be parsed, we return an empty selection set, so that
+// nothing is considered covered by it.
+func parseFieldSet(fieldSet string) ast.SelectionSet {
+	input := strings.TrimSpace(fieldSet)
+	if !strings.HasPrefix(input, "{") {
+		input = "{" + input + "}"
+	}
+	query, err := parser.ParseQuery(&ast.Source{Input: input})
+	if err != nil || len(query.Operations) != 1 {
+		return nil
+	}
+	return query.Operations[0].SelectionSet
+}
+
+// selectionCoveredBy returns whether every field selected by selectionSet
+// (recursively) is also selected by fieldSet.
+func selectionCoveredBy(selectionSet ast.SelectionSet, fieldSet ast.SelectionSet) bool {
+	for _, selection := range selectionSet {
+		switch v := selection.(type) {
+		case *ast.Field:
+			// __typename is always available to the gateway.
+			if strings.HasPrefix(v.Name, "__") {
+				continue
+			}
+			// The same field may appear more than once, e.g. in a key and
+			// in @provides, so we merge their subselections.
+			var found bool
+			var subFieldSet ast.SelectionSet
+			for _, fieldSetSelection := range fieldSet {
+				if f, ok := fieldSetSelection.(*ast.Field); ok && f.Name == v.Name {
+					found = true
+					subFieldSet = append(subFieldSet, f.SelectionSet...)
+				}
 			}
-			for service := range processSelectionSet(schema, v.SelectionSet) {
-				services[service] = true
+			if !found || !selectionCoveredBy(v.SelectionSet, subFieldSet) {
+				return false
 			}
 		case *ast.FragmentSpread:
-			for service := range processSelectionSet(schema, v.Definition.SelectionSet) {
-				services[service] = true
+			if !selectionCoveredBy(v.Definition.SelectionSet, fieldSet) {
+				return false
 			}
 		case *ast.InlineFragment:
-			for service := range processSelectionSet(schema, v.SelectionSet) {
-				services[service] = true
+			if !selectionCoveredBy(v.SelectionSet, fieldSet) {
+				return false
 			}
 		}
 	}
-	return services
+	return true
 }
 
 // serviceForField returns the service indicated by the @join__field
 // directive on the given field, if any. Note: if there is no join__field
 // directive, the field is owned by the object that contains the field.
+//
+// Since v0.2 of the join spec, a field may have several @join__field
+// directives, including ones marking it as external to a service which
+// merely references (or @provides) it; we return the first service which
+// actually resolves the field. Unlike CSDL, those versions also have a
+// @join__field on fields resolved by the object's owner; to match CSDL, we
+// treat those as having none.
 func serviceForField(
 	schema *ast.Schema,
 	objectDefinition *ast.Definition,
@@ -98,11 +481,23 @@ func serviceForField(
 	}
 	for _, directive := range fieldDefinition.Directives {
 		if directive.Name == "join__field" {
-			for _, argument := range directive.Arguments {
-				if argument.Name == "graph" {
-					return serviceNameFromEnum(schema, argument.Value.Raw)
+			external := directive.Arguments.ForName("external")
+			if external != nil && external.Value.Raw == "true" {
+				continue
+			}
+			graph := directive.Arguments.ForName("graph")
+			if graph == nil {
+				continue
+			}
+			service := serviceNameFromEnum(schema, graph.Value.Raw)
+			if !usesJoinOwner(schema) {
+				for _, owner := range servicesForConcreteType(schema, objectDefinition) {
+					if owner == service {
+						return ""
+					}
 				}
 			}
+			return service
 		}
 	}
 	return ""
@@ -117,6 +512,21 @@ func serviceForInterfaceField(
 	objectDefinition *ast.Definition,
 	fieldName string,
 ) string {
+	service, err := interfaceFieldOwner(schema, objectDefinition, fieldName)
+	if err != nil {
+		panic(err.Error())
+	}
+	return service
+}
+
+// interfaceFieldOwner is like serviceForInterfaceField, but returns an error,
+// rather than panicking, if the concrete types' fields have different
+// owners.
+func interfaceFieldOwner(
+	schema *ast.Schema,
+	objectDefinition *ast.Definition,
+	fieldName string,
+) (string, error) {
 	var service string
 	var previousConcreteTypeName string
 	for _, concreteType := range schema.PossibleTypes[objectDefinition.Name] {
@@ -127,7 +537,7 @@ func serviceForInterfaceField(
 			isFirstConcreteType := previousConcreteTypeName == ""
 			serviceForThisType := serviceForField(schema, concreteType, field)
 			if !isFirstConcreteType && serviceForThisType != service {
-				panic(fmt.Sprintf(
+				return "", fmt.Errorf(
 					"%s interface field \"%s\" has concrete "+
 						"implementations owned by different services. "+
 						"The field is owned by the \"%s\" service on %s "+
@@ -138,14 +548,14 @@ func serviceForInterfaceField(
 					previousConcreteTypeName,
 					serviceForThisType,
 					concreteType.Name,
-				))
+				)
 			}
 			service = serviceForThisType
 			previousConcreteTypeName = concreteType.Name
 			break
 		}
 	}
-	return service
+	return service, nil
 }
 
 // Return the service for the given type. The type may be an object, or
@@ -157,32 +567,76 @@ func servicesForType(schema *ast.Schema, objectDefinition *ast.Definition) []str
 	// abstract type is an interface or union. For non-abstract types,
 	// PossibleTypes contains the concrete type itself.
 	for _, concreteType := range schema.PossibleTypes[objectDefinition.Name] {
-		service := serviceForConcreteType(schema, concreteType)
-		if service != "" {
-			services = append(services, service)
-		}
+		services = append(services, servicesForConcreteType(schema, concreteType)...)
 	}
 	return services
 }
 
-// serviceForConcreteType returns the value of the "join__owner"
-// directive on the given type, if one exists. If there is no owner,
-// either the type is owned by a single service or the type is a
-// "value" type. For single-owner types, *some* parent selection
-// should contain an owner. In both the single-owner and "value" type
-// cases no additional service information is available, so this
-// function returns an empty string.
-func serviceForConcreteType(schema *ast.Schema, objectDefinition *ast.Definition) string {
-	for _, directive := range objectDefinition.Directives {
-		if directive.Name == "join__owner" {
-			for _, argument := range directive.Arguments {
-				if argument.Name == "graph" {
-					return serviceNameFromEnum(schema, argument.Value.Raw)
+// usesJoinOwner returns whether the given composed schema uses v0.1 of the
+// join spec (CSDL), where each entity has a single owner given by its
+// @join__owner directive. Later versions dropped @join__owner: each service
+// defining an entity has a @join__type on it, and those which merely extend
+// it say so with extension: true. The schema's @core or @link directives
+// name the version, but gqlparser doesn't keep those, so we go by the
+// directives the schema defines.
+func usesJoinOwner(schema *ast.Schema) bool {
+	_, ok := schema.Directives["join__owner"]
+	return ok
+}
+
+// servicesForConcreteType returns the services owning the given type. In
+// CSDL schemas that's the value of the "join__owner" directive on the type,
+// if one exists. Otherwise, it's each service with a @join__type for one of
+// the type's keys, which doesn't just extend the type. If there is no owner,
+// either the type is owned by a single service or the type is a "value"
+// type. For single-owner types, *some* parent selection should contain an
+// owner. In both the single-owner and "value" type cases no additional
+// service information is available, so this function returns nil.
+func servicesForConcreteType(schema *ast.Schema, objectDefinition *ast.Definition) []string {
+	if usesJoinOwner(schema) {
+		for _, directive := range objectDefinition.Directives {
+			if directive.Name == "join__owner" {
+				if graph := directive.Arguments.ForName("graph"); graph != nil {
+					return []string{serviceNameFromEnum(schema, graph.Value.Raw)}
 				}
 			}
 		}
+		return nil
 	}
-	return ""
+
+	var services []string
+	seen := make(map[string]bool)
+	for _, directive := range objectDefinition.Directives {
+		if directive.Name != "join__type" || directive.Arguments.ForName("key") == nil {
+			continue
+		}
+		extension := directive.Arguments.ForName("extension")
+		resolvable := directive.Arguments.ForName("resolvable")
+		if extension != nil && extension.Value.Raw == "true" ||
+			resolvable != nil && resolvable.Value.Raw == "false" {
+			continue
+		}
+		// There's one @join__type per key, so a service may appear more
+		// than once.
+		graph := directive.Arguments.ForName("graph")
+		if graph != nil && !seen[graph.Value.Raw] {
+			seen[graph.Value.Raw] = true
+			services = append(services, serviceNameFromEnum(schema, graph.Value.Raw))
+		}
+	}
+	return services
+}
+
+// serviceForConcreteType returns the owner of the given type, or, if it has
+// several (which is only possible in join v0.2 and later), the first one;
+// see servicesForConcreteType. If there is no owner, this function returns
+// an empty string.
+func serviceForConcreteType(schema *ast.Schema, objectDefinition *ast.Definition) string {
+	services := servicesForConcreteType(schema, objectDefinition)
+	if len(services) == 0 {
+		return ""
+	}
+	return services[0]
 }
 
 // serviceNameFromEnum maps the service-enum to its name.  The schema
@@ -9,22 +9,28 @@ import (
 	"github.com/vektah/gqlparser/v2/ast"
 
 	"github.com/Khan/webapp/dev/khantest"
+
+	"github.com/StevenACoffman/gqlgen-plugins/errors/kind"
 )
 
 type operationServicesSuite struct {
 	khantest.Suite
-	schema *ast.Schema
+	// schemaFile is the name of the composed schema in testdata to test
+	// against; we run the suite against both a CSDL (join v0.1) schema and a
+	// join v0.3 supergraph of the same services.
+	schemaFile string
+	schema     *ast.Schema
 }
 
 func (suite *operationServicesSuite) SetupSuite() {
 	suite.Suite.SetupSuite()
 
-	schemaPath := path.Join(khantest.TestdataDir(), "schema.graphql")
+	schemaPath := path.Join(khantest.TestdataDir(), suite.schemaFile)
 	schemaContent, err := os.ReadFile(schemaPath)
 	suite.Require().NoError(err)
 
 	source := &ast.Source{
-		Name:  "schema.graphql",
+		Name:  suite.schemaFile,
 		Input: string(schemaContent),
 	}
 
@@ -78,7 +84,7 @@ func (suite *operationServicesSuite) TestFederatedTypeSingleServiceProvidesField
 				serviceBFederatedThing {
 					# Note: serviceA provides this field. That means it isn't
 					# necessary to communicate with serviceB to resolve this
-					# query, though we don't currently support this case.
+					# query.
 					serviceBField
 				}
 			}
@@ -88,8 +94,7 @@ func (suite *operationServicesSuite) TestFederatedTypeSingleServiceProvidesField
 	services, err := ServicesForOperation(suite.schema, query)
 	suite.Require().NoError(err)
 
-	// If we add support for @provides, change this to just "serviceA".
-	suite.Require().Equal([]string{"serviceA", "serviceB"}, services)
+	suite.Require().Equal([]string{"serviceA"}, services)
 }
 
 func (suite *operationServicesSuite) TestFederatedTypeSingleServiceKeyField() {
@@ -99,9 +104,45 @@ func (suite *operationServicesSuite) TestFederatedTypeSingleServiceKeyField() {
 				serviceBFederatedThing {
 					# We only select the key, which is implicitly provided by
 					# serviceA. That means it isn't necessary to communicate
-					# with serviceB to resolve this query, though we don't
-					# currently support this case.
+					# with serviceB to resolve this query.
+					id
+				}
+			}
+		}
+	`
+
+	services, err := ServicesForOperation(suite.schema, query)
+	suite.Require().NoError(err)
+
+	suite.Require().Equal([]string{"serviceA"}, services)
+}
+
+func (suite *operationServicesSuite) TestFederatedTypeSingleServiceKeyAndProvidedFields() {
+	const query = `
+		query {
+			serviceAFederatedThing {
+				serviceBFederatedThing {
 					id
+					serviceBField
+				}
+			}
+		}
+	`
+
+	services, err := ServicesForOperation(suite.schema, query)
+	suite.Require().NoError(err)
+
+	suite.Require().Equal([]string{"serviceA"}, services)
+}
+
+func (suite *operationServicesSuite) TestFederatedTypeProvidedAndUnprovidedFields() {
+	const query = `
+		query {
+			serviceAFederatedThing {
+				serviceBFederatedThing {
+					serviceBField
+					# serviceA doesn't provide this one, so we need serviceB.
+					otherServiceBField
 				}
 			}
 		}
@@ -110,10 +151,133 @@ func (suite *operationServicesSuite) TestFederatedTypeSingleServiceKeyField() {
 	services, err := ServicesForOperation(suite.schema, query)
 	suite.Require().NoError(err)
 
-	// If we add support for key-only selections, change this to "serviceA".
 	suite.Require().Equal([]string{"serviceA", "serviceB"}, services)
 }
 
+func (suite *operationServicesSuite) TestCompoundKeySatisfied() {
+	const query = `
+		query {
+			serviceAFederatedThing {
+				serviceBCompoundKeyThing {
+					# This is exactly the second key.
+					kaid
+					courseId
+				}
+			}
+		}
+	`
+
+	services, err := ServicesForOperation(suite.schema, query)
+	suite.Require().NoError(err)
+
+	suite.Require().Equal([]string{"serviceA"}, services)
+}
+
+func (suite *operationServicesSuite) TestCompoundKeyPartiallySatisfied() {
+	const query = `
+		query {
+			serviceAFederatedThing {
+				serviceBCompoundKeyThing {
+					__typename
+					courseId
+				}
+			}
+		}
+	`
+
+	services, err := ServicesForOperation(suite.schema, query)
+	suite.Require().NoError(err)
+
+	suite.Require().Equal([]string{"serviceA"}, services)
+}
+
+func (suite *operationServicesSuite) TestCompoundKeyFieldsFromDifferentKeys() {
+	const query = `
+		query {
+			serviceAFederatedThing {
+				serviceBCompoundKeyThing {
+					# Each of these is part of a key, but no one key contains
+					# both of them.
+					id
+					courseId
+				}
+			}
+		}
+	`
+
+	services, err := ServicesForOperation(suite.schema, query)
+	suite.Require().NoError(err)
+
+	suite.Require().Equal([]string{"serviceA", "serviceB"}, services)
+}
+
+func (suite *operationServicesSuite) TestCompoundKeyWithNonKeyField() {
+	const query = `
+		query {
+			serviceAFederatedThing {
+				serviceBCompoundKeyThing {
+					kaid
+					courseId
+					serviceBField
+				}
+			}
+		}
+	`
+
+	services, err := ServicesForOperation(suite.schema, query)
+	suite.Require().NoError(err)
+
+	suite.Require().Equal([]string{"serviceA", "serviceB"}, services)
+}
+
+func (suite *operationServicesSuite) TestSelectionCoveredByNestedKey() {
+	// A key like "course { id }" only covers the listed subfields of the
+	// nested object.
+	fieldSet := parseFieldSet("kaid course { id }")
+
+	suite.Require().True(selectionCoveredBy(parseFieldSet("course { id }"), fieldSet))
+	suite.Require().True(selectionCoveredBy(parseFieldSet("kaid course { __typename id }"), fieldSet))
+	suite.Require().False(selectionCoveredBy(parseFieldSet("course { id title }"), fieldSet))
+	suite.Require().False(selectionCoveredBy(parseFieldSet("kaid { id }"), parseFieldSet("{ id }")))
+}
+
+func (suite *operationServicesSuite) TestRequiresFieldFromOtherService() {
+	const query = `
+		query {
+			serviceBFederatedThing {
+				# serviceB resolves this, but requires a field from serviceA.
+				requiresServiceAField
+			}
+		}
+	`
+
+	services, err := ServicesForOperation(suite.schema, query)
+	suite.Require().NoError(err)
+
+	suite.Require().Equal([]string{"serviceA", "serviceB"}, services)
+
+	attribution, err := ServiceAttributionForOperation(suite.schema, query)
+	suite.Require().NoError(err)
+
+	suite.Require().Equal([]string{"serviceA", "serviceB"},
+		attribution["serviceBFederatedThing.requiresServiceAField"])
+}
+
+func (suite *operationServicesSuite) TestWithoutRequires() {
+	const query = `
+		query {
+			serviceBFederatedThing {
+				otherServiceBField
+			}
+		}
+	`
+
+	services, err := ServicesForOperation(suite.schema, query)
+	suite.Require().NoError(err)
+
+	suite.Require().Equal([]string{"serviceB"}, services)
+}
+
 func (suite *operationServicesSuite) TestFederatedTypeMultipleServices() {
 	const query = `
 		query {
@@ -232,6 +396,564 @@ func (suite *operationServicesSuite) TestInterfaceResolvedByNonOwner() {
 	suite.Require().ElementsMatch([]string{"serviceA", "serviceB"}, services)
 }
 
+func (suite *operationServicesSuite) TestInEncounterOrder() {
+	const query = `
+		query {
+			serviceBThing {
+				name
+			}
+			serviceAThing {
+				name
+			}
+		}
+	`
+
+	services, err := ServicesForOperationWithOptions(
+		suite.schema, query, ServicesOptions{InEncounterOrder: true})
+	suite.Require().NoError(err)
+
+	suite.Require().Equal([]string{"serviceB", "serviceA"}, services)
+}
+
+func (suite *operationServicesSuite) TestUnusedFragment() {
+	const query = `
+		query {
+			serviceAThing {
+				...ThingName
+			}
+		}
+
+		fragment ThingName on ServiceAThing {
+			name
+		}
+
+		# This fragment is part of a shared library the operation doesn't use.
+		fragment ThingColor on ServiceBThing {
+			color {
+				name
+			}
+		}
+	`
+
+	_, err := ServicesForOperation(suite.schema, query)
+	suite.Require().Error(err)
+	suite.Require().Contains(err.Error(), "ThingColor")
+
+	services, err := ServicesForOperationWithOptions(
+		suite.schema, query, ServicesOptions{AllowUnusedFragments: true})
+	suite.Require().NoError(err)
+
+	suite.Require().Equal([]string{"serviceA"}, services)
+}
+
+func (suite *operationServicesSuite) TestDeferredInlineFragment() {
+	const query = `
+		query {
+			serviceAThing {
+				name
+			}
+			... @defer(label: "slow") {
+				serviceBFederatedThing {
+					otherServiceBField
+				}
+			}
+		}
+	`
+
+	// The schema doesn't declare @defer, so it's rejected by default.
+	_, err := ServicesForOperation(suite.schema, query)
+	suite.Require().Error(err)
+	suite.Require().Contains(err.Error(), "defer")
+
+	services, err := ServicesForOperationWithOptions(
+		suite.schema, query, ServicesOptions{AllowDeferAndStream: true})
+	suite.Require().NoError(err)
+
+	suite.Require().Equal([]string{"serviceA", "serviceB"}, services)
+}
+
+func (suite *operationServicesSuite) TestDeferredFragmentSpread() {
+	const query = `
+		query {
+			serviceAFederatedThing {
+				id
+				...SlowThing @defer(if: true)
+			}
+		}
+
+		fragment SlowThing on ServiceAFederatedThing {
+			serviceBField {
+				name
+			}
+		}
+	`
+
+	services, err := ServicesForOperationWithOptions(
+		suite.schema, query, ServicesOptions{AllowDeferAndStream: true})
+	suite.Require().NoError(err)
+
+	suite.Require().Equal([]string{"serviceA", "serviceB"}, services)
+}
+
+func (suite *operationServicesSuite) TestStreamedField() {
+	const query = `
+		query {
+			interfaceResolvedByNonOwner @stream(initialCount: 1) {
+				serviceBField
+			}
+		}
+	`
+
+	services, err := ServicesForOperationWithOptions(
+		suite.schema, query, ServicesOptions{AllowDeferAndStream: true})
+	suite.Require().NoError(err)
+
+	suite.Require().Equal([]string{"serviceA", "serviceB"}, services)
+}
+
+func (suite *operationServicesSuite) TestDeferDoesNotModifySchema() {
+	_, err := ServicesForOperationWithOptions(
+		suite.schema, `query { serviceAThing { ... @defer { name } } }`,
+		ServicesOptions{AllowDeferAndStream: true})
+	suite.Require().NoError(err)
+
+	suite.Require().NotContains(suite.schema.Directives, "defer")
+	suite.Require().NotContains(suite.schema.Directives, "stream")
+}
+
+func (suite *operationServicesSuite) TestUnusedFragmentOnlyUsedByUnusedFragment() {
+	const query = `
+		query {
+			serviceAThing {
+				name
+			}
+		}
+
+		fragment Unused on ServiceBThing {
+			...AlsoUnused
+		}
+
+		fragment AlsoUnused on ServiceBThing {
+			name
+		}
+	`
+
+	services, err := ServicesForOperationWithOptions(
+		suite.schema, query, ServicesOptions{AllowUnusedFragments: true})
+	suite.Require().NoError(err)
+
+	suite.Require().Equal([]string{"serviceA"}, services)
+}
+
+func (suite *operationServicesSuite) TestSortedByDefault() {
+	const query = `
+		query {
+			serviceBThing {
+				name
+			}
+			serviceAThing {
+				name
+			}
+		}
+	`
+
+	services, err := ServicesForOperation(suite.schema, query)
+	suite.Require().NoError(err)
+
+	suite.Require().Equal([]string{"serviceA", "serviceB"}, services)
+}
+
+func (suite *operationServicesSuite) TestNonFederatedSchema() {
+	schema, err := gqlparser.LoadSchema(&ast.Source{
+		Name: "<inline>",
+		Input: `
+			type Query {
+				thing: Thing!
+			}
+
+			type Thing {
+				name: String!
+			}
+		`,
+	})
+	suite.Require().NoError(err)
+
+	const query = `
+		query {
+			thing {
+				name
+			}
+		}
+	`
+
+	_, err = ServicesForOperation(schema, query)
+	suite.Require().ErrorIs(err, ErrNotFederated)
+}
+
+func (suite *operationServicesSuite) TestSingleServiceMutation() {
+	const query = `
+		mutation {
+			someMutation
+		}
+	`
+
+	metadata, err := MetadataForOperationWithOptions(
+		suite.schema, query, MetadataOptions{IncludeServices: true})
+	suite.Require().NoError(err)
+	suite.Require().False(metadata.CrossServiceMutation)
+
+	result, err := AnalyzeOperation(suite.schema, "SomeMutation", query)
+	suite.Require().NoError(err)
+	suite.Require().False(result.CrossServiceMutation)
+}
+
+func (suite *operationServicesSuite) TestCrossServiceMutation() {
+	const query = `
+		mutation {
+			someMutation
+			someServiceBMutation
+		}
+	`
+
+	metadata, err := MetadataForOperationWithOptions(
+		suite.schema, query, MetadataOptions{IncludeServices: true})
+	suite.Require().NoError(err)
+	suite.Require().True(metadata.CrossServiceMutation)
+
+	result, err := AnalyzeOperation(suite.schema, "SomeMutations", query)
+	suite.Require().NoError(err)
+	suite.Require().Equal([]string{"serviceA", "serviceB"}, result.To)
+	suite.Require().True(result.CrossServiceMutation)
+
+	// Without IncludeServices, we don't work it out.
+	metadata, err = MetadataForOperation(suite.schema, query)
+	suite.Require().NoError(err)
+	suite.Require().False(metadata.CrossServiceMutation)
+}
+
+func (suite *operationServicesSuite) TestCrossServiceQueryIsNotMutation() {
+	const query = `
+		query {
+			serviceAThing {
+				name
+			}
+			serviceBThing {
+				name
+			}
+		}
+	`
+
+	metadata, err := MetadataForOperationWithOptions(
+		suite.schema, query, MetadataOptions{IncludeServices: true})
+	suite.Require().NoError(err)
+	suite.Require().False(metadata.CrossServiceMutation)
+}
+
+func (suite *operationServicesSuite) TestAllServices() {
+	services, err := AllServices(suite.schema)
+	suite.Require().NoError(err)
+
+	suite.Require().Equal([]string{"serviceA", "serviceB"}, services)
+}
+
+func (suite *operationServicesSuite) TestAllServicesNonFederatedSchema() {
+	schema, err := gqlparser.LoadSchema(&ast.Source{
+		Name:  "<inline>",
+		Input: `type Query { name: String! }`,
+	})
+	suite.Require().NoError(err)
+
+	_, err = AllServices(schema)
+	suite.Require().ErrorIs(err, kind.NotFound)
+}
+
+func (suite *operationServicesSuite) TestOperationTouchesOnlyAllowedServices() {
+	const query = `
+		query {
+			serviceAThing {
+				name
+			}
+		}
+	`
+
+	suite.Require().NoError(OperationTouchesOnly(suite.schema, query, []string{"serviceA"}))
+}
+
+func (suite *operationServicesSuite) TestOperationTouchesDisallowedService() {
+	const query = `
+		query {
+			serviceAThing {
+				name
+			}
+			serviceBThing {
+				name
+			}
+		}
+	`
+
+	err := OperationTouchesOnly(suite.schema, query, []string{"serviceA"})
+	suite.Require().ErrorIs(err, kind.NotAllowed)
+	suite.Require().Contains(err.Error(), "services:[serviceB]")
+}
+
+func (suite *operationServicesSuite) TestNamedOperations() {
+	const query = `
+		query GetServiceAThing {
+			serviceAThing {
+				...ThingName
+			}
+		}
+
+		query GetServiceBThing {
+			serviceBThing {
+				...ThingName
+			}
+		}
+
+		fragment ThingName on ServiceAThing {
+			name
+		}
+	`
+
+	_, err := ServicesForOperation(suite.schema, query)
+	suite.Require().Error(err)
+
+	services, err := ServicesForNamedOperations(suite.schema, query)
+	suite.Require().NoError(err)
+
+	suite.Require().Equal(map[string][]string{
+		"GetServiceAThing": {"serviceA"},
+		"GetServiceBThing": {"serviceB"},
+	}, services)
+}
+
+func (suite *operationServicesSuite) TestNamedOperationsAnonymous() {
+	services, err := ServicesForNamedOperations(
+		suite.schema, `{ serviceAFederatedThing { serviceBField { name } } }`)
+	suite.Require().NoError(err)
+
+	suite.Require().Equal(map[string][]string{
+		"": {"serviceA", "serviceB"},
+	}, services)
+}
+
+func (suite *operationServicesSuite) TestSubscription() {
+	const query = `
+		subscription {
+			serviceAFederatedThingUpdated {
+				# Nested fields may come from other services as usual.
+				serviceBField {
+					name
+				}
+			}
+		}
+	`
+
+	services, err := ServicesForOperation(suite.schema, query)
+	suite.Require().NoError(err)
+
+	suite.Require().Equal([]string{"serviceA", "serviceB"}, services)
+}
+
+func (suite *operationServicesSuite) TestSubscriptionMultiServiceRoot() {
+	const query = `
+		subscription OnUpdate {
+			serviceAThingUpdated {
+				name
+			}
+			serviceBThingUpdated {
+				name
+			}
+		}
+	`
+
+	_, err := ServicesForOperation(suite.schema, query)
+	suite.Require().ErrorIs(err, kind.InvalidInput)
+	suite.Require().Contains(err.Error(), "subscription root fields must be resolved by a single service")
+	suite.Require().Contains(err.Error(), "services:[serviceA serviceB]")
+
+	_, err = ServicesForNamedOperations(suite.schema, query)
+	suite.Require().ErrorIs(err, kind.InvalidInput)
+}
+
+func (suite *operationServicesSuite) TestOperationGroup() {
+	queries := []string{
+		`query { serviceAThing { name } }`,
+		`query { serviceAFederatedThing { serviceAField { name } } }`,
+		`query { serviceAFederatedThing { serviceBField { name } } }`,
+	}
+
+	services, err := ServicesForOperationGroup(suite.schema, queries)
+	suite.Require().NoError(err)
+
+	suite.Require().Equal([]string{"serviceA", "serviceB"}, services)
+}
+
+func (suite *operationServicesSuite) TestOperationGroupSingleService() {
+	queries := []string{
+		`query { serviceAThing { name } }`,
+		`query { serviceAThing { color { name } } }`,
+	}
+
+	services, err := ServicesForOperationGroup(suite.schema, queries)
+	suite.Require().NoError(err)
+
+	suite.Require().Equal([]string{"serviceA"}, services)
+}
+
+func (suite *operationServicesSuite) TestOperationGroupErrors() {
+	queries := []string{
+		`query { serviceAThing { name } }`,
+		`query { noSuchField }`,
+		`query { serviceBThing { alsoNoSuchField } }`,
+	}
+
+	_, err := ServicesForOperationGroup(suite.schema, queries)
+	suite.Require().Error(err)
+
+	var errs ErrorList
+	suite.Require().ErrorAs(err, &errs)
+	suite.Require().Len(errs, 2)
+}
+
+func (suite *operationServicesSuite) TestAttributionFederatedTypeSingleService() {
+	const query = `
+		query {
+			serviceAFederatedThing {
+				serviceAField {
+					name
+				}
+			}
+		}
+	`
+
+	attribution, err := ServiceAttributionForOperation(suite.schema, query)
+	suite.Require().NoError(err)
+
+	suite.Require().Equal(map[string][]string{
+		"serviceAFederatedThing":               {"serviceA"},
+		"serviceAFederatedThing.serviceAField": {"serviceA"},
+	}, attribution)
+}
+
+func (suite *operationServicesSuite) TestAttributionFederatedTypeMultipleServices() {
+	const query = `
+		query {
+			serviceAFederatedThing {
+				serviceAField {
+					name
+				}
+				serviceBField {
+					name
+				}
+			}
+		}
+	`
+
+	attribution, err := ServiceAttributionForOperation(suite.schema, query)
+	suite.Require().NoError(err)
+
+	suite.Require().Equal(map[string][]string{
+		"serviceAFederatedThing":               {"serviceA"},
+		"serviceAFederatedThing.serviceAField": {"serviceA"},
+		"serviceAFederatedThing.serviceBField": {"serviceA", "serviceB"},
+	}, attribution)
+}
+
+func (suite *operationServicesSuite) TestAttributionProvidesField() {
+	const query = `
+		query {
+			serviceAFederatedThing {
+				serviceBFederatedThing {
+					serviceBField
+				}
+			}
+		}
+	`
+
+	attribution, err := ServiceAttributionForOperation(suite.schema, query)
+	suite.Require().NoError(err)
+
+	// serviceA provides serviceBField, so nothing below
+	// serviceBFederatedThing needs serviceB.
+	suite.Require().Equal(map[string][]string{
+		"serviceAFederatedThing":                        {"serviceA"},
+		"serviceAFederatedThing.serviceBFederatedThing": {"serviceA"},
+	}, attribution)
+}
+
+func (suite *operationServicesSuite) TestAttributionCompoundKeyFieldsFromDifferentKeys() {
+	const query = `
+		query {
+			serviceAFederatedThing {
+				serviceBCompoundKeyThing {
+					id
+					courseId
+				}
+			}
+		}
+	`
+
+	attribution, err := ServiceAttributionForOperation(suite.schema, query)
+	suite.Require().NoError(err)
+
+	suite.Require().Equal(map[string][]string{
+		"serviceAFederatedThing":                                   {"serviceA"},
+		"serviceAFederatedThing.serviceBCompoundKeyThing":          {"serviceA"},
+		"serviceAFederatedThing.serviceBCompoundKeyThing.id":       {"serviceB"},
+		"serviceAFederatedThing.serviceBCompoundKeyThing.courseId": {"serviceB"},
+	}, attribution)
+}
+
+func (suite *operationServicesSuite) TestAttributionFragmentSpread() {
+	const query = `
+		query {
+			serviceAFederatedThing {
+				...ThingFields
+			}
+		}
+
+		fragment ThingFields on ServiceAFederatedThing {
+			serviceBField {
+				name
+			}
+		}
+	`
+
+	attribution, err := ServiceAttributionForOperation(suite.schema, query)
+	suite.Require().NoError(err)
+
+	suite.Require().Equal(map[string][]string{
+		"serviceAFederatedThing":               {"serviceA"},
+		"serviceAFederatedThing.serviceBField": {"serviceA", "serviceB"},
+	}, attribution)
+}
+
+func (suite *operationServicesSuite) TestAttributionInlineFragmentWithAlias() {
+	const query = `
+		query {
+			things: sameServiceOwnerInterface {
+				... on SameServiceOwnerConcreteTwo {
+					fieldOnlyInServiceB
+				}
+			}
+		}
+	`
+
+	attribution, err := ServiceAttributionForOperation(suite.schema, query)
+	suite.Require().NoError(err)
+
+	suite.Require().Equal(map[string][]string{
+		"things":                     {"serviceA"},
+		"things.fieldOnlyInServiceB": {"serviceA", "serviceB"},
+	}, attribution)
+}
+
 func TestOperationServices(t *testing.T) {
-	khantest.Run(t, new(operationServicesSuite))
+	t.Run("CSDL", func(t *testing.T) {
+		khantest.Run(t, &operationServicesSuite{schemaFile: "schema.graphql"})
+	})
+	t.Run("JoinV03", func(t *testing.T) {
+		khantest.Run(t, &operationServicesSuite{schemaFile: "supergraph.graphql"})
+	})
 }
@@ -0,0 +1,112 @@
+package graphqltools
+
+// This file contains tools for checking the variables an operation uses.
+
+import (
+	"sort"
+
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"github.com/vektah/gqlparser/v2/parser"
+	"github.com/vektah/gqlparser/v2/validator"
+)
+
+// UndeclaredVariables returns the (sorted, distinct) names, without the "$",
+// of the variables used in the given query text which aren't declared by
+// the operation using them, e.g. "kaid" for
+//
+//	query GetUser { user(kaid: $kaid) { name } }
+//
+// Variables used in fragments count as used by each operation spreading the
+// fragment.
+//
+// gqlparser's validation rejects such queries too, but only with a message
+// per use; this gives callers a list they can report as they like. Any other
+// validation errors are returned as usual.
+func UndeclaredVariables(schema *ast.Schema, queryText string) ([]string, error) {
+	query, err := parser.ParseQuery(&ast.Source{Input: queryText})
+	if err != nil {
+		return nil, err
+	}
+	var errList gqlerror.List
+	for _, err := range validator.Validate(schema, query) {
+		if err.Rule != "NoUndefinedVariables" {
+			errList = append(errList, err)
+		}
+	}
+	if errList != nil {
+		return nil, errList
+	}
+
+	undeclared := make(map[string]bool)
+	for _, operation := range query.Operations {
+		for _, name := range usedVariables(query, operation.SelectionSet) {
+			if operation.VariableDefinitions.ForName(name) == nil {
+				undeclared[name] = true
+			}
+		}
+	}
+
+	names := make([]string, 0, len(undeclared))
+	for name := range undeclared {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// usedVariables returns the names of the variables used in the arguments
+// of the fields and directives in the given selection set, including in the
+// fragments it spreads (recursively). Names may be repeated.
+func usedVariables(query *ast.QueryDocument, selectionSet ast.SelectionSet) []string {
+	var names []string
+	var addValue func(value *ast.Value)
+	addValue = func(value *ast.Value) {
+		if value == nil {
+			return
+		}
+		if value.Kind == ast.Variable {
+			names = append(names, value.Raw)
+		}
+		for _, child := range value.Children {
+			addValue(child.Value)
+		}
+	}
+	addDirectives := func(directives ast.DirectiveList) {
+		for _, directive := range directives {
+			for _, argument := range directive.Arguments {
+				addValue(argument.Value)
+			}
+		}
+	}
+
+	visitedFragments := make(map[string]bool)
+	var visit func(selectionSet ast.SelectionSet)
+	visit = func(selectionSet ast.SelectionSet) {
+		for _, selection := range selectionSet {
+			switch v := selection.(type) {
+			case *ast.Field:
+				for _, argument := range v.Arguments {
+					addValue(argument.Value)
+				}
+				addDirectives(v.Directives)
+				visit(v.SelectionSet)
+			case *ast.FragmentSpread:
+				addDirectives(v.Directives)
+				if visitedFragments[v.Name] {
+					continue
+				}
+				visitedFragments[v.Name] = true
+				if fragment := query.Fragments.ForName(v.Name); fragment != nil {
+					addDirectives(fragment.Directives)
+					visit(fragment.SelectionSet)
+				}
+			case *ast.InlineFragment:
+				addDirectives(v.Directives)
+				visit(v.SelectionSet)
+			}
+		}
+	}
+	visit(selectionSet)
+	return names
+}
@@ -0,0 +1,92 @@
+package graphqltools
+
+import (
+	"testing"
+
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+
+	"github.com/Khan/webapp/dev/khantest"
+)
+
+type operationVariablesSuite struct {
+	khantest.Suite
+	schema *ast.Schema
+}
+
+func (suite *operationVariablesSuite) SetupSuite() {
+	suite.Suite.SetupSuite()
+
+	schema, err := gqlparser.LoadSchema(&ast.Source{
+		Name: "<inline>",
+		Input: `
+			type Query {
+				user(kaid: String, filter: UserFilter): User
+			}
+
+			input UserFilter {
+				locales: [String!]
+			}
+
+			type User {
+				name: String!
+				courses(first: Int): [String!]!
+			}
+		`,
+	})
+	suite.Require().NoError(err)
+	suite.schema = schema
+}
+
+func (suite *operationVariablesSuite) TestUndeclaredVariables() {
+	const query = `
+		query GetUser($kaid: String) {
+			user(kaid: $kaid, filter: {locales: [$locale, "en"]}) {
+				name @include(if: $withName)
+				...UserCourses
+			}
+		}
+
+		fragment UserCourses on User {
+			courses(first: $first)
+		}
+	`
+
+	undeclared, err := UndeclaredVariables(suite.schema, query)
+	suite.Require().NoError(err)
+
+	suite.Require().Equal([]string{"first", "locale", "withName"}, undeclared)
+}
+
+func (suite *operationVariablesSuite) TestAllVariablesDeclared() {
+	const query = `
+		query GetUser($kaid: String, $first: Int) {
+			user(kaid: $kaid) {
+				courses(first: $first)
+			}
+		}
+	`
+
+	undeclared, err := UndeclaredVariables(suite.schema, query)
+	suite.Require().NoError(err)
+
+	suite.Require().Empty(undeclared)
+}
+
+func (suite *operationVariablesSuite) TestOtherValidationErrors() {
+	const query = `
+		query GetUser {
+			user(kaid: $kaid) {
+				noSuchField
+			}
+		}
+	`
+
+	_, err := UndeclaredVariables(suite.schema, query)
+	suite.Require().Error(err)
+	suite.Require().Contains(err.Error(), "noSuchField")
+}
+
+func TestOperationVariables(t *testing.T) {
+	khantest.Run(t, new(operationVariablesSuite))
+}
@@ -19,6 +19,7 @@ package graphqltools
 // directives) and are working just fine as they are.
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/StevenACoffman/gqlgen-plugins/errors/kind"
 	"regexp"
@@ -27,16 +28,42 @@ import (
 
 	"github.com/vektah/gqlparser/v2/ast"
 	"github.com/vektah/gqlparser/v2/formatter"
+	"github.com/vektah/gqlparser/v2/parser"
 
 	"github.com/StevenACoffman/simplerr/errors"
 )
 
 type ReplaceInfo struct {
-	OldName                 string
+	// OldName is the first of OldNames, for the common case where there's
+	// only one.
+	OldName string
+	// OldNames is all the names being replaced: that of the `name` argument,
+	// if present, followed by those of the `names` argument, if present.
+	// Multiple names are useful when a field is renamed more than once, e.g.
+	// locale -> kaLocale -> contentLocale.
+	OldNames                []string
 	OldTypeName             string
 	WasRequiredBeforeRename bool
 	TreatZeroAsUnset        bool
 	TreatZeroAsUnsetPresent bool
+	// Reason, if set, is the reason with which we deprecate the old name(s),
+	// instead of the default "Replaced by <newName>."
+	Reason string
+	// Reasons, if set, is like Reason, but gives a separate reason for each
+	// of OldNames, in the same order, e.g. when a field has accreted several
+	// historical names each deprecated for its own reason. See ReasonFor.
+	Reasons []string
+}
+
+// ReasonFor returns the reason, if any, with which we deprecate the given one
+// of OldNames: the corresponding element of Reasons, if set, or else Reason.
+func (info *ReplaceInfo) ReasonFor(oldName string) string {
+	for i, name := range info.OldNames {
+		if name == oldName && i < len(info.Reasons) {
+			return info.Reasons[i]
+		}
+	}
+	return info.Reason
 }
 
 func GetReplaceInfo(directives ast.DirectiveList) (*ReplaceInfo, error) {
@@ -46,31 +73,92 @@ func GetReplaceInfo(directives ast.DirectiveList) (*ReplaceInfo, error) {
 		return nil, errors.WithStack(kind.NotFound)
 	}
 
-	arg := directive.Arguments.ForName("name")
+	// @replaces isn't repeatable, so the schema validator should prevent
+	// this, but hand-built ASTs can still have more than one.  We don't want
+	// to silently pick the first.
+	if count := len(directives.ForNames("replaces")); count > 1 {
+		return nil, errors.WrapWithFields(kind.Internal, errors.Fields{
+			"message": "@replaces directive may only be used once per node",
+			"count":   count,
+		})
+	}
+
+	replaceInfo := &ReplaceInfo{}
 
-	if arg == nil {
-		// The schema validator should enforce this is present.
-		return nil, errors.Wrap(kind.Internal, "name required on @replaces directive")
+	if arg := directive.Arguments.ForName("name"); arg != nil {
+		replaceInfo.OldNames = append(replaceInfo.OldNames, arg.Value.Raw)
 	}
 
-	replaceInfo := &ReplaceInfo{OldName: arg.Value.Raw}
+	if arg := directive.Arguments.ForName("names"); arg != nil {
+		for _, child := range arg.Value.Children {
+			replaceInfo.OldNames = append(replaceInfo.OldNames, child.Value.Raw)
+		}
+	}
+
+	if len(replaceInfo.OldNames) == 0 {
+		return nil, errors.Wrap(kind.Internal, "name or names required on @replaces directive")
+	}
+
+	seen := make(map[string]bool, len(replaceInfo.OldNames))
+	for _, oldName := range replaceInfo.OldNames {
+		if seen[oldName] {
+			return nil, errors.WrapWithFields(kind.InvalidInput, errors.Fields{
+				"message": "@replaces directive names must be distinct",
+				"oldName": oldName,
+			})
+		}
+		seen[oldName] = true
+	}
 
-	if arg = directive.Arguments.ForName("type"); arg != nil {
+	replaceInfo.OldName = replaceInfo.OldNames[0]
+
+	if arg := directive.Arguments.ForName("type"); arg != nil {
 		replaceInfo.OldTypeName = arg.Value.Raw
 	}
 
-	if arg = directive.Arguments.ForName("wasRequiredBeforeRename"); arg != nil {
+	if arg := directive.Arguments.ForName("wasRequiredBeforeRename"); arg != nil {
 		replaceInfo.WasRequiredBeforeRename = arg.Value.Raw == "true"
 	}
 
-	if arg = directive.Arguments.ForName("treatZeroAsUnset"); arg != nil {
+	if arg := directive.Arguments.ForName("treatZeroAsUnset"); arg != nil {
 		replaceInfo.TreatZeroAsUnset = arg.Value.Raw == "true"
 		replaceInfo.TreatZeroAsUnsetPresent = true
 	}
 
+	if arg := directive.Arguments.ForName("reason"); arg != nil {
+		replaceInfo.Reason = arg.Value.Raw
+	}
+
+	if arg := directive.Arguments.ForName("reasons"); arg != nil {
+		if replaceInfo.Reason != "" {
+			return nil, errors.Wrap(kind.InvalidInput,
+				"@replaces directive can't use both reason and reasons")
+		}
+		for _, child := range arg.Value.Children {
+			replaceInfo.Reasons = append(replaceInfo.Reasons, child.Value.Raw)
+		}
+		if len(replaceInfo.Reasons) != len(replaceInfo.OldNames) {
+			return nil, errors.WrapWithFields(kind.InvalidInput, errors.Fields{
+				"message":  "@replaces directive must give one of reasons per old name",
+				"oldNames": len(replaceInfo.OldNames),
+				"reasons":  len(replaceInfo.Reasons),
+			})
+		}
+	}
+
 	return replaceInfo, nil
 }
 
+// _deprecationReason returns the reason with which to deprecate a name
+// replaced by newName: the given reason, from a @replaces directive, if set,
+// or else "Replaced by <newName>."
+func _deprecationReason(reason string, newName string) string {
+	if reason != "" {
+		return reason
+	}
+	return fmt.Sprintf("Replaced by %s.", newName)
+}
+
 type ErrorList []error
 
 func (e ErrorList) Error() string {
@@ -81,10 +169,50 @@ func (e ErrorList) Error() string {
 	return strings.Join(messages, "\n")
 }
 
+// MarshalJSON serializes the list as an array of ErrorListEntry, for tools
+// which want to annotate the errors, e.g. with the type and field names each
+// is about.
+func (e ErrorList) MarshalJSON() ([]byte, error) {
+	entries := make([]ErrorListEntry, len(e))
+	for i := range e {
+		entries[i] = ErrorListEntry{Message: e[i].Error(), Fields: errors.GetFields(e[i])}
+	}
+	return json.Marshal(entries)
+}
+
 // Replacer holds information about renames in a schema. Call
 // GetReplacesDirectiveUpdates to processes a schema. See that method for more
 // information.
 type Replacer struct {
+	// LineEnding is the line ending used in the schema returned by
+	// GetReplacesDirectiveUpdates: LineEndingLF (the default, if empty) or
+	// LineEndingCRLF.
+	LineEnding string
+
+	// GoFieldPrefix is prepended to the (title-cased) old name of each
+	// renamed field to get the Go name in its @goField directive, e.g.
+	// "DeprecatedLocale" for the old field "locale". NewReplacer sets it to
+	// DefaultGoFieldPrefix.
+	GoFieldPrefix string
+
+	// OmitGoField omits the @goField directive from old fields, for schemas
+	// which aren't used with gqlgen, e.g. those only used for federation
+	// composition, where @goField is undefined. GoFieldPrefix is then
+	// unused.
+	OmitGoField bool
+
+	// Strict enables extra validation of @replaces directives which are
+	// valid but likely mistakes: currently, a `type` argument naming the
+	// type the field or argument already has, so nothing is retyped.
+	Strict bool
+
+	// DeprecatedDirectiveOnly marks old names as deprecated with only a
+	// @deprecated directive, without the "Deprecated: Replaced by X."
+	// description, wherever the schema's @deprecated is valid. Elsewhere --
+	// for the standard @deprecated, on all top-level definitions -- we still
+	// use the description, as it's the only way to mark them deprecated.
+	DeprecatedDirectiveOnly bool
+
 	// Errors collected while performing renames. Returned by
 	// GetReplacesDirectiveUpdates after all @replaces directives have been
 	// processed.
@@ -93,7 +221,8 @@ type Replacer struct {
 	// A map from (new) object name to fields being renamed on that object.
 	fields map[string][]_fieldInfo
 	// All the top-level definitions with names being renamed. A top-level
-	// definition is an object, input object, interface, union or enum.
+	// definition is an object, input object, interface, union, enum or
+	// scalar.
 	definitions []_definitionInfo
 	// A map from (new) enum name to enum values being renamed on the enum.
 	enumValues map[string][]_enumValueInfo
@@ -108,47 +237,116 @@ type Replacer struct {
 
 	// A map from new type names to old type names, for names being renamed.
 	// Includes all renamed definition names.
-	cacheReplacedTypes map[string]string
+	cacheReplacedTypes map[string][]string
 
 	// A map from (new) definition names to definition kinds.
 	definitionKinds map[string]ast.DefinitionKind
 
+	// The old types named by the `type` arguments of @replaces directives on
+	// fields and arguments, to check once we've seen the whole schema.
+	oldTypeRefs []_oldTypeRef
+
 	// A map from (new) object names to the keys on those objects. The keys are
 	// strings as they appear in the "fields" argument of the @key directive,
 	// e.g. "kaid classroomId" or "course { id }".
 	federationKeys map[string][]string
 
+	// The locations on which the schema's @deprecated directive is valid.
+	deprecatedLocations map[ast.DirectiveLocation]bool
+
 	// Set if the replacer has already processed a schema.
 	hasProcessedSchema bool
 }
 
+// DefaultGoFieldPrefix is the default Replacer.GoFieldPrefix.
+const DefaultGoFieldPrefix = "Deprecated"
+
+// ReplacerOption configures a Replacer; see NewReplacerWithOptions.
+type ReplacerOption func(*Replacer)
+
+// WithLineEnding sets Replacer.LineEnding.
+func WithLineEnding(lineEnding string) ReplacerOption {
+	return func(r *Replacer) { r.LineEnding = lineEnding }
+}
+
+// WithGoFieldPrefix sets Replacer.GoFieldPrefix.
+func WithGoFieldPrefix(prefix string) ReplacerOption {
+	return func(r *Replacer) { r.GoFieldPrefix = prefix }
+}
+
+// WithoutGoField sets Replacer.OmitGoField.
+func WithoutGoField() ReplacerOption {
+	return func(r *Replacer) { r.OmitGoField = true }
+}
+
+// WithStrict sets Replacer.Strict.
+func WithStrict() ReplacerOption {
+	return func(r *Replacer) { r.Strict = true }
+}
+
+// WithDeprecatedDirectiveOnly sets Replacer.DeprecatedDirectiveOnly.
+func WithDeprecatedDirectiveOnly() ReplacerOption {
+	return func(r *Replacer) { r.DeprecatedDirectiveOnly = true }
+}
+
+// NewReplacerWithOptions returns a new Replacer with the given options
+// applied.
+func NewReplacerWithOptions(options ...ReplacerOption) *Replacer {
+	r := NewReplacer()
+	for _, option := range options {
+		option(r)
+	}
+	return r
+}
+
 func NewReplacer() *Replacer {
 	return &Replacer{
+		GoFieldPrefix:      DefaultGoFieldPrefix,
 		fields:             make(map[string][]_fieldInfo),
 		enumValues:         make(map[string][]_enumValueInfo),
 		extraImplements:    make(map[string][]string),
 		extraUnionMembers:  make(map[string][]string),
-		cacheReplacedTypes: make(map[string]string),
+		cacheReplacedTypes: make(map[string][]string),
 		definitionKinds:    make(map[string]ast.DefinitionKind),
 		federationKeys:     make(map[string][]string),
+
+		deprecatedLocations: make(map[ast.DirectiveLocation]bool),
 	}
 }
 
 type _definitionInfo struct {
 	definition *ast.Definition
 	oldName    string
+	// The reason for this old name given by the @replaces directive, if
+	// any; see ReplaceInfo.ReasonFor.
+	reason string
 }
 
 type _fieldInfo struct {
 	field       *ast.FieldDefinition
 	oldName     string
 	oldTypeName string
+	// The treatZeroAsUnset argument of the @replaces directive, if any.
+	treatZeroAsUnset bool
+	// The reason for this old name given by the @replaces directive, if
+	// any; see ReplaceInfo.ReasonFor.
+	reason string
+}
+
+// _oldTypeRef is a use of a @replaces directive's `type` argument.
+type _oldTypeRef struct {
+	oldTypeName string
+	// Identifies the field or argument, for errors.
+	fields errors.Fields
 }
 
 type _enumValueInfo struct {
 	enumValue *ast.EnumValueDefinition
 	newName   string
 	oldName   string
+	// The reason for this old name given by the @replaces directive, if
+	// any; see ReplaceInfo.ReasonFor.
+	reason string
 }
 
 // ValidateReplacesDirectives returns an error if any @replaces directive uses
@@ -165,20 +363,617 @@ func ValidateReplacesDirectives(schema *ast.Schema) error {
 	return nil
 }
 
+// FieldRef identifies a field of a type in the schema.
+type FieldRef struct {
+	TypeName  string
+	FieldName string
+}
+
+func (f FieldRef) String() string {
+	return f.TypeName + "." + f.FieldName
+}
+
+// TreatZeroAsUnsetFields returns all the renamed input fields in the given
+// schema whose @replaces directive sets treatZeroAsUnset:true, sorted by type
+// and then field name. The field names are the new names.
+//
+// Such fields have subtle semantics: an explicit zero value (e.g. "" or
+// false) is treated as if the field weren't set at all, so it's worth
+// auditing them from time to time.
+func TreatZeroAsUnsetFields(schema *ast.Schema) ([]FieldRef, error) {
+	replacer := NewReplacer()
+
+	replacer.processSchema(schema)
+
+	if len(replacer.errors) > 0 {
+		return nil, errors.WrapWithFields(kind.InvalidInput, errors.Fields{"errorlist": replacer.errors})
+	}
+
+	var fields []FieldRef
+	for typeName, fieldInfos := range replacer.fields {
+		if replacer.definitionKinds[typeName] != ast.InputObject {
+			continue
+		}
+		for _, fieldInfo := range fieldInfos {
+			if fieldInfo.treatZeroAsUnset {
+				fields = append(fields, FieldRef{TypeName: typeName, FieldName: fieldInfo.field.Name})
+			}
+		}
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		if fields[i].TypeName != fields[j].TypeName {
+			return fields[i].TypeName < fields[j].TypeName
+		}
+		return fields[i].FieldName < fields[j].FieldName
+	})
+	return fields, nil
+}
+
+// RenamedFieldReference is a reference, in the field set of a @key,
+// @requires or @provides directive, to a renamed field by its old name; see
+// RenamedFieldReferences.
+type RenamedFieldReference struct {
+	// Directive is the name of the directive, e.g. "requires".
+	Directive string
+	// On is what the directive is on: a type, for @key, or a field, e.g.
+	// "Student.teacherName", for @requires and @provides.
+	On string
+	// Field is the renamed field, by its new name.
+	Field FieldRef
+	// OldName is the old name by which the directive references the field.
+	OldName string
+	// Position is the position of the directive.
+	Position *ast.Position
+}
+
+func (r RenamedFieldReference) String() string {
+	return fmt.Sprintf("@%s on %s references %s by its old name %q",
+		r.Directive, r.On, r.Field, r.OldName)
+}
+
+// RenamedFieldReferences returns the places in the given schema where the
+// field set of a federation @key, @requires or @provides directive selects
+// a renamed field by its old name, sorted by what the directive is on.
+//
+// The schema additions only rewrite keys of the renamed field's own type,
+// adding a key with the old name alongside the original; other references
+// to the old name, e.g. a @requires on another entity, are left dangling
+// once the old field is removed, so callers should warn about them. (Keys
+// added by the schema additions, if the schema includes them, aren't
+// reported.)
+func RenamedFieldReferences(schema *ast.Schema) ([]RenamedFieldReference, error) {
+	replacer := NewReplacer()
+
+	replacer.processSchema(schema)
+
+	if len(replacer.errors) > 0 {
+		return nil, errors.WrapWithFields(kind.InvalidInput, errors.Fields{"errorlist": replacer.errors})
+	}
+
+	// A map from type name, then old field name, to the renamed field.
+	renamedFields := make(map[string]map[string]*ast.FieldDefinition)
+	for typeName, fieldInfos := range replacer.fields {
+		for _, fieldInfo := range fieldInfos {
+			if renamedFields[typeName] == nil {
+				renamedFields[typeName] = make(map[string]*ast.FieldDefinition)
+			}
+			renamedFields[typeName][fieldInfo.oldName] = fieldInfo.field
+		}
+	}
+
+	var references []RenamedFieldReference
+	var check func(
+		directive *ast.Directive, on string, typeName string, fieldSet ast.SelectionSet)
+	check = func(
+		directive *ast.Directive, on string, typeName string, fieldSet ast.SelectionSet,
+	) {
+		for _, selection := range fieldSet {
+			switch v := selection.(type) {
+			case *ast.Field:
+				var fieldType *ast.Type
+				if field, ok := renamedFields[typeName][v.Name]; ok {
+					references = append(references, RenamedFieldReference{
+						Directive: directive.Name,
+						On:        on,
+						Field:     FieldRef{TypeName: typeName, FieldName: field.Name},
+						OldName:   v.Name,
+						Position:  directive.Position,
+					})
+					fieldType = field.Type
+				} else if definition := schema.Types[typeName]; definition != nil {
+					if field := definition.Fields.ForName(v.Name); field != nil {
+						fieldType = field.Type
+					}
+				}
+				if fieldType != nil {
+					check(directive, on, fieldType.Name(), v.SelectionSet)
+				}
+			case *ast.InlineFragment:
+				check(directive, on, v.TypeCondition, v.SelectionSet)
+			}
+		}
+	}
+
+	for _, definition := range schema.Types {
+		if definition.Kind != ast.Object && definition.Kind != ast.Interface {
+			continue
+		}
+		keys := _getFederationKeys(definition)
+		for _, directive := range definition.Directives.ForNames("key") {
+			fields := directive.Arguments.ForName("fields")
+			if fields == nil || _isRewrittenKey(fields.Value.Raw, keys, renamedFields[definition.Name]) {
+				continue
+			}
+			check(directive, definition.Name, definition.Name, parseFieldSet(fields.Value.Raw))
+		}
+		for _, field := range definition.Fields {
+			on := definition.Name + "." + field.Name
+			for _, directive := range field.Directives {
+				fields := directive.Arguments.ForName("fields")
+				if fields == nil {
+					continue
+				}
+				switch directive.Name {
+				case "requires":
+					check(directive, on, definition.Name, parseFieldSet(fields.Value.Raw))
+				case "provides":
+					check(directive, on, field.Type.Name(), parseFieldSet(fields.Value.Raw))
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(references, func(i, j int) bool {
+		if references[i].On != references[j].On {
+			return references[i].On < references[j].On
+		}
+		return references[i].Directive < references[j].Directive
+	})
+	return references, nil
+}
+
+// _isRewrittenKey returns whether the given key is one the schema additions
+// would add for a key in keys, i.e. whether replacing the old names of the
+// given renamed fields (by old name) in it gives one of keys.
+func _isRewrittenKey(
+	key string,
+	keys []string,
+	renamedFields map[string]*ast.FieldDefinition,
+) bool {
+	original := key
+	for oldName, field := range renamedFields {
+		original, _ = _replaceTopLevelKeyField(original, oldName, field.Name)
+	}
+	if original == key {
+		return false
+	}
+	for _, existing := range keys {
+		if existing == original {
+			return true
+		}
+	}
+	return false
+}
+
+// Line endings supported by Replacer.LineEnding.
+const (
+	LineEndingLF   = "\n"
+	LineEndingCRLF = "\r\n"
+)
+
 // GetReplacesDirectiveUpdates applies any @replaces directives found in the
 // given schema. It returns a schema that should be included along with the
 // original schema to perform the @replaces updates.
-func GetReplacesDirectiveUpdates(schema *ast.Schema) (string, error) {
-	replacer := NewReplacer()
+//
+// The Replacer is configured with the given options, if any; see
+// NewReplacerWithOptions.
+func GetReplacesDirectiveUpdates(schema *ast.Schema, options ...ReplacerOption) (string, error) {
+	return NewReplacerWithOptions(options...).GetReplacesDirectiveUpdates(schema)
+}
+
+// GetReplacesDirectiveUpdates is like the package-level function of the same
+// name, but respects the options set on the Replacer. A Replacer may only
+// process one schema.
+func (r *Replacer) GetReplacesDirectiveUpdates(schema *ast.Schema) (string, error) {
+	r.processSchema(schema)
+	r.checkOldNameCollisions(schema)
+	additions := r.getSchemaAdditions()
+
+	if len(r.errors) > 0 {
+		return "", errors.WrapWithFields(kind.InvalidInput, errors.Fields{"errorlist": r.errors})
+	}
 
+	return r.normalizeLineEndings(additions)
+}
+
+// GetReplacesDirectiveUpdatesByService is like GetReplacesDirectiveUpdates,
+// but for a composed schema (i.e. a schema in the CSDL format): it returns
+// the schema additions grouped by the service that owns them, so each service
+// can own its own deprecated.graphql. If the schema isn't a composed schema,
+// it returns an error wrapping ErrNotFederated.
+//
+// A renamed definition is owned by the service in its @join__owner
+// directive, and a renamed field by the service in its @join__field
+// directive, falling back to the owner of the type the field is on. Enum
+// values, interface implementations and union members belong to the owner of
+// their enum, object or union, respectively. Additions for "value" types,
+// which have no owner (e.g. enums and input objects), are keyed by "".
+func GetReplacesDirectiveUpdatesByService(
+	schema *ast.Schema,
+	options ...ReplacerOption,
+) (map[string]string, error) {
+	if !isFederatedSchema(schema) {
+		return nil, errors.WithStack(ErrNotFederated)
+	}
+
+	r := NewReplacerWithOptions(options...)
+	r.processSchema(schema)
+	r.checkOldNameCollisions(schema)
+	if len(r.errors) > 0 {
+		return nil, errors.WrapWithFields(kind.InvalidInput, errors.Fields{"errorlist": r.errors})
+	}
+
+	byService := make(map[string]*Replacer)
+	forService := func(service string) *Replacer {
+		serviceReplacer, ok := byService[service]
+		if !ok {
+			serviceReplacer = NewReplacerWithOptions(options...)
+			serviceReplacer.cacheReplacedTypes = r.cacheReplacedTypes
+			serviceReplacer.definitionKinds = r.definitionKinds
+			serviceReplacer.federationKeys = r.federationKeys
+			serviceReplacer.hasProcessedSchema = true
+			byService[service] = serviceReplacer
+		}
+		return serviceReplacer
+	}
+	typeOwner := func(typeName string) string {
+		return serviceForConcreteType(schema, schema.Types[typeName])
+	}
+
+	for _, definitionInfo := range r.definitions {
+		serviceReplacer := forService(typeOwner(definitionInfo.definition.Name))
+		serviceReplacer.definitions = append(serviceReplacer.definitions, definitionInfo)
+	}
+	for typeName, fieldInfos := range r.fields {
+		for _, fieldInfo := range fieldInfos {
+			service := serviceForField(schema, schema.Types[typeName], fieldInfo.field)
+			if service == "" {
+				service = typeOwner(typeName)
+			}
+			serviceReplacer := forService(service)
+			serviceReplacer.fields[typeName] = append(serviceReplacer.fields[typeName], fieldInfo)
+		}
+	}
+	for enumName, enumValueInfos := range r.enumValues {
+		serviceReplacer := forService(typeOwner(enumName))
+		serviceReplacer.enumValues[enumName] = enumValueInfos
+	}
+	for objectName, interfaces := range r.extraImplements {
+		serviceReplacer := forService(typeOwner(objectName))
+		serviceReplacer.extraImplements[objectName] = interfaces
+	}
+	for unionName, members := range r.extraUnionMembers {
+		serviceReplacer := forService(typeOwner(unionName))
+		serviceReplacer.extraUnionMembers[unionName] = members
+	}
+
+	updates := make(map[string]string, len(byService))
+	for service, serviceReplacer := range byService {
+		additions := serviceReplacer.getSchemaAdditions()
+		if len(serviceReplacer.errors) > 0 {
+			return nil, errors.WrapWithFields(kind.InvalidInput, errors.Fields{
+				"errorlist": serviceReplacer.errors,
+				"service":   service,
+			})
+		}
+		normalized, err := serviceReplacer.normalizeLineEndings(additions)
+		if err != nil {
+			return nil, err
+		}
+		updates[service] = normalized
+	}
+	return updates, nil
+}
+
+// GetReplacesDirectiveUpdatesForType is like GetReplacesDirectiveUpdates,
+// but returns only the additions for the type of the given (new) name: its
+// old definitions, if it was renamed, and the extensions adding its old
+// fields, enum values, interfaces and union members. This is useful for
+// previewing the additions for one type, e.g. in an editor. The whole schema
+// is still processed, so errors elsewhere in it are returned too. If the
+// schema has no such type, it returns a kind.NotFound error.
+func GetReplacesDirectiveUpdatesForType(
+	schema *ast.Schema,
+	typeName string,
+	options ...ReplacerOption,
+) (string, error) {
+	if schema.Types[typeName] == nil {
+		return "", errors.WrapWithFields(kind.NotFound, errors.Fields{"type": typeName})
+	}
+
+	r := NewReplacerWithOptions(options...)
+	r.processSchema(schema)
+	r.checkOldNameCollisions(schema)
+	if len(r.errors) > 0 {
+		return "", errors.WrapWithFields(kind.InvalidInput, errors.Fields{"errorlist": r.errors})
+	}
+
+	typeReplacer := NewReplacerWithOptions(options...)
+	typeReplacer.cacheReplacedTypes = r.cacheReplacedTypes
+	typeReplacer.definitionKinds = r.definitionKinds
+	typeReplacer.federationKeys = r.federationKeys
+	typeReplacer.hasProcessedSchema = true
+	for _, definitionInfo := range r.definitions {
+		if definitionInfo.definition.Name == typeName {
+			typeReplacer.definitions = append(typeReplacer.definitions, definitionInfo)
+		}
+	}
+	if fieldInfos, ok := r.fields[typeName]; ok {
+		typeReplacer.fields[typeName] = fieldInfos
+	}
+	if enumValueInfos, ok := r.enumValues[typeName]; ok {
+		typeReplacer.enumValues[typeName] = enumValueInfos
+	}
+	if interfaces, ok := r.extraImplements[typeName]; ok {
+		typeReplacer.extraImplements[typeName] = interfaces
+	}
+	if members, ok := r.extraUnionMembers[typeName]; ok {
+		typeReplacer.extraUnionMembers[typeName] = members
+	}
+
+	additions := typeReplacer.getSchemaAdditions()
+	if len(typeReplacer.errors) > 0 {
+		return "", errors.WrapWithFields(kind.InvalidInput, errors.Fields{"errorlist": typeReplacer.errors})
+	}
+	return typeReplacer.normalizeLineEndings(additions)
+}
+
+// GetReplacesDirectiveManifest returns a JSON manifest of all the renames
+// made by @replaces directives in the given schema; see
+// Replacer.RenameManifest.
+func GetReplacesDirectiveManifest(schema *ast.Schema) ([]byte, error) {
+	replacer := NewReplacer()
 	replacer.processSchema(schema)
-	additions := replacer.getSchemaAdditions()
+	replacer.checkOldNameCollisions(schema)
+	return replacer.RenameManifest()
+}
 
-	if len(replacer.errors) > 0 {
-		return "", errors.WrapWithFields(kind.InvalidInput, errors.Fields{"errorlist": replacer.errors})
+// Kinds of renames listed in a rename manifest (in addition to the
+// definition kinds, e.g. "object" or "inputObject").
+const (
+	RenameKindField       = "field"
+	RenameKindEnumValue   = "enumValue"
+	RenameKindArgument    = "argument"
+	RenameKindUnionMember = "union-member"
+)
+
+var _renameDefinitionKinds = map[ast.DefinitionKind]string{
+	ast.Object:      "object",
+	ast.InputObject: "inputObject",
+	ast.Interface:   "interface",
+	ast.Union:       "union",
+	ast.Enum:        "enum",
+	ast.Scalar:      "scalar",
+}
+
+// RenameManifest returns a JSON list of all the renames in the schema the
+// Replacer has processed (e.g. via GetReplacesDirectiveUpdates), one
+// RenameManifestEntry per old name, sorted by parent, kind and old name. It's
+// meant for tooling, like generating changelog entries.
+func (r *Replacer) RenameManifest() ([]byte, error) {
+	if !r.hasProcessedSchema {
+		return nil, errors.Wrap(kind.Internal, "must process a schema before getting its rename manifest")
+	}
+	if len(r.errors) > 0 {
+		return nil, errors.WrapWithFields(kind.InvalidInput, errors.Fields{"errorlist": r.errors})
+	}
+
+	entries := []RenameManifestEntry{}
+	newTypeNames := map[string]string{}
+	for _, definitionInfo := range r.definitions {
+		newTypeNames[definitionInfo.oldName] = definitionInfo.definition.Name
+		entries = append(entries, RenameManifestEntry{
+			Kind:    _renameDefinitionKinds[definitionInfo.definition.Kind],
+			OldName: definitionInfo.oldName,
+			NewName: definitionInfo.definition.Name,
+			Reason:  definitionInfo.reason,
+		})
+	}
+
+	processedArguments := map[*ast.FieldDefinition]bool{}
+	for typeName, fieldInfos := range r.fields {
+		for _, fieldInfo := range fieldInfos {
+			entry := RenameManifestEntry{
+				Kind:    RenameKindField,
+				Parent:  typeName,
+				OldName: fieldInfo.oldName,
+				NewName: fieldInfo.field.Name,
+				Reason:  fieldInfo.reason,
+			}
+			if fieldInfo.oldTypeName != "" {
+				entry.OldType = _updateType(fieldInfo.field.Type, fieldInfo.oldTypeName).String()
+				entry.NewType = fieldInfo.field.Type.String()
+			}
+			entries = append(entries, entry)
+
+			// A field with several old names shares its arguments.
+			if processedArguments[fieldInfo.field] {
+				continue
+			}
+			processedArguments[fieldInfo.field] = true
+			for _, arg := range fieldInfo.field.Arguments {
+				replaceInfo, err := GetReplaceInfo(arg.Directives)
+				if err != nil {
+					continue // not renamed (or already reported)
+				}
+				for _, oldName := range replaceInfo.OldNames {
+					entry := RenameManifestEntry{
+						Kind:    RenameKindArgument,
+						Parent:  typeName + "." + fieldInfo.field.Name,
+						OldName: oldName,
+						NewName: arg.Name,
+						Reason:  replaceInfo.ReasonFor(oldName),
+					}
+					if replaceInfo.OldTypeName != "" {
+						entry.OldType = _updateType(arg.Type, replaceInfo.OldTypeName).String()
+						entry.NewType = arg.Type.String()
+					}
+					entries = append(entries, entry)
+				}
+			}
+		}
+	}
+
+	for enumName, enumValueInfos := range r.enumValues {
+		for _, enumValueInfo := range enumValueInfos {
+			entries = append(entries, RenameManifestEntry{
+				Kind:    RenameKindEnumValue,
+				Parent:  enumName,
+				OldName: enumValueInfo.oldName,
+				NewName: enumValueInfo.newName,
+				Reason:  enumValueInfo.reason,
+			})
+		}
+	}
+
+	for unionName, members := range r.extraUnionMembers {
+		for _, member := range members {
+			entries = append(entries, RenameManifestEntry{
+				Kind:    RenameKindUnionMember,
+				Parent:  unionName,
+				OldName: member,
+				NewName: newTypeNames[member],
+			})
+		}
 	}
 
-	return additions, nil
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Parent != entries[j].Parent {
+			return entries[i].Parent < entries[j].Parent
+		}
+		if entries[i].Kind != entries[j].Kind {
+			return entries[i].Kind < entries[j].Kind
+		}
+		return entries[i].OldName < entries[j].OldName
+	})
+
+	manifest, err := json.Marshal(entries)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return manifest, nil
+}
+
+// normalizeLineEndings converts all the line endings in the given text to
+// r.LineEnding. Note that the text may already contain a mix of line
+// endings, since descriptions are copied from the (possibly CRLF) source.
+func (r *Replacer) normalizeLineEndings(text string) (string, error) {
+	text = strings.ReplaceAll(text, LineEndingCRLF, LineEndingLF)
+	switch r.LineEnding {
+	case "", LineEndingLF:
+		return text, nil
+	case LineEndingCRLF:
+		return strings.ReplaceAll(text, LineEndingLF, LineEndingCRLF), nil
+	default:
+		return "", errors.WrapWithFields(kind.InvalidInput, errors.Fields{
+			"message":    "line ending must be LF or CRLF",
+			"lineEnding": r.LineEnding,
+		})
+	}
+}
+
+// DeprecatedSchemaDrift describes how an existing deprecated.graphql file
+// differs from what GetReplacesDirectiveUpdates would generate; see
+// CheckDeprecatedSchema.
+//
+// Entries are named like "StudentList" (a deprecated definition),
+// "Classroom.coachKaid" (a deprecated field or enum value),
+// "Domain implements Topic" or "ClassroomStuff = StudentList".
+type DeprecatedSchemaDrift struct {
+	// Missing is the (sorted) entries for @replaces renames in the schema
+	// which aren't in the deprecated.graphql file.
+	Missing []string
+	// Stale is the (sorted) entries in the deprecated.graphql file which are
+	// no longer backed by a @replaces directive.
+	Stale []string
+}
+
+// HasDrift returns whether the deprecated.graphql file is out of date.
+func (d *DeprecatedSchemaDrift) HasDrift() bool {
+	return len(d.Missing) > 0 || len(d.Stale) > 0
+}
+
+// CheckDeprecatedSchema compares the renames in the given schema to the
+// contents of an existing deprecated.graphql file, and reports any drift
+// between them. This is the validation counterpart of
+// GetReplacesDirectiveUpdates, suitable for a lint or CI check.
+//
+// The comparison is by name only: it won't notice, say, a deprecated field
+// whose type has changed. Compare against the output of
+// GetReplacesDirectiveUpdates if you need that.
+func CheckDeprecatedSchema(
+	schema *ast.Schema,
+	deprecatedSchema string,
+) (*DeprecatedSchemaDrift, error) {
+	expected, err := GetReplacesDirectiveUpdates(schema)
+	if err != nil {
+		return nil, err
+	}
+	expectedEntries, err := _deprecatedSchemaEntries(expected)
+	if err != nil {
+		return nil, err
+	}
+	actualEntries, err := _deprecatedSchemaEntries(deprecatedSchema)
+	if err != nil {
+		return nil, err
+	}
+
+	drift := &DeprecatedSchemaDrift{}
+	for entry := range expectedEntries {
+		if !actualEntries[entry] {
+			drift.Missing = append(drift.Missing, entry)
+		}
+	}
+	for entry := range actualEntries {
+		if !expectedEntries[entry] {
+			drift.Stale = append(drift.Stale, entry)
+		}
+	}
+	sort.Strings(drift.Missing)
+	sort.Strings(drift.Stale)
+	return drift, nil
+}
+
+// _deprecatedSchemaEntries returns the set of entries (see
+// DeprecatedSchemaDrift) in the given deprecated.graphql content.
+func _deprecatedSchemaEntries(content string) (map[string]bool, error) {
+	doc, err := parser.ParseSchema(&ast.Source{Name: "deprecated.graphql", Input: content})
+	if err != nil {
+		return nil, errors.WrapWithFields(kind.InvalidInput, errors.Fields{
+			"message":   "unable to parse deprecated schema",
+			"originErr": err,
+		})
+	}
+
+	entries := make(map[string]bool)
+	for _, definition := range doc.Definitions {
+		entries[definition.Name] = true
+	}
+	for _, extension := range doc.Extensions {
+		for _, field := range extension.Fields {
+			entries[extension.Name+"."+field.Name] = true
+		}
+		for _, enumValue := range extension.EnumValues {
+			entries[extension.Name+"."+enumValue.Name] = true
+		}
+		for _, iface := range extension.Interfaces {
+			entries[extension.Name+" implements "+iface] = true
+		}
+		for _, member := range extension.Types {
+			entries[extension.Name+" = "+member] = true
+		}
+	}
+	return entries, nil
 }
 
 // processSchema records metadata about uses of @replaces directives in the
@@ -191,13 +986,19 @@ func (r *Replacer) processSchema(schema *ast.Schema) {
 		r.hasProcessedSchema = true
 	}
 
+	if deprecated := schema.Directives["deprecated"]; deprecated != nil {
+		for _, location := range deprecated.Locations {
+			r.deprecatedLocations[location] = true
+		}
+	}
+
 	for _, definition := range schema.Types {
 		r._processDefinition(definition)
 
 		switch definition.Kind {
 		case ast.Object, ast.InputObject, ast.Interface:
 			for _, field := range definition.Fields {
-				r._processField(definition.Name, definition.Kind, field)
+				r._processField(schema, definition.Name, definition.Kind, field)
 			}
 		case ast.Enum:
 			for _, enumValue := range definition.EnumValues {
@@ -206,6 +1007,12 @@ func (r *Replacer) processSchema(schema *ast.Schema) {
 		}
 	}
 
+	for _, directive := range schema.Directives {
+		r._processDirectiveArguments(directive)
+	}
+
+	r._checkOldTypesExist(schema)
+
 	// Go through the types again to find any objects that implement renamed
 	// interfaces or unions that included renamed union members. These types
 	// will be updated (via the extend keyword) to implement/include the old
@@ -224,6 +1031,50 @@ func (r *Replacer) processSchema(schema *ast.Schema) {
 	}
 }
 
+// checkOldNameCollisions records an error for each old name which collides
+// with a type, or a field or enum value of the same type, that's already in
+// the schema; we'd otherwise emit a duplicate which fails to parse later.
+//
+// Note this isn't part of processSchema, because it only makes sense for the
+// schema without its deprecated.graphql: ValidateReplacesDirectives is also
+// used on the full schema (e.g. by the ReplacesDirective plugin), where the
+// old names are expected to exist.
+func (r *Replacer) checkOldNameCollisions(schema *ast.Schema) {
+	collision := func(definition string, oldName string, collisionKind string) error {
+		return errors.WrapWithFields(kind.InvalidInput, errors.Fields{
+			"message":    "@replaces directive old name collides with an existing name",
+			"definition": definition,
+			"oldName":    oldName,
+			"collision":  collisionKind,
+		})
+	}
+
+	for _, definitionInfo := range r.definitions {
+		if schema.Types[definitionInfo.oldName] != nil {
+			r.errors = append(r.errors, collision(
+				definitionInfo.definition.Name, definitionInfo.oldName, "type"))
+		}
+	}
+
+	for typeName, fieldInfos := range r.fields {
+		definition := schema.Types[typeName]
+		for _, fieldInfo := range fieldInfos {
+			if definition.Fields.ForName(fieldInfo.oldName) != nil {
+				r.errors = append(r.errors, collision(typeName, fieldInfo.oldName, "field"))
+			}
+		}
+	}
+
+	for enumName, enumValueInfos := range r.enumValues {
+		definition := schema.Types[enumName]
+		for _, enumValueInfo := range enumValueInfos {
+			if definition.EnumValues.ForName(enumValueInfo.oldName) != nil {
+				r.errors = append(r.errors, collision(enumName, enumValueInfo.oldName, "enum value"))
+			}
+		}
+	}
+}
+
 func (r *Replacer) getReplaceInfo(directives ast.DirectiveList) (*ReplaceInfo, bool) {
 	replaceInfo, err := GetReplaceInfo(directives)
 	if errors.Is(err, kind.NotFound) {
@@ -237,20 +1088,21 @@ func (r *Replacer) getReplaceInfo(directives ast.DirectiveList) (*ReplaceInfo, b
 }
 
 func (r *Replacer) _processField(
+	schema *ast.Schema,
 	typeName string,
 	definitionKind ast.DefinitionKind,
 	field *ast.FieldDefinition,
 ) {
 	replaceInfo, ok := r.getReplaceInfo(field.Directives)
 	if !ok {
-		// Verify that none of the arguments are renamed. While it would be
-		// possible to allow argument renames by including both the old and
-		// new names as nullable arguments (and enforcing that only one is
-		// set, and mapping between the two), requiring the field to be also
-		// renamed is simpler to reason about: we don't have a deal with
-		// enforcing non-nullability in mapping code. Callers need to be
-		// updated to use the new argument anyway, so also updating the field
-		// name isn't much more of a change.
+		// Verify that none of the arguments are renamed. We can't support
+		// renaming just an argument: the old argument would have to be added
+		// to the existing field, but GraphQL can't extend a field, and
+		// emitting the field again (with both arguments) is an error ("Field
+		// can only be defined once"). Renaming the field too gives us a new
+		// field to put the old arguments on. Callers need to be updated to
+		// use the new argument anyway, so also updating the field name isn't
+		// much more of a change.
 		for _, arg := range field.Arguments {
 			if _, ok := r.getReplaceInfo(arg.Directives); ok {
 				r.errors = append(r.errors,
@@ -268,6 +1120,20 @@ func (r *Replacer) _processField(
 		return
 	}
 
+	r._checkOldNames(typeName+"."+field.Name, field.Name, replaceInfo)
+	r._checkArgumentsHaveOneOldName(typeName, field)
+	r._checkOldTypeChanges(field.Type, replaceInfo,
+		errors.Fields{"type": typeName, "field": field.Name})
+	for _, arg := range field.Arguments {
+		argReplaceInfo, ok := r.getReplaceInfo(arg.Directives)
+		if !ok {
+			continue
+		}
+		r._checkOldNames(typeName+"."+field.Name+"("+arg.Name+")", arg.Name, argReplaceInfo)
+		r._checkOldTypeChanges(arg.Type, argReplaceInfo,
+			errors.Fields{"type": typeName, "field": field.Name, "argument": arg.Name})
+	}
+
 	if definitionKind == ast.InputObject {
 		if field.Type.NonNull {
 			r.errors = append(r.errors, errors.WrapWithFields(kind.InvalidInput,
@@ -277,11 +1143,52 @@ func (r *Replacer) _processField(
 					"field":   field.Name,
 				},
 			))
+		} else if replaceInfo.OldTypeName != "" &&
+			!_isNullableType(_updateType(field.Type, replaceInfo.OldTypeName)) {
+			// The new field is nullable, but the old type may not be, e.g.
+			// @replaces(name: "oldField", type: "String!"). We'd then emit a
+			// non-null old field, which clients could never omit.
+			r.errors = append(r.errors, errors.WrapWithFields(kind.InvalidInput,
+				errors.Fields{
+					"message": "input fields using the @replaces directive must have a nullable old type",
+					"type":    typeName,
+					"field":   field.Name,
+					"oldType": replaceInfo.OldTypeName,
+				},
+			))
+		}
+		if _isNonListField(field) {
+			switch namedType := schema.Types[field.Type.Name()]; {
+			case namedType != nil &&
+				(namedType.Kind == ast.InputObject || namedType.Kind == ast.Enum):
+				// Only scalars have a zero value clients might send to mean
+				// "unset", so treatZeroAsUnset is meaningless here.
+				if replaceInfo.TreatZeroAsUnsetPresent {
+					r.errors = append(r.errors, errors.WrapWithFields(kind.InvalidInput,
+						errors.Fields{
+							"message":   "@replaces directive's treatZeroAsUnset can only be used on scalar input fields",
+							"type":      typeName,
+							"field":     field.Name,
+							"fieldType": namedType.Name,
+						},
+					))
+				}
+			case !replaceInfo.TreatZeroAsUnsetPresent:
+				r.errors = append(r.errors, errors.WrapWithFields(kind.InvalidInput,
+					errors.Fields{
+						"message": "@replaces directive on non-list input fields must include treatZeroAsUnset:true or treatZeroAsUnset:false",
+						"type":    typeName,
+						"field":   field.Name,
+					},
+				))
+			}
 		}
-		if _isNonListField(field) && !replaceInfo.TreatZeroAsUnsetPresent {
+		// The generated mapping code checks each old name against the new
+		// name separately, so it can't require exactly one of several.
+		if replaceInfo.WasRequiredBeforeRename && len(replaceInfo.OldNames) > 1 {
 			r.errors = append(r.errors, errors.WrapWithFields(kind.InvalidInput,
 				errors.Fields{
-					"message": "@replaces directive on non-list input fields must include treatZeroAsUnset:true or treatZeroAsUnset:false",
+					"message": "@replaces directive with more than one old name can't use wasRequiredBeforeRename",
 					"type":    typeName,
 					"field":   field.Name,
 				},
@@ -289,11 +1196,130 @@ func (r *Replacer) _processField(
 		}
 	}
 
-	r.fields[typeName] = append(r.fields[typeName], _fieldInfo{
-		field:       field,
-		oldName:     replaceInfo.OldName,
-		oldTypeName: replaceInfo.OldTypeName,
-	})
+	for _, oldName := range replaceInfo.OldNames {
+		r.fields[typeName] = append(r.fields[typeName], _fieldInfo{
+			field:            field,
+			oldName:          oldName,
+			oldTypeName:      replaceInfo.OldTypeName,
+			treatZeroAsUnset: replaceInfo.TreatZeroAsUnset,
+			reason:           replaceInfo.ReasonFor(oldName),
+		})
+	}
+}
+
+// _processDirectiveArguments verifies that none of the arguments of a
+// directive definition are renamed. We can't support that: the old argument
+// would have to be added to the existing directive, but GraphQL has no way to
+// extend a directive definition, and redefining it is an error. So existing
+// uses of the old argument would silently stop validating; callers should
+// instead add a new directive and deprecate the old one by hand.
+func (r *Replacer) _processDirectiveArguments(directive *ast.DirectiveDefinition) {
+	for _, arg := range directive.Arguments {
+		if _, ok := r.getReplaceInfo(arg.Directives); ok {
+			r.errors = append(r.errors,
+				errors.WrapWithFields(kind.InvalidInput,
+					errors.Fields{
+						"message":   "@replaces directive can't be used on directive arguments",
+						"directive": directive.Name,
+						"argument":  arg.Name,
+					},
+				),
+			)
+		}
+	}
+}
+
+// _checkOldNames records an error if any of the old names in replaceInfo
+// is the same as the new name, which would make the old and new names
+// collide. (GetReplaceInfo already checks that the old names are distinct.)
+func (r *Replacer) _checkOldNames(node string, newName string, replaceInfo *ReplaceInfo) {
+	for _, oldName := range replaceInfo.OldNames {
+		if oldName == newName {
+			r.errors = append(r.errors, errors.WrapWithFields(kind.InvalidInput,
+				errors.Fields{
+					"message": "@replaces directive can't replace a name with itself",
+					"node":    node,
+					"oldName": oldName,
+				},
+			))
+		}
+	}
+}
+
+// _checkOldTypeChanges records an error, in strict mode, if replaceInfo has
+// an old type which is the same as the inner named type of typ, the current
+// type of the field or argument being renamed. Such a `type` argument does
+// nothing, and probably means the author meant to name a different type (or
+// forgot to update the field's type). fields identify the field or argument
+// in the error.
+//
+// It also notes the old type, if any, for _checkOldTypesExist.
+func (r *Replacer) _checkOldTypeChanges(
+	typ *ast.Type,
+	replaceInfo *ReplaceInfo,
+	fields errors.Fields,
+) {
+	if replaceInfo.OldTypeName != "" {
+		refFields := errors.Fields{}
+		for key, value := range fields {
+			refFields[key] = value
+		}
+		r.oldTypeRefs = append(r.oldTypeRefs, _oldTypeRef{
+			oldTypeName: replaceInfo.OldTypeName,
+			fields:      refFields,
+		})
+	}
+	if !r.Strict || replaceInfo.OldTypeName != typ.Name() {
+		return
+	}
+	fields["message"] = "@replaces directive's type argument is the same as the current type"
+	fields["oldType"] = replaceInfo.OldTypeName
+	r.errors = append(r.errors, errors.WrapWithFields(kind.InvalidInput, fields))
+}
+
+// _checkOldTypesExist records an error for each old type named by a
+// @replaces directive's `type` argument which is neither a type in the schema
+// nor the old name of a renamed type, which we'll emit.  Otherwise the old
+// field or argument we emit would refer to a type which doesn't exist, and
+// the schema would fail to parse later.
+func (r *Replacer) _checkOldTypesExist(schema *ast.Schema) {
+	emittedTypes := map[string]bool{}
+	for _, oldNames := range r.cacheReplacedTypes {
+		for _, oldName := range oldNames {
+			emittedTypes[oldName] = true
+		}
+	}
+	for _, ref := range r.oldTypeRefs {
+		// The type may be non-null, like "String!".
+		typeName := strings.TrimSuffix(ref.oldTypeName, "!")
+		if schema.Types[typeName] != nil || emittedTypes[typeName] {
+			continue
+		}
+		ref.fields["message"] = "@replaces directive's type argument names a type that doesn't exist"
+		ref.fields["oldType"] = ref.oldTypeName
+		r.errors = append(r.errors, errors.WrapWithFields(kind.InvalidInput, ref.fields))
+	}
+}
+
+// _checkArgumentsHaveOneOldName records an error if any of the arguments of
+// the given field replaces more than one old name. We'd need to emit one
+// copy of the field per combination of old argument names, which isn't worth
+// the trouble.
+func (r *Replacer) _checkArgumentsHaveOneOldName(typeName string, field *ast.FieldDefinition) {
+	for _, arg := range field.Arguments {
+		// Any errors will be recorded when the argument is processed.
+		replaceInfo, err := GetReplaceInfo(arg.Directives)
+		if err == nil && len(replaceInfo.OldNames) > 1 {
+			r.errors = append(r.errors, errors.WrapWithFields(kind.InvalidInput,
+				errors.Fields{
+					"message":  "@replaces directive on arguments can only replace one old name",
+					"type":     typeName,
+					"field":    field.Name,
+					"argument": arg.Name,
+				},
+			))
+		}
+	}
 }
 
 // _isNonListField returns whether the give field has a non-list type, e.g.
@@ -318,12 +1344,21 @@ func _isNonListField(field *ast.FieldDefinition) bool {
 	return field.Type.NamedType != ""
 }
 
+// _isNullableType returns whether the given type is nullable. Note that the
+// type returned by _updateType may have a NamedType which itself ends in "!"
+// (if the `type` argument of @replaces did), so we check the formatted type.
+func _isNullableType(typ *ast.Type) bool {
+	return !strings.HasSuffix(typ.String(), "!")
+}
+
 func (r *Replacer) _processEnumValue(enumName string, enumValue *ast.EnumValueDefinition) {
 	replaceInfo, ok := r.getReplaceInfo(enumValue.Directives)
 	if !ok {
 		return
 	}
 
+	r._checkOldNames(enumName+"."+enumValue.Name, enumValue.Name, replaceInfo)
+
 	if replaceInfo.OldTypeName != "" {
 		r.errors = append(r.errors, errors.WrapWithFields(kind.InvalidInput,
 			errors.Fields{
@@ -332,13 +1367,19 @@ func (r *Replacer) _processEnumValue(enumName string, enumValue *ast.EnumValueDe
 		))
 	}
 
-	r.enumValues[enumName] = append(r.enumValues[enumName], _enumValueInfo{
-		enumValue: enumValue,
-		newName:   enumValue.Name,
-		oldName:   replaceInfo.OldName,
-	})
+	for _, oldName := range replaceInfo.OldNames {
+		r.enumValues[enumName] = append(r.enumValues[enumName], _enumValueInfo{
+			enumValue: enumValue,
+			newName:   enumValue.Name,
+			oldName:   oldName,
+			reason:    replaceInfo.ReasonFor(oldName),
+		})
+	}
 }
 
+// _processDefinition records the definition's kind and federation keys, and
+// its rename, if any. All kinds of definition may be renamed, including
+// scalars, but only via the `name` argument of @replaces.
 func (r *Replacer) _processDefinition(def *ast.Definition) {
 	r.definitionKinds[def.Name] = def.Kind
 	r.federationKeys[def.Name] = _getFederationKeys(def)
@@ -348,6 +1389,8 @@ func (r *Replacer) _processDefinition(def *ast.Definition) {
 		return
 	}
 
+	r._checkOldNames(def.Name, def.Name, replaceInfo)
+
 	if replaceInfo.OldTypeName != "" {
 		r.errors = append(r.errors, errors.WrapWithFields(kind.InvalidInput,
 			errors.Fields{
@@ -356,10 +1399,16 @@ func (r *Replacer) _processDefinition(def *ast.Definition) {
 		))
 	}
 
-	r.definitions = append(
-		r.definitions, _definitionInfo{definition: def, oldName: replaceInfo.OldName})
+	for _, oldName := range replaceInfo.OldNames {
+		r.definitions = append(
+			r.definitions, _definitionInfo{
+				definition: def,
+				oldName:    oldName,
+				reason:     replaceInfo.ReasonFor(oldName),
+			})
+	}
 
-	r.cacheReplacedTypes[def.Name] = replaceInfo.OldName
+	r.cacheReplacedTypes[def.Name] = replaceInfo.OldNames
 }
 
 func _getFederationKeys(def *ast.Definition) []string {
@@ -378,20 +1427,20 @@ func _getFederationKeys(def *ast.Definition) []string {
 
 func (r *Replacer) _processInterfaceImplementation(objectName string, interfaceName string) {
 	// Look for interface names that have been renamed.
-	oldName, ok := r.cacheReplacedTypes[interfaceName]
+	oldNames, ok := r.cacheReplacedTypes[interfaceName]
 	if !ok {
 		return
 	}
-	r.extraImplements[objectName] = append(r.extraImplements[objectName], oldName)
+	r.extraImplements[objectName] = append(r.extraImplements[objectName], oldNames...)
 }
 
 func (r *Replacer) _processUnionMember(unionName string, memberName string) {
 	// Look for union members that have been renamed.
-	oldName, ok := r.cacheReplacedTypes[memberName]
+	oldNames, ok := r.cacheReplacedTypes[memberName]
 	if !ok {
 		return
 	}
-	r.extraUnionMembers[unionName] = append(r.extraUnionMembers[unionName], oldName)
+	r.extraUnionMembers[unionName] = append(r.extraUnionMembers[unionName], oldNames...)
 }
 
 type _internalFormatter interface {
@@ -426,23 +1475,28 @@ func (r *Replacer) getSchemaAdditions() string {
 	})
 
 	// Definition updates. Definitions cover objects, input objects,
-	// interfaces, unions and enums.
+	// interfaces, unions, enums and scalars. (A renamed scalar is emitted as
+	// just `scalar OldName`, keeping its other directives such as
+	// @specifiedBy; since it has no fields there's nothing else to update,
+	// and fields of type OldName are only ever added by a `type` argument on
+	// their own @replaces.)
 	for _, definitionInfo := range r.definitions {
 		hasExtend := _definitionHasExtends(definitionInfo.definition)
 		oldDefinition := *definitionInfo.definition
-		deprecatedMessage := fmt.Sprintf(
-			"Deprecated: Replaced by %s.", definitionInfo.definition.Name)
-		if oldDefinition.Description == "" {
-			// TODO(marksandstrom) Emit the above description as a comment when
-			// the "extend" keyword is present.
-			if !hasExtend {
-				oldDefinition.Description = deprecatedMessage
+		oldDefinition.Directives = _removeReplacesDirective(oldDefinition.Directives)
+		oldDefinition.Description, oldDefinition.Directives = r._markDeprecated(
+			_definitionLocations[oldDefinition.Kind],
+			_deprecationReason(definitionInfo.reason, definitionInfo.definition.Name),
+			oldDefinition.Description, oldDefinition.Directives)
+		if hasExtend {
+			// GraphQL doesn't allow descriptions on extensions, so we emit
+			// the description as a comment instead.
+			for _, line := range strings.Split(oldDefinition.Description, "\n") {
+				buf.WriteString(strings.TrimRight("# "+line, " ") + "\n")
 			}
-		} else {
-			oldDefinition.Description = oldDefinition.Description + "\n" + deprecatedMessage
+			oldDefinition.Description = ""
 		}
 		oldDefinition.Name = definitionInfo.oldName
-		oldDefinition.Directives = _removeReplacesDirective(oldDefinition.Directives)
 		oldDefinition.Fields = make(
 			ast.FieldList, len(definitionInfo.definition.Fields))
 		// Clear @replaces directives on fields.
@@ -467,6 +1521,19 @@ func (r *Replacer) getSchemaAdditions() string {
 				newField.Arguments[j] = &updatedArg
 			}
 		}
+		// Renamed unions list the old names of their renamed members, to
+		// match the schema from before the renames. (The new union instead
+		// gets the old members via an extension; see below.)
+		if len(definitionInfo.definition.Types) > 0 {
+			oldDefinition.Types = nil
+			for _, member := range definitionInfo.definition.Types {
+				if oldNames, ok := r.cacheReplacedTypes[member]; ok {
+					oldDefinition.Types = append(oldDefinition.Types, oldNames...)
+				} else {
+					oldDefinition.Types = append(oldDefinition.Types, member)
+				}
+			}
+		}
 		// Clear @replaces directives on enum values.
 		//
 		// For renamed enums, we emit the enum with the existing new enum
@@ -517,20 +1584,22 @@ func (r *Replacer) getSchemaAdditions() string {
 
 	for _, newObjectName := range fieldsObjectNames {
 		fields := r.fields[newObjectName]
+		// Emit the old fields in the order of the new fields in the source,
+		// so diffs of the output are reproducible.
+		sort.SliceStable(fields, func(i, j int) bool {
+			return _sourceStart(fields[i].field.Position) < _sourceStart(fields[j].field.Position)
+		})
 
 		// If the object the fields are on has also been renamed, output
 		// renamed fields for both new and old object names.
 		allObjectNames := []string{newObjectName}
-		if oldName, ok := r.cacheReplacedTypes[newObjectName]; ok {
-			allObjectNames = append(allObjectNames, oldName)
+		if oldNames, ok := r.cacheReplacedTypes[newObjectName]; ok {
+			allObjectNames = append(allObjectNames, oldNames...)
 		}
 
-		// We make a copy of the keys and update them in-place if a renamed
-		// field is present in a key. Any updated keys are added to the type
-		// extension.
-		keys := make([]string, len(r.federationKeys[newObjectName]))
-		copy(keys, r.federationKeys[newObjectName])
-		keyHasUpdates := make([]bool, len(keys))
+		// Any keys which include a renamed field are added to the type
+		// extension, with the field's old name.
+		oldKeys := _oldFederationKeys(r.federationKeys[newObjectName], fields)
 
 		for _, objectName := range allObjectNames {
 			object := ast.Definition{
@@ -544,20 +1613,6 @@ func (r *Replacer) getSchemaAdditions() string {
 					oldField.Type = _updateType(fieldInfo.field.Type, fieldInfo.oldTypeName)
 				}
 
-				for i := range keys {
-					// Note: if a renamed field name appears in two places in
-					// the federation key, e.g. `id { id }`, we'll replace both
-					// instances of the name, which isn't correct (we only want
-					// to replace the field belonging to the object). This case
-					// is pretty rare, and we don't expect to encounter it in
-					// practice.
-					if _containsExactWord(keys[i], fieldInfo.field.Name) {
-						keys[i] = _replaceExactWord(
-							keys[i], fieldInfo.field.Name, fieldInfo.oldName)
-						keyHasUpdates[i] = true
-					}
-				}
-
 				// Apply any argument renames. Note: renamed arguments are only
 				// allowed on renamed fields, i.e. if an argument is renamed,
 				// the corresponding field must also be renamed. This
@@ -582,52 +1637,52 @@ func (r *Replacer) getSchemaAdditions() string {
 				}
 				oldField.Directives = _removeReplacesDirective(oldField.Directives)
 
-				deprecatedMessage := fmt.Sprintf("Replaced by %s.", fieldInfo.field.Name)
-				// The @deprecated directive isn't valid on input fields.
 				if r.definitionKinds[newObjectName] != ast.InputObject {
 					oldField.Directives = _addDeprecatedDirective(
-						oldField.Directives, deprecatedMessage)
+						oldField.Directives,
+						_deprecationReason(fieldInfo.reason, fieldInfo.field.Name))
 				} else {
-					if oldField.Description == "" {
-						oldField.Description = "Deprecated: " + deprecatedMessage
-					} else {
-						oldField.Description = oldField.Description +
-							"\nDeprecated: " + deprecatedMessage
-					}
+					// By default we describe old input fields as deprecated,
+					// since older versions of the spec didn't allow
+					// @deprecated on them.
+					oldField.Description, oldField.Directives = r._markDeprecated(
+						ast.LocationInputFieldDefinition,
+						_deprecationReason(fieldInfo.reason, fieldInfo.field.Name),
+						oldField.Description, oldField.Directives)
 				}
-				oldField.Directives = append(oldField.Directives, &ast.Directive{
-					Name: "goField",
-					Arguments: ast.ArgumentList{
-						&ast.Argument{
-							Name: "name",
-							Value: &ast.Value{
-								Kind: ast.StringValue,
-								Raw:  "Deprecated" + strings.Title(fieldInfo.oldName),
+				if !r.OmitGoField {
+					oldField.Directives = append(oldField.Directives, &ast.Directive{
+						Name: "goField",
+						Arguments: ast.ArgumentList{
+							&ast.Argument{
+								Name: "name",
+								Value: &ast.Value{
+									Kind: ast.StringValue,
+									Raw:  r.GoFieldPrefix + strings.Title(fieldInfo.oldName),
+								},
 							},
 						},
-					},
-				})
+					})
+				}
 				object.Fields = append(object.Fields, &oldField)
 			}
 
 			// Add any updated keys to the type extension. Directives on type
 			// extensions are additive; any updated keys will be present on
 			// the type along with the original keys.
-			for i := range keys {
-				if keyHasUpdates[i] {
-					object.Directives = append(object.Directives, &ast.Directive{
-						Name: "key",
-						Arguments: ast.ArgumentList{
-							&ast.Argument{
-								Name: "fields",
-								Value: &ast.Value{
-									Kind: ast.StringValue,
-									Raw:  keys[i],
-								},
+			for _, key := range oldKeys {
+				object.Directives = append(object.Directives, &ast.Directive{
+					Name: "key",
+					Arguments: ast.ArgumentList{
+						&ast.Argument{
+							Name: "fields",
+							Value: &ast.Value{
+								Kind: ast.StringValue,
+								Raw:  key,
 							},
 						},
-					})
-				}
+					},
+				})
 			}
 
 			f.FormatDefinition(&object, true)
@@ -651,8 +1706,8 @@ func (r *Replacer) getSchemaAdditions() string {
 		// If the enum the enum values are on has also been renamed, output
 		// renamed enum values for both new and old enum names.
 		allEnumNames := []string{newName}
-		if oldName, ok := r.cacheReplacedTypes[newName]; ok {
-			allEnumNames = append(allEnumNames, oldName)
+		if oldNames, ok := r.cacheReplacedTypes[newName]; ok {
+			allEnumNames = append(allEnumNames, oldNames...)
 		}
 
 		for _, enumName := range allEnumNames {
@@ -668,7 +1723,7 @@ func (r *Replacer) getSchemaAdditions() string {
 				oldEnumValue.Directives = _removeReplacesDirective(oldEnumValue.Directives)
 				oldEnumValue.Directives = _addDeprecatedDirective(
 					oldEnumValue.Directives,
-					fmt.Sprintf("Replaced by %s.", enumValueInfo.newName))
+					_deprecationReason(enumValueInfo.reason, enumValueInfo.newName))
 				enum.EnumValues = append(enum.EnumValues, &oldEnumValue)
 			}
 			f.FormatDefinition(&enum, true)
@@ -690,8 +1745,8 @@ func (r *Replacer) getSchemaAdditions() string {
 		// been renamed, output extra interfaces for both new and old object
 		// names.
 		allObjectNames := []string{newName}
-		if oldName, ok := r.cacheReplacedTypes[newName]; ok {
-			allObjectNames = append(allObjectNames, oldName)
+		if oldNames, ok := r.cacheReplacedTypes[newName]; ok {
+			allObjectNames = append(allObjectNames, oldNames...)
 		}
 
 		for _, objectName := range allObjectNames {
@@ -707,14 +1762,17 @@ func (r *Replacer) getSchemaAdditions() string {
 
 	// Union member updates
 	//
-	// We emit union extensions that to add old union members to both new
-	// (and possibly old) union types. For example:
+	// We emit union extensions that to add old union members to new union
+	// types. For example:
 	//
 	// union SomeUnion = MemberOne | MemberTwo
 	// extend SomeUnion = OldMemberTwo
 	//
 	// Results in the union:
 	// union SomeUnion = MemberOne | MemberTwo | OldMemberTwo
+	//
+	// If the union was also renamed, the old union was emitted above with
+	// the old members already, so it needs no extension.
 	extraUnionMembersUnionNames := make([]string, 0, len(r.extraUnionMembers))
 	for unionName := range r.extraUnionMembers {
 		extraUnionMembersUnionNames = append(extraUnionMembersUnionNames, unionName)
@@ -722,29 +1780,27 @@ func (r *Replacer) getSchemaAdditions() string {
 	sort.Strings(extraUnionMembersUnionNames)
 
 	for _, newName := range extraUnionMembersUnionNames {
-		unionMembers := r.extraUnionMembers[newName]
-
-		// If the union the union members are on has also been renamed, output
-		// the extra union members for both new and old union names.
-		allUnionNames := []string{newName}
-		if oldName, ok := r.cacheReplacedTypes[newName]; ok {
-			allUnionNames = append(allUnionNames, oldName)
-		}
-
-		for _, unionName := range allUnionNames {
-			union := ast.Definition{
-				Kind: ast.Union,
-				Name: unionName,
-			}
-			union.Types = append(union.Types, unionMembers...)
-			f.FormatDefinition(&union, true)
-			buf.WriteByte('\n')
+		union := ast.Definition{
+			Kind: ast.Union,
+			Name: newName,
 		}
+		union.Types = append(union.Types, r.extraUnionMembers[newName]...)
+		f.FormatDefinition(&union, true)
+		buf.WriteByte('\n')
 	}
 
 	return strings.ReplaceAll(buf.String(), "\t", "    ")
 }
 
+// _sourceStart returns the offset in its source of the given position, or -1
+// if there's none (e.g. for a field built by hand rather than parsed).
+func _sourceStart(position *ast.Position) int {
+	if position == nil {
+		return -1
+	}
+	return position.Start
+}
+
 // We expect "extend" and the definition keyword to be on the same line.
 // GraphQL doesn't require this, but it prevents us from picking up "extend"
 // at the end of a comment. Note that "extend" does NOT have to be the first
@@ -780,18 +1836,97 @@ func _definitionHasExtends(definition *ast.Definition) bool {
 	return _extendRegex.FindString(substring) != ""
 }
 
-func _containsExactWord(text string, word string) bool {
-	// The inputs are GraphQL field names, which won't have any characters that
-	// need to be escaped.
-	regex := regexp.MustCompile(`\b` + word + `\b`)
-	return regex.FindString(text) != ""
+// _oldFederationKeys returns the keys to add for the given renamed fields of
+// an object: each of the object's keys which includes a renamed field, with
+// the field replaced by its old name. Each key is rewritten starting from the
+// original, so a field with several old names gets one key per old name (and a
+// key including several such fields gets one for each combination).
+func _oldFederationKeys(keys []string, fields []_fieldInfo) []string {
+	// The old names of each renamed field, in the order of the fields.
+	var fieldNames []string
+	oldNames := make(map[string][]string)
+	for _, fieldInfo := range fields {
+		name := fieldInfo.field.Name
+		if _, ok := oldNames[name]; !ok {
+			fieldNames = append(fieldNames, name)
+		}
+		oldNames[name] = append(oldNames[name], fieldInfo.oldName)
+	}
+
+	var oldKeys []string
+	for _, key := range keys {
+		updatedKeys := []string{key}
+		for _, name := range fieldNames {
+			var next []string
+			for _, updatedKey := range updatedKeys {
+				for _, oldName := range oldNames[name] {
+					// Only top-level fields in the key belong to this
+					// object; in e.g. `course { id }`, `id` is a field of
+					// Course.
+					replaced, ok := _replaceTopLevelKeyField(updatedKey, name, oldName)
+					if !ok {
+						// The field isn't in this key at all.
+						next = append(next, updatedKey)
+						break
+					}
+					next = append(next, replaced)
+				}
+			}
+			updatedKeys = next
+		}
+		for _, updatedKey := range updatedKeys {
+			if updatedKey != key {
+				oldKeys = append(oldKeys, updatedKey)
+			}
+		}
+	}
+	return oldKeys
+}
+
+// _replaceTopLevelKeyField returns the given federation key (the "fields"
+// argument of @key, e.g. "id course { id }") with the top-level selection of
+// field replaced by replacement, and whether there was such a selection.
+// Selections nested in braces, which are fields of other types, are left
+// alone, as is the formatting of the key.
+func _replaceTopLevelKeyField(key string, field string, replacement string) (string, bool) {
+	var updated strings.Builder
+	replaced := false
+	depth := 0
+	for i := 0; i < len(key); {
+		c := key[i]
+		switch {
+		case c == '{':
+			depth++
+		case c == '}':
+			depth--
+		case _isNameStart(c):
+			// Consume the whole name.
+			start := i
+			for i < len(key) && _isNameContinue(key[i]) {
+				i++
+			}
+			name := key[start:i]
+			if depth == 0 && name == field {
+				name = replacement
+				replaced = true
+			}
+			updated.WriteString(name)
+			continue
+		}
+		updated.WriteByte(c)
+		i++
+	}
+	return updated.String(), replaced
+}
+
+// _isNameStart and _isNameContinue return whether the given byte may start,
+// or continue, a GraphQL name.
+func _isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
 }
 
-func _replaceExactWord(text string, word string, replacement string) string {
-	// The inputs are GraphQL field names, which won't have any characters that
-	// need to be escaped.
-	regex := regexp.MustCompile(`\b` + word + `\b`)
-	return regex.ReplaceAllString(text, replacement)
+func _isNameContinue(c byte) bool {
+	return _isNameStart(c) || (c >= '0' && c <= '9')
 }
 
 // _updateType returns a new type with the same shape as the passed in type but
@@ -822,6 +1957,39 @@ func _removeReplacesDirective(directives ast.DirectiveList) ast.DirectiveList {
 	return updated
 }
 
+// _definitionLocations maps each kind of top-level definition to the
+// directive location for it.
+var _definitionLocations = map[ast.DefinitionKind]ast.DirectiveLocation{
+	ast.Object:      ast.LocationObject,
+	ast.InputObject: ast.LocationInputObject,
+	ast.Interface:   ast.LocationInterface,
+	ast.Union:       ast.LocationUnion,
+	ast.Enum:        ast.LocationEnum,
+	ast.Scalar:      ast.LocationScalar,
+}
+
+// _markDeprecated returns the given description and directives, of an old
+// name at the given location, updated to say it's deprecated for the given
+// reason (see _deprecationReason). By default we add "Deprecated: <reason>"
+// to the description; with DeprecatedDirectiveOnly we instead add a
+// @deprecated directive, if it's valid at that location.
+func (r *Replacer) _markDeprecated(
+	location ast.DirectiveLocation,
+	reason string,
+	description string,
+	directives ast.DirectiveList,
+) (string, ast.DirectiveList) {
+	if r.DeprecatedDirectiveOnly && r.deprecatedLocations[location] {
+		return description, _addDeprecatedDirective(directives, reason)
+	}
+
+	deprecatedMessage := "Deprecated: " + reason
+	if description == "" {
+		return deprecatedMessage, directives
+	}
+	return description + "\n" + deprecatedMessage, directives
+}
+
 func _addDeprecatedDirective(directives ast.DirectiveList, message string) ast.DirectiveList {
 	updated := make(ast.DirectiveList, len(directives), len(directives)+1)
 	copy(updated, directives)
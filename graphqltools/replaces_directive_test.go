@@ -2,6 +2,7 @@ package graphqltools
 
 import (
 	"context"
+	"encoding/json"
 	"github.com/vektah/gqlparser/v2"
 	"os"
 	"strings"
@@ -9,6 +10,8 @@ import (
 
 	"github.com/Khan/webapp/dev/khantest"
 	"github.com/Khan/webapp/pkg/lib"
+	"github.com/StevenACoffman/gqlgen-plugins/errors/kind"
+	"github.com/StevenACoffman/simplerr/errors"
 	"github.com/vektah/gqlparser/v2/ast"
 )
 
@@ -18,7 +21,7 @@ type replaceSuite struct{ khantest.Suite }
 // that non-@replaces directives are retained in the appropriate places.
 const otherDirectiveSource = `
 	directive @test
-		on FIELD_DEFINITION | INPUT_FIELD_DEFINITION | OBJECT | UNION | ENUM | ENUM_VALUE | INPUT_OBJECT | INTERFACE | ARGUMENT_DEFINITION
+		on FIELD_DEFINITION | INPUT_FIELD_DEFINITION | OBJECT | UNION | ENUM | ENUM_VALUE | INPUT_OBJECT | INTERFACE | ARGUMENT_DEFINITION | SCALAR
 
 	directive @key(
 		fields: String!
@@ -45,6 +48,27 @@ func parse(input string) (*ast.Schema, error) {
 	return schema, nil
 }
 
+// multipleNamesReplacesDirectiveSource is a definition of @replaces which
+// includes the `names`, `reason` and `reasons` arguments.
+const multipleNamesReplacesDirectiveSource = `
+	directive @replaces(
+		name: String
+		names: [String!]
+		type: String
+		wasRequiredBeforeRename: Boolean
+		treatZeroAsUnset: Boolean
+		reason: String
+		reasons: [String!]
+	) on FIELD_DEFINITION | INPUT_FIELD_DEFINITION | OBJECT | UNION | ENUM | ENUM_VALUE | INPUT_OBJECT | INTERFACE | ARGUMENT_DEFINITION | SCALAR
+`
+
+// parseWithMultipleNames is like parse, but uses a definition of @replaces
+// which supports the `names`, `reason` and `reasons` arguments.
+func parseWithMultipleNames(input string) (*ast.Schema, error) {
+	input = multipleNamesReplacesDirectiveSource + otherDirectiveSource + input
+	return gqlparser.LoadSchema(&ast.Source{Input: input})
+}
+
 func (suite *replaceSuite) TestFieldName() {
 	schema, err := parse(`
 		type Course @test {
@@ -66,9 +90,93 @@ extend type Course {
 	suite.Require().Equal(expected, updates)
 }
 
+func (suite *replaceSuite) TestFieldsInSourceOrder() {
+	schema, err := parse(`
+		type Course {
+			title: String @replaces(name: "name")
+			kaLocale: String @replaces(name: "locale")
+			slug: String @replaces(name: "path")
+		}
+	`)
+	suite.Require().NoError(err)
+
+	updates, err := GetReplacesDirectiveUpdates(schema)
+	suite.Require().NoError(err)
+
+	expected := strings.TrimLeft(`
+extend type Course {
+    name: String @deprecated(reason: "Replaced by title.") @goField(name: "DeprecatedName")
+    locale: String @deprecated(reason: "Replaced by kaLocale.") @goField(name: "DeprecatedLocale")
+    path: String @deprecated(reason: "Replaced by slug.") @goField(name: "DeprecatedPath")
+}
+
+`, "\n")
+
+	suite.Require().Equal(expected, updates)
+}
+
+func (suite *replaceSuite) TestGoFieldPrefix() {
+	schema, err := parse(`
+		type Course {
+			kaLocale: String @replaces(name: "locale")
+		}
+	`)
+	suite.Require().NoError(err)
+
+	updates, err := GetReplacesDirectiveUpdates(schema, WithGoFieldPrefix("Legacy"))
+	suite.Require().NoError(err)
+
+	expected := strings.TrimLeft(`
+extend type Course {
+    locale: String @deprecated(reason: "Replaced by kaLocale.") @goField(name: "LegacyLocale")
+}
+
+`, "\n")
+
+	suite.Require().Equal(expected, updates)
+}
+
+func (suite *replaceSuite) TestCRLFLineEnding() {
+	schema, err := parse(`
+		type Classroom @replaces(name: "StudentList") {
+			id: String!
+		}
+	`)
+	suite.Require().NoError(err)
+
+	replacer := NewReplacer()
+	replacer.LineEnding = LineEndingCRLF
+	updates, err := replacer.GetReplacesDirectiveUpdates(schema)
+	suite.Require().NoError(err)
+
+	expected := "\"\"\"Deprecated: Replaced by Classroom.\"\"\"\r\n" +
+		"type StudentList {\r\n" +
+		"    id: String!\r\n" +
+		"}\r\n" +
+		"\r\n"
+
+	suite.Require().Equal(expected, updates)
+}
+
+func (suite *replaceSuite) TestInvalidLineEnding() {
+	schema, err := parse(`
+		type Course {
+			kaLocale: String @replaces(name: "locale")
+		}
+	`)
+	suite.Require().NoError(err)
+
+	replacer := NewReplacer()
+	replacer.LineEnding = "\r"
+	_, err = replacer.GetReplacesDirectiveUpdates(schema)
+	suite.Require().Error(err)
+	suite.Require().Contains(err.Error(), "line ending must be LF or CRLF")
+}
+
 func (suite *replaceSuite) TestFieldNameAndType() {
 	schema, err := parse(`
 		type Classroom { id: String! }
+		type StudentList { id: String! }
 		type User {
 			classrooms: [Classroom!] @replaces(name: "studentLists", type: "StudentList")
 		}
@@ -88,6 +196,37 @@ extend type User {
 	suite.Require().Equal(expected, updates)
 }
 
+func (suite *replaceSuite) TestDanglingOldType() {
+	// StudentList is neither in the schema nor the old name of a type.
+	schema, err := parse(`
+		type Classroom { id: String! }
+		type User {
+			classrooms: [Classroom!] @replaces(name: "studentLists", type: "StudentList")
+			classroom(teacherKaid: String @replaces(name: "coachKaid", type: "Kaid!")): Classroom @replaces(name: "studentList")
+		}
+	`)
+	suite.Require().NoError(err)
+
+	_, err = GetReplacesDirectiveUpdates(schema)
+	suite.Require().Error(err)
+	suite.Require().Contains(err.Error(),
+		"@replaces directive's type argument names a type that doesn't exist")
+	suite.Require().Contains(err.Error(), "oldType:StudentList")
+	suite.Require().Contains(err.Error(), "oldType:Kaid!")
+
+	// A type we'll emit under its old name is fine.
+	schema, err = parse(`
+		type Classroom @replaces(name: "StudentList") { id: String! }
+		type User {
+			classrooms: [Classroom!] @replaces(name: "studentLists", type: "StudentList")
+		}
+	`)
+	suite.Require().NoError(err)
+
+	_, err = GetReplacesDirectiveUpdates(schema)
+	suite.Require().NoError(err)
+}
+
 func (suite *replaceSuite) TestFederationKeyFieldEmitsOldKey() {
 	schema, err := parse(`
 		type UserKaLocaleCourse @key(fields: "id kaLocale kaid") {
@@ -111,6 +250,74 @@ extend type UserKaLocaleCourse @key(fields: "id locale kaid") {
 	suite.Require().Equal(expected, updates)
 }
 
+func (suite *replaceSuite) TestFederationKeyOnlyRenamesTopLevelField() {
+	schema, err := parse(`
+		type Thing @key(fields: "id { id }") {
+			id: Thing! @replaces(name: "oldId")
+		}
+	`)
+	suite.Require().NoError(err)
+
+	updates, err := GetReplacesDirectiveUpdates(schema)
+	suite.Require().NoError(err)
+
+	expected := strings.TrimLeft(`
+extend type Thing @key(fields: "oldId { id }") {
+    oldId: Thing! @deprecated(reason: "Replaced by id.") @goField(name: "DeprecatedOldId")
+}
+
+`, "\n")
+
+	suite.Require().Equal(expected, updates)
+}
+
+func (suite *replaceSuite) TestFederationKeyWithNestedSelection() {
+	schema, err := parse(`
+		type Course { id: String! }
+		type Classroom @key(fields: "course { id } kaid") {
+			course: Course!
+			kaid: String! @replaces(name: "coachKaid")
+		}
+	`)
+	suite.Require().NoError(err)
+
+	updates, err := GetReplacesDirectiveUpdates(schema)
+	suite.Require().NoError(err)
+
+	expected := strings.TrimLeft(`
+extend type Classroom @key(fields: "course { id } coachKaid") {
+    coachKaid: String! @deprecated(reason: "Replaced by kaid.") @goField(name: "DeprecatedCoachKaid")
+}
+
+`, "\n")
+
+	suite.Require().Equal(expected, updates)
+}
+
+func (suite *replaceSuite) TestFederationKeyNestedFieldOfSameNameNotRenamed() {
+	schema, err := parse(`
+		type Course { id: String! }
+		type Classroom @key(fields: "course { id }") {
+			course: Course!
+			id: String! @replaces(name: "classroomId")
+		}
+	`)
+	suite.Require().NoError(err)
+
+	updates, err := GetReplacesDirectiveUpdates(schema)
+	suite.Require().NoError(err)
+
+	// The key doesn't include Classroom.id, so it isn't updated.
+	expected := strings.TrimLeft(`
+extend type Classroom {
+    classroomId: String! @deprecated(reason: "Replaced by id.") @goField(name: "DeprecatedClassroomId")
+}
+
+`, "\n")
+
+	suite.Require().Equal(expected, updates)
+}
+
 func (suite *replaceSuite) TestArgumentName() {
 	schema, err := parse(`
 		type Classroom { id: String! }
@@ -156,6 +363,30 @@ extend type User {
 	suite.Require().Equal(expected, updates)
 }
 
+// TestArgumentNameAdditionsLoad checks that the additions for a renamed
+// argument (on a renamed field) can be loaded along with the original schema,
+// as deprecated.graphql is.
+func (suite *replaceSuite) TestArgumentNameAdditionsLoad() {
+	const source = `
+		directive @goField(name: String) on FIELD_DEFINITION | INPUT_FIELD_DEFINITION
+		type Classroom { id: String! }
+		type User {
+			classroom(teacherKaid: String @replaces(name: "coachKaid")): Classroom @replaces(name: "studentList")
+		}
+	`
+	schema, err := parse(source)
+	suite.Require().NoError(err)
+
+	updates, err := GetReplacesDirectiveUpdates(schema)
+	suite.Require().NoError(err)
+
+	updated, err := parse(source + updates)
+	suite.Require().NoError(err)
+	oldField := updated.Types["User"].Fields.ForName("studentList")
+	suite.Require().NotNil(oldField)
+	suite.Require().NotNil(oldField.Arguments.ForName("coachKaid"))
+}
+
 func (suite *replaceSuite) TestFieldMustBeReplacedIfArgumentReplaced() {
 	schema, err := parse(`
 		type Classroom { id: String! }
@@ -270,6 +501,7 @@ func (suite *replaceSuite) TestExtendedDefinitionStaysExtendedAndOmitsDescriptio
 	suite.Require().NoError(err)
 
 	expected := strings.TrimLeft(`
+# Deprecated: Replaced by Classroom.
 extend type StudentList @test {
     id: String!
 }
@@ -279,6 +511,28 @@ extend type StudentList @test {
 	suite.Require().Equal(expected, updates)
 }
 
+func (suite *replaceSuite) TestExtendedDefinitionEmitsDeprecationAsComment() {
+	schema, err := parse(`
+		extend interface CurationNode @replaces(name: "Topic") @test {
+			id: String!
+		}
+	`)
+	suite.Require().NoError(err)
+
+	updates, err := GetReplacesDirectiveUpdates(schema)
+	suite.Require().NoError(err)
+
+	expected := strings.TrimLeft(`
+# Deprecated: Replaced by CurationNode.
+extend interface Topic @test {
+    id: String!
+}
+
+`, "\n")
+
+	suite.Require().Equal(expected, updates)
+}
+
 func (suite *replaceSuite) TestObjectCanNotUseType() {
 	schema, err := parse(`
 		type Classroom @replaces(name: "StudentList", type: "StudentList") {
@@ -419,6 +673,47 @@ func (suite *replaceSuite) TestInputObjectNonListFieldNameMustSpecifyTreatZeroAs
 		err.Error(), "@replaces directive on non-list input fields must include treatZeroAsUnset:true or treatZeroAsUnset:false")
 }
 
+func (suite *replaceSuite) TestInputObjectScalarFieldAllowsTreatZeroAsUnset() {
+	schema, err := parse(`
+		input SomeInput {
+			newArg: Int @replaces(name: "oldArg", treatZeroAsUnset: true)
+		}
+	`)
+	suite.Require().NoError(err)
+
+	_, err = GetReplacesDirectiveUpdates(schema)
+	suite.Require().NoError(err)
+}
+
+func (suite *replaceSuite) TestInputObjectObjectFieldRejectsTreatZeroAsUnset() {
+	schema, err := parse(`
+		input LocaleInput { locale: String }
+		input SomeInput {
+			newArg: LocaleInput @replaces(name: "oldArg", treatZeroAsUnset: false)
+		}
+	`)
+	suite.Require().NoError(err)
+
+	_, err = GetReplacesDirectiveUpdates(schema)
+	suite.Require().Error(err)
+	suite.Require().Contains(
+		err.Error(), "@replaces directive's treatZeroAsUnset can only be used on scalar input fields")
+	suite.Require().Contains(err.Error(), "fieldType:LocaleInput")
+}
+
+func (suite *replaceSuite) TestInputObjectObjectFieldTreatZeroAsUnsetNotRequired() {
+	schema, err := parse(`
+		input LocaleInput { locale: String }
+		input SomeInput {
+			newArg: LocaleInput @replaces(name: "oldArg")
+		}
+	`)
+	suite.Require().NoError(err)
+
+	_, err = GetReplacesDirectiveUpdates(schema)
+	suite.Require().NoError(err)
+}
+
 func (suite *replaceSuite) TestInputObjectFieldTreatZeroAsUnsetNotRequiredOnLists() {
 	schema, err := parse(`
 		input SomeInput {
@@ -463,70 +758,77 @@ extend input SomeInput {
 	suite.Require().Equal(expected, updates)
 }
 
-func (suite *replaceSuite) TestInputObjectFieldMustBeNullable() {
+func (suite *replaceSuite) TestRedundantOldTypeStrict() {
 	schema, err := parse(`
 		input SomeInput {
-			newArg: String! @replaces(name: "oldArg", treatZeroAsUnset: true) @test
+			newArg: [String!] @replaces(name: "oldArg", type: "String")
+		}
+		type Query {
+			course(locale: String @replaces(name: "lang", type: "String")): String @replaces(name: "lesson")
 		}
 	`)
 	suite.Require().NoError(err)
 
+	// Redundant, but harmless, so we allow it by default.
 	_, err = GetReplacesDirectiveUpdates(schema)
+	suite.Require().NoError(err)
+
+	_, err = GetReplacesDirectiveUpdates(schema, WithStrict())
 	suite.Require().Error(err)
-	suite.Require().Contains(
-		err.Error(), "input fields using the @replaces directive must be nullable")
+	suite.Require().Equal(2, strings.Count(err.Error(),
+		"@replaces directive's type argument is the same as the current type"))
+	suite.Require().Contains(err.Error(), "field:newArg")
+	suite.Require().Contains(err.Error(), "argument:locale")
 }
 
-func (suite *replaceSuite) TestInterfaceName() {
+func (suite *replaceSuite) TestChangedOldTypeStrict() {
 	schema, err := parse(`
-		interface CurationNode @replaces(name: "Topic") @test {
-			id: String!
+		input SomeInput {
+			newArg: String @replaces(name: "oldArg", type: "Int", treatZeroAsUnset: true)
 		}
 	`)
 	suite.Require().NoError(err)
 
-	updates, err := GetReplacesDirectiveUpdates(schema)
+	_, err = GetReplacesDirectiveUpdates(schema, WithStrict())
 	suite.Require().NoError(err)
-
-	expected := strings.TrimLeft(`
-"""Deprecated: Replaced by CurationNode."""
-interface Topic @test {
-    id: String!
-}
-
-`, "\n")
-
-	suite.Require().Equal(expected, updates)
 }
 
-func (suite *replaceSuite) TestInterfaceCanNotUseType() {
+func (suite *replaceSuite) TestDeprecatedDirectiveOnlyObject() {
 	schema, err := parse(`
-		interface CurationNode @replaces(name: "Topic", type: "Topic") {
-			id: String!
+		type Classroom @replaces(name: "StudentList") @test {
+			teacherKaid: String! @replaces(name: "coachKaid")
+			students(minGrade: Int @replaces(name: "grade")): [String!]! @replaces(name: "pupils")
 		}
-	`)
-	suite.Require().NoError(err)
-
-	_, err = GetReplacesDirectiveUpdates(schema)
-	suite.Require().Error(err)
-	suite.Require().Contains(
-		err.Error(), "@replaces directive on definitions can only use `name` argument")
-}
-
-func (suite *replaceSuite) TestInterfaceField() {
-	schema, err := parse(`
-		interface CurationNode {
-			kaLocale: String @replaces(name: "locale") @test
+		input ClassroomInput {
+			teacherKaid: String @replaces(name: "coachKaid", treatZeroAsUnset: true)
 		}
 	`)
 	suite.Require().NoError(err)
 
-	updates, err := GetReplacesDirectiveUpdates(schema)
+	updates, err := GetReplacesDirectiveUpdates(schema, WithDeprecatedDirectiveOnly())
 	suite.Require().NoError(err)
 
+	// @deprecated isn't valid on objects, so we still describe StudentList
+	// as deprecated; it is valid on fields, arguments, and input fields.
 	expected := strings.TrimLeft(`
-extend interface CurationNode {
-    locale: String @test @deprecated(reason: "Replaced by kaLocale.") @goField(name: "DeprecatedLocale")
+"""Deprecated: Replaced by Classroom."""
+type StudentList @test {
+    teacherKaid: String!
+    students(minGrade: Int): [String!]!
+}
+
+extend type Classroom {
+    coachKaid: String! @deprecated(reason: "Replaced by teacherKaid.") @goField(name: "DeprecatedCoachKaid")
+    pupils(grade: Int): [String!]! @deprecated(reason: "Replaced by students.") @goField(name: "DeprecatedPupils")
+}
+
+extend type StudentList {
+    coachKaid: String! @deprecated(reason: "Replaced by teacherKaid.") @goField(name: "DeprecatedCoachKaid")
+    pupils(grade: Int): [String!]! @deprecated(reason: "Replaced by students.") @goField(name: "DeprecatedPupils")
+}
+
+extend input ClassroomInput {
+    coachKaid: String @deprecated(reason: "Replaced by teacherKaid.") @goField(name: "DeprecatedCoachKaid")
 }
 
 `, "\n")
@@ -534,25 +836,202 @@ extend interface CurationNode {
 	suite.Require().Equal(expected, updates)
 }
 
-func (suite *replaceSuite) TestObjectsThatImplementReplacedInterfaceShouldBeExtended() {
+func (suite *replaceSuite) TestWithoutGoField() {
 	schema, err := parse(`
-		interface CurationNode @replaces(name: "Topic") {
-			id: String!
+		type Classroom {
+			teacherKaid: String! @replaces(name: "coachKaid")
 		}
-
-		type Domain implements CurationNode @test {
-			id: String!
+		input ClassroomInput {
+			teacherKaid: String @replaces(name: "coachKaid", treatZeroAsUnset: true)
 		}
 	`)
 	suite.Require().NoError(err)
 
-	updates, err := GetReplacesDirectiveUpdates(schema)
+	updates, err := GetReplacesDirectiveUpdates(schema, WithoutGoField())
 	suite.Require().NoError(err)
 
 	expected := strings.TrimLeft(`
-"""Deprecated: Replaced by CurationNode."""
-interface Topic {
-    id: String!
+extend type Classroom {
+    coachKaid: String! @deprecated(reason: "Replaced by teacherKaid.")
+}
+
+extend input ClassroomInput {
+    """Deprecated: Replaced by teacherKaid."""
+    coachKaid: String
+}
+
+`, "\n")
+
+	suite.Require().Equal(expected, updates)
+}
+
+func (suite *replaceSuite) TestDeprecatedDirectiveOnlyEnum() {
+	schema, err := parse(`
+		enum ContentKind @replaces(name: "TopicKind") @test {
+			DOMAIN
+			COURSE @replaces(name: "SUBJECT")
+		}
+	`)
+	suite.Require().NoError(err)
+
+	updates, err := GetReplacesDirectiveUpdates(schema, WithDeprecatedDirectiveOnly())
+	suite.Require().NoError(err)
+
+	// @deprecated isn't valid on enums either, so we still describe
+	// TopicKind as deprecated; it is valid on enum values.
+	expected := strings.TrimLeft(`
+"""Deprecated: Replaced by ContentKind."""
+enum TopicKind @test {
+    DOMAIN
+    COURSE
+}
+
+extend enum ContentKind {
+    SUBJECT @deprecated(reason: "Replaced by COURSE.")
+}
+
+extend enum TopicKind {
+    SUBJECT @deprecated(reason: "Replaced by COURSE.")
+}
+
+`, "\n")
+
+	suite.Require().Equal(expected, updates)
+}
+
+func (suite *replaceSuite) TestInputObjectFieldMustBeNullable() {
+	schema, err := parse(`
+		input SomeInput {
+			newArg: String! @replaces(name: "oldArg", treatZeroAsUnset: true) @test
+		}
+	`)
+	suite.Require().NoError(err)
+
+	_, err = GetReplacesDirectiveUpdates(schema)
+	suite.Require().Error(err)
+	suite.Require().Contains(
+		err.Error(), "input fields using the @replaces directive must be nullable")
+}
+
+func (suite *replaceSuite) TestTreatZeroAsUnsetFields() {
+	schema, err := parse(`
+		input UpdateUserInput {
+			nickname: String @replaces(name: "displayName", treatZeroAsUnset: true)
+			kaLocale: String @replaces(name: "locale", treatZeroAsUnset: true)
+			points: Int @replaces(name: "energyPoints", treatZeroAsUnset: false)
+			childIds: [String!] @replaces(name: "childTopics")
+		}
+		input AddCourseInput @replaces(name: "AddSubjectInput") {
+			courseId: String @replaces(name: "subjectId", treatZeroAsUnset: true)
+			title: String
+		}
+		type User {
+			# Not an input field, so not included.
+			kaid: String @replaces(name: "userId")
+		}
+	`)
+	suite.Require().NoError(err)
+
+	fields, err := TreatZeroAsUnsetFields(schema)
+	suite.Require().NoError(err)
+
+	suite.Require().Equal([]FieldRef{
+		{TypeName: "AddCourseInput", FieldName: "courseId"},
+		{TypeName: "UpdateUserInput", FieldName: "kaLocale"},
+		{TypeName: "UpdateUserInput", FieldName: "nickname"},
+	}, fields)
+}
+
+func (suite *replaceSuite) TestInputObjectFieldOldTypeMustBeNullable() {
+	schema, err := parse(`
+		input SomeInput {
+			newArg: String @replaces(name: "oldArg", type: "Int!", treatZeroAsUnset: true) @test
+		}
+	`)
+	suite.Require().NoError(err)
+
+	_, err = GetReplacesDirectiveUpdates(schema)
+	suite.Require().Error(err)
+	suite.Require().Contains(
+		err.Error(), "input fields using the @replaces directive must have a nullable old type")
+}
+
+func (suite *replaceSuite) TestInterfaceName() {
+	schema, err := parse(`
+		interface CurationNode @replaces(name: "Topic") @test {
+			id: String!
+		}
+	`)
+	suite.Require().NoError(err)
+
+	updates, err := GetReplacesDirectiveUpdates(schema)
+	suite.Require().NoError(err)
+
+	expected := strings.TrimLeft(`
+"""Deprecated: Replaced by CurationNode."""
+interface Topic @test {
+    id: String!
+}
+
+`, "\n")
+
+	suite.Require().Equal(expected, updates)
+}
+
+func (suite *replaceSuite) TestInterfaceCanNotUseType() {
+	schema, err := parse(`
+		interface CurationNode @replaces(name: "Topic", type: "Topic") {
+			id: String!
+		}
+	`)
+	suite.Require().NoError(err)
+
+	_, err = GetReplacesDirectiveUpdates(schema)
+	suite.Require().Error(err)
+	suite.Require().Contains(
+		err.Error(), "@replaces directive on definitions can only use `name` argument")
+}
+
+func (suite *replaceSuite) TestInterfaceField() {
+	schema, err := parse(`
+		interface CurationNode {
+			kaLocale: String @replaces(name: "locale") @test
+		}
+	`)
+	suite.Require().NoError(err)
+
+	updates, err := GetReplacesDirectiveUpdates(schema)
+	suite.Require().NoError(err)
+
+	expected := strings.TrimLeft(`
+extend interface CurationNode {
+    locale: String @test @deprecated(reason: "Replaced by kaLocale.") @goField(name: "DeprecatedLocale")
+}
+
+`, "\n")
+
+	suite.Require().Equal(expected, updates)
+}
+
+func (suite *replaceSuite) TestObjectsThatImplementReplacedInterfaceShouldBeExtended() {
+	schema, err := parse(`
+		interface CurationNode @replaces(name: "Topic") {
+			id: String!
+		}
+
+		type Domain implements CurationNode @test {
+			id: String!
+		}
+	`)
+	suite.Require().NoError(err)
+
+	updates, err := GetReplacesDirectiveUpdates(schema)
+	suite.Require().NoError(err)
+
+	expected := strings.TrimLeft(`
+"""Deprecated: Replaced by CurationNode."""
+interface Topic {
+    id: String!
 }
 
 extend type Domain implements Topic
@@ -670,9 +1149,10 @@ func (suite *replaceSuite) TestUnionMemberOnReplacedUnion() {
 	updates, err := GetReplacesDirectiveUpdates(schema)
 	suite.Require().NoError(err)
 
+	// The old union has just the old member, as before the renames.
 	expected := strings.TrimLeft(`
 """Deprecated: Replaced by ClassroomStuff."""
-union OldClassroomStuff = Classroom
+union OldClassroomStuff = StudentList
 
 """Deprecated: Replaced by Classroom."""
 type StudentList {
@@ -681,7 +1161,47 @@ type StudentList {
 
 extend union ClassroomStuff = StudentList
 
-extend union OldClassroomStuff = StudentList
+`, "\n")
+
+	suite.Require().Equal(expected, updates)
+}
+
+func (suite *replaceSuite) TestReplacedUnionUsesOldMemberNames() {
+	schema, err := parse(`
+		union CurationNodeChild @replaces(name: "TopicChildren") = Domain | Course | Unit
+
+		type Domain @replaces(name: "Subject") {
+			id: String!
+		}
+
+		type Course {
+			id: String!
+		}
+
+		type Unit @replaces(name: "Topic") {
+			id: String!
+		}
+	`)
+	suite.Require().NoError(err)
+
+	updates, err := GetReplacesDirectiveUpdates(schema)
+	suite.Require().NoError(err)
+
+	expected := strings.TrimLeft(`
+"""Deprecated: Replaced by Domain."""
+type Subject {
+    id: String!
+}
+
+"""Deprecated: Replaced by Unit."""
+type Topic {
+    id: String!
+}
+
+"""Deprecated: Replaced by CurationNodeChild."""
+union TopicChildren = Subject | Course | Topic
+
+extend union CurationNodeChild = Subject | Topic
 
 `, "\n")
 
@@ -749,6 +1269,52 @@ extend enum ContentKind {
 	suite.Require().Equal(expected, updates)
 }
 
+func (suite *replaceSuite) TestFieldReason() {
+	schema, err := parseWithMultipleNames(`
+		type Course {
+			kaLocale: String @replaces(name: "locale", reason: "Use kaLocale; locale was ambiguous across content trees.")
+		}
+	`)
+	suite.Require().NoError(err)
+
+	updates, err := GetReplacesDirectiveUpdates(schema)
+	suite.Require().NoError(err)
+
+	expected := strings.TrimLeft(`
+extend type Course {
+    locale: String @deprecated(reason: "Use kaLocale; locale was ambiguous across content trees.") @goField(name: "DeprecatedLocale")
+}
+
+`, "\n")
+
+	suite.Require().Equal(expected, updates)
+}
+
+func (suite *replaceSuite) TestEnumValueReason() {
+	schema, err := parseWithMultipleNames(`
+		enum ContentKind {
+			DOMAIN
+			COURSE @replaces(name: "TOPIC", reason: "Topics are now called courses.")
+			UNIT @replaces(name: "SUBJECT")
+		}
+	`)
+	suite.Require().NoError(err)
+
+	updates, err := GetReplacesDirectiveUpdates(schema)
+	suite.Require().NoError(err)
+
+	// Without a reason, we fall back to the default.
+	expected := strings.TrimLeft(`
+extend enum ContentKind {
+    TOPIC @deprecated(reason: "Topics are now called courses.")
+    SUBJECT @deprecated(reason: "Replaced by UNIT.")
+}
+
+`, "\n")
+
+	suite.Require().Equal(expected, updates)
+}
+
 func (suite *replaceSuite) TestReplacedEnumValueOnReplacedEnum() {
 	schema, err := parse(`
 		enum ContentKind @replaces(name: "OldContentKind") {
@@ -796,6 +1362,729 @@ func (suite *replaceSuite) TestEnumValueCanNotUseType() {
 		err.Error(), "@replaces directive on enum values can only use `name` argument")
 }
 
+func (suite *replaceSuite) TestDuplicateReplacesDirective() {
+	// The schema validator won't let us parse this, so we build the AST by
+	// hand.
+	replaces := func(name string) *ast.Directive {
+		return &ast.Directive{
+			Name: "replaces",
+			Arguments: ast.ArgumentList{{
+				Name:  "name",
+				Value: &ast.Value{Kind: ast.StringValue, Raw: name},
+			}},
+		}
+	}
+	directives := ast.DirectiveList{replaces("oldName"), replaces("olderName")}
+
+	_, err := GetReplaceInfo(directives)
+	suite.Require().ErrorIs(err, kind.Internal)
+	suite.Require().Contains(
+		err.Error(), "@replaces directive may only be used once per node")
+}
+
+func (suite *replaceSuite) TestScalarName() {
+	schema, err := parse(`
+		scalar UserId @replaces(name: "Kaid") @test
+		type User { id: UserId! }
+	`)
+	suite.Require().NoError(err)
+
+	updates, err := GetReplacesDirectiveUpdates(schema)
+	suite.Require().NoError(err)
+
+	expected := strings.TrimLeft(`
+"""Deprecated: Replaced by UserId."""
+scalar Kaid @test
+
+`, "\n")
+
+	suite.Require().Equal(expected, updates)
+}
+
+func (suite *replaceSuite) TestScalarNameKeepsSpecifiedBy() {
+	schema, err := parse(`
+		scalar UserId
+			@replaces(name: "Kaid")
+			@specifiedBy(url: "https://example.com/user-id")
+		type User { id: UserId! }
+	`)
+	suite.Require().NoError(err)
+
+	updates, err := GetReplacesDirectiveUpdates(schema)
+	suite.Require().NoError(err)
+
+	expected := strings.TrimLeft(`
+"""Deprecated: Replaced by UserId."""
+scalar Kaid @specifiedBy(url: "https://example.com/user-id")
+
+`, "\n")
+
+	suite.Require().Equal(expected, updates)
+}
+
+func (suite *replaceSuite) TestScalarNameWithReplacedFieldType() {
+	schema, err := parse(`
+		scalar UserId @replaces(name: "Kaid")
+		type User {
+			userId: UserId! @replaces(name: "kaid", type: "Kaid")
+		}
+	`)
+	suite.Require().NoError(err)
+
+	updates, err := GetReplacesDirectiveUpdates(schema)
+	suite.Require().NoError(err)
+
+	expected := strings.TrimLeft(`
+"""Deprecated: Replaced by UserId."""
+scalar Kaid
+
+extend type User {
+    kaid: Kaid! @deprecated(reason: "Replaced by userId.") @goField(name: "DeprecatedKaid")
+}
+
+`, "\n")
+
+	suite.Require().Equal(expected, updates)
+}
+
+func (suite *replaceSuite) TestScalarCanNotUseType() {
+	schema, err := parse(`
+		scalar UserId @replaces(name: "Kaid", type: "String")
+	`)
+	suite.Require().NoError(err)
+
+	_, err = GetReplacesDirectiveUpdates(schema)
+	suite.Require().Error(err)
+	suite.Require().Contains(
+		err.Error(), "@replaces directive on definitions can only use `name` argument")
+}
+
+func _replacesDirective(name string, names ...string) *ast.Directive {
+	directive := &ast.Directive{Name: "replaces"}
+	if name != "" {
+		directive.Arguments = append(directive.Arguments, &ast.Argument{
+			Name:  "name",
+			Value: &ast.Value{Kind: ast.StringValue, Raw: name},
+		})
+	}
+	if len(names) > 0 {
+		list := &ast.Value{Kind: ast.ListValue}
+		for _, name := range names {
+			list.Children = append(list.Children, &ast.ChildValue{
+				Value: &ast.Value{Kind: ast.StringValue, Raw: name},
+			})
+		}
+		directive.Arguments = append(directive.Arguments, &ast.Argument{
+			Name:  "names",
+			Value: list,
+		})
+	}
+	return directive
+}
+
+func (suite *replaceSuite) TestGetReplaceInfoNames() {
+	replaceInfo, err := GetReplaceInfo(ast.DirectiveList{
+		_replacesDirective("kaLocale", "locale", "lang"),
+	})
+	suite.Require().NoError(err)
+
+	suite.Require().Equal("kaLocale", replaceInfo.OldName)
+	suite.Require().Equal([]string{"kaLocale", "locale", "lang"}, replaceInfo.OldNames)
+}
+
+func (suite *replaceSuite) TestGetReplaceInfoNamesOnly() {
+	replaceInfo, err := GetReplaceInfo(ast.DirectiveList{
+		_replacesDirective("", "kaLocale", "locale"),
+	})
+	suite.Require().NoError(err)
+
+	suite.Require().Equal("kaLocale", replaceInfo.OldName)
+	suite.Require().Equal([]string{"kaLocale", "locale"}, replaceInfo.OldNames)
+}
+
+func (suite *replaceSuite) TestGetReplaceInfoRequiresName() {
+	_, err := GetReplaceInfo(ast.DirectiveList{_replacesDirective("")})
+	suite.Require().Error(err)
+	suite.Require().Contains(err.Error(), "name or names required on @replaces directive")
+}
+
+func (suite *replaceSuite) TestGetReplaceInfoNamesMustBeDistinct() {
+	_, err := GetReplaceInfo(ast.DirectiveList{
+		_replacesDirective("locale", "kaLocale", "locale"),
+	})
+	suite.Require().Error(err)
+	suite.Require().Contains(err.Error(), "@replaces directive names must be distinct")
+}
+
+func (suite *replaceSuite) TestFieldMultipleNames() {
+	schema, err := parseWithMultipleNames(`
+		type Course {
+			contentLocale: String @replaces(names: ["kaLocale", "locale"])
+		}
+	`)
+	suite.Require().NoError(err)
+
+	updates, err := GetReplacesDirectiveUpdates(schema)
+	suite.Require().NoError(err)
+
+	expected := strings.TrimLeft(`
+extend type Course {
+    kaLocale: String @deprecated(reason: "Replaced by contentLocale.") @goField(name: "DeprecatedKaLocale")
+    locale: String @deprecated(reason: "Replaced by contentLocale.") @goField(name: "DeprecatedLocale")
+}
+
+`, "\n")
+
+	suite.Require().Equal(expected, updates)
+}
+
+func (suite *replaceSuite) TestFederationKeyFieldMultipleNames() {
+	schema, err := parseWithMultipleNames(`
+		type Course @key(fields: "contentLocale slug") {
+			slug: String!
+			contentLocale: String @replaces(names: ["kaLocale", "locale"])
+		}
+	`)
+	suite.Require().NoError(err)
+
+	updates, err := GetReplacesDirectiveUpdates(schema)
+	suite.Require().NoError(err)
+
+	// Each old name gets its own key.
+	expected := strings.TrimLeft(`
+extend type Course @key(fields: "kaLocale slug") @key(fields: "locale slug") {
+    kaLocale: String @deprecated(reason: "Replaced by contentLocale.") @goField(name: "DeprecatedKaLocale")
+    locale: String @deprecated(reason: "Replaced by contentLocale.") @goField(name: "DeprecatedLocale")
+}
+
+`, "\n")
+
+	suite.Require().Equal(expected, updates)
+}
+
+func (suite *replaceSuite) TestFieldMultipleNamesReasons() {
+	schema, err := parseWithMultipleNames(`
+		type Course {
+			contentLocale: String @replaces(
+				names: ["kaLocale", "locale"],
+				reasons: ["Not all content is on KA.", "Ambiguous across content trees."],
+			)
+		}
+	`)
+	suite.Require().NoError(err)
+
+	updates, err := GetReplacesDirectiveUpdates(schema)
+	suite.Require().NoError(err)
+
+	expected := strings.TrimLeft(`
+extend type Course {
+    kaLocale: String @deprecated(reason: "Not all content is on KA.") @goField(name: "DeprecatedKaLocale")
+    locale: String @deprecated(reason: "Ambiguous across content trees.") @goField(name: "DeprecatedLocale")
+}
+
+`, "\n")
+
+	suite.Require().Equal(expected, updates)
+
+	manifest, err := GetReplacesDirectiveManifest(schema)
+	suite.Require().NoError(err)
+
+	suite.Require().JSONEq(`[
+		{
+			"kind": "field",
+			"parent": "Course",
+			"oldName": "kaLocale",
+			"newName": "contentLocale",
+			"reason": "Not all content is on KA."
+		},
+		{
+			"kind": "field",
+			"parent": "Course",
+			"oldName": "locale",
+			"newName": "contentLocale",
+			"reason": "Ambiguous across content trees."
+		}
+	]`, string(manifest))
+}
+
+func (suite *replaceSuite) TestReasonsMustMatchNames() {
+	schema, err := parseWithMultipleNames(`
+		type Course {
+			contentLocale: String @replaces(names: ["kaLocale", "locale"], reasons: ["Too KA-specific."])
+		}
+	`)
+	suite.Require().NoError(err)
+
+	_, err = GetReplacesDirectiveUpdates(schema)
+	suite.Require().Error(err)
+	suite.Require().Contains(
+		err.Error(), "@replaces directive must give one of reasons per old name")
+}
+
+func (suite *replaceSuite) TestCanNotUseReasonAndReasons() {
+	schema, err := parseWithMultipleNames(`
+		type Course {
+			kaLocale: String @replaces(name: "locale", reason: "Ambiguous.", reasons: ["Ambiguous."])
+		}
+	`)
+	suite.Require().NoError(err)
+
+	_, err = GetReplacesDirectiveUpdates(schema)
+	suite.Require().Error(err)
+	suite.Require().Contains(
+		err.Error(), "@replaces directive can't use both reason and reasons")
+}
+
+func (suite *replaceSuite) TestDefinitionMultipleNames() {
+	schema, err := parseWithMultipleNames(`
+		enum ContentKind @replaces(name: "TopicKind", names: ["NodeKind"]) {
+			DOMAIN
+		}
+	`)
+	suite.Require().NoError(err)
+
+	updates, err := GetReplacesDirectiveUpdates(schema)
+	suite.Require().NoError(err)
+
+	expected := strings.TrimLeft(`
+"""Deprecated: Replaced by ContentKind."""
+enum NodeKind {
+    DOMAIN
+}
+
+"""Deprecated: Replaced by ContentKind."""
+enum TopicKind {
+    DOMAIN
+}
+
+`, "\n")
+
+	suite.Require().Equal(expected, updates)
+}
+
+func (suite *replaceSuite) TestOldNameCanNotBeNewName() {
+	schema, err := parseWithMultipleNames(`
+		type Course {
+			contentLocale: String @replaces(names: ["locale", "contentLocale"])
+		}
+	`)
+	suite.Require().NoError(err)
+
+	_, err = GetReplacesDirectiveUpdates(schema)
+	suite.Require().Error(err)
+	suite.Require().Contains(
+		err.Error(), "@replaces directive can't replace a name with itself")
+}
+
+func (suite *replaceSuite) TestArgumentCanNotUseMultipleNames() {
+	schema, err := parseWithMultipleNames(`
+		type Classroom { id: String! }
+		type User {
+			classroom(teacherKaid: String @replaces(names: ["coachKaid", "kaid"])): Classroom @replaces(name: "studentList")
+		}
+	`)
+	suite.Require().NoError(err)
+
+	_, err = GetReplacesDirectiveUpdates(schema)
+	suite.Require().Error(err)
+	suite.Require().Contains(
+		err.Error(), "@replaces directive on arguments can only replace one old name")
+}
+
+func (suite *replaceSuite) TestOldNameCollidesWithType() {
+	schema, err := parse(`
+		type Classroom @replaces(name: "StudentList") { id: String! }
+		type StudentList { id: String! }
+	`)
+	suite.Require().NoError(err)
+
+	_, err = GetReplacesDirectiveUpdates(schema)
+	suite.Require().Error(err)
+	suite.Require().Contains(
+		err.Error(), "@replaces directive old name collides with an existing name")
+	suite.Require().Contains(err.Error(), "collision:type")
+	suite.Require().Contains(err.Error(), "oldName:StudentList")
+}
+
+func (suite *replaceSuite) TestOldNameCollidesWithField() {
+	schema, err := parse(`
+		type Course {
+			kaLocale: String @replaces(name: "locale")
+			locale: String
+		}
+	`)
+	suite.Require().NoError(err)
+
+	_, err = GetReplacesDirectiveUpdates(schema)
+	suite.Require().Error(err)
+	suite.Require().Contains(
+		err.Error(), "@replaces directive old name collides with an existing name")
+	suite.Require().Contains(err.Error(), "collision:field")
+	suite.Require().Contains(err.Error(), "oldName:locale")
+}
+
+func (suite *replaceSuite) TestOldNameCollisionAllowedWhenValidating() {
+	// When validating, the schema may well include deprecated.graphql.
+	schema, err := parse(`
+		type Course {
+			kaLocale: String @replaces(name: "locale")
+			locale: String
+		}
+	`)
+	suite.Require().NoError(err)
+
+	suite.Require().NoError(ValidateReplacesDirectives(schema))
+}
+
+func (suite *replaceSuite) TestErrorListJSON() {
+	schema, err := parse(`
+		input LocaleInput { locale: String }
+		input SomeInput {
+			newArg: LocaleInput @replaces(name: "oldArg", treatZeroAsUnset: false)
+		}
+	`)
+	suite.Require().NoError(err)
+
+	err = ValidateReplacesDirectives(schema)
+	suite.Require().Error(err)
+	errs, ok := errors.GetFields(err)["errorlist"].(ErrorList)
+	suite.Require().True(ok)
+
+	marshaled, err := json.Marshal(errs)
+	suite.Require().NoError(err)
+	var entries []ErrorListEntry
+	suite.Require().NoError(json.Unmarshal(marshaled, &entries))
+	suite.Require().Len(entries, 1)
+	suite.Require().Contains(entries[0].Message,
+		"@replaces directive's treatZeroAsUnset can only be used on scalar input fields")
+	suite.Require().Equal("SomeInput", entries[0].Fields["type"])
+	suite.Require().Equal("newArg", entries[0].Fields["field"])
+	suite.Require().Equal("LocaleInput", entries[0].Fields["fieldType"])
+}
+
+func (suite *replaceSuite) TestGetReplacesDirectiveUpdatesForType() {
+	schema, err := parse(`
+		type Classroom @replaces(name: "StudentList") {
+			id: String!
+			teacherKaid: String @replaces(name: "coachKaid")
+		}
+
+		type Course @replaces(name: "Lesson") {
+			kaLocale: String @replaces(name: "locale")
+		}
+	`)
+	suite.Require().NoError(err)
+
+	updates, err := GetReplacesDirectiveUpdatesForType(schema, "Classroom")
+	suite.Require().NoError(err)
+
+	// Course was renamed too, but we only asked for Classroom.
+	expected := strings.TrimLeft(`
+"""Deprecated: Replaced by Classroom."""
+type StudentList {
+    id: String!
+    teacherKaid: String
+}
+
+extend type Classroom {
+    coachKaid: String @deprecated(reason: "Replaced by teacherKaid.") @goField(name: "DeprecatedCoachKaid")
+}
+
+extend type StudentList {
+    coachKaid: String @deprecated(reason: "Replaced by teacherKaid.") @goField(name: "DeprecatedCoachKaid")
+}
+
+`, "\n")
+	suite.Require().Equal(expected, updates)
+}
+
+func (suite *replaceSuite) TestGetReplacesDirectiveUpdatesForUnknownType() {
+	schema, err := parse(`
+		type Course {
+			kaLocale: String @replaces(name: "locale")
+		}
+	`)
+	suite.Require().NoError(err)
+
+	_, err = GetReplacesDirectiveUpdatesForType(schema, "Lesson")
+	suite.Require().ErrorIs(err, kind.NotFound)
+}
+
+// joinSource is the subset of the CSDL join metadata used by the
+// GetReplacesDirectiveUpdatesByService tests.
+const joinSource = `
+	directive @join__graph(name: String!, url: String!) on ENUM_VALUE
+	directive @join__owner(graph: join__Graph!) on INTERFACE | OBJECT
+	directive @join__field(graph: join__Graph) on FIELD_DEFINITION
+
+	enum join__Graph {
+		SERVICE_A @join__graph(name: "serviceA", url: "http://service-a")
+		SERVICE_B @join__graph(name: "serviceB", url: "http://service-b")
+	}
+`
+
+func (suite *replaceSuite) TestGetReplacesDirectiveUpdatesByService() {
+	schema, err := parse(joinSource + `
+		type Classroom @join__owner(graph: SERVICE_A) @replaces(name: "StudentList") {
+			id: String!
+		}
+
+		type Course @join__owner(graph: SERVICE_B) {
+			kaLocale: String @replaces(name: "locale")
+			classroomName: String @join__field(graph: SERVICE_A) @replaces(name: "studentListName")
+		}
+
+		enum Color {
+			RED @replaces(name: "CRIMSON")
+		}
+	`)
+	suite.Require().NoError(err)
+
+	updates, err := GetReplacesDirectiveUpdatesByService(schema)
+	suite.Require().NoError(err)
+
+	expected := map[string]string{
+		"serviceA": strings.TrimLeft(`
+"""Deprecated: Replaced by Classroom."""
+type StudentList @join__owner(graph: SERVICE_A) {
+    id: String!
+}
+
+extend type Course {
+    studentListName: String @join__field(graph: SERVICE_A) @deprecated(reason: "Replaced by classroomName.") @goField(name: "DeprecatedStudentListName")
+}
+
+`, "\n"),
+		"serviceB": strings.TrimLeft(`
+extend type Course {
+    locale: String @deprecated(reason: "Replaced by kaLocale.") @goField(name: "DeprecatedLocale")
+}
+
+`, "\n"),
+		"": strings.TrimLeft(`
+extend enum Color {
+    CRIMSON @deprecated(reason: "Replaced by RED.")
+}
+
+`, "\n"),
+	}
+
+	suite.Require().Equal(expected, updates)
+}
+
+func (suite *replaceSuite) TestGetReplacesDirectiveUpdatesByServiceNotFederated() {
+	schema, err := parse(`
+		type Course {
+			kaLocale: String @replaces(name: "locale")
+		}
+	`)
+	suite.Require().NoError(err)
+
+	_, err = GetReplacesDirectiveUpdatesByService(schema)
+	suite.Require().ErrorIs(err, ErrNotFederated)
+}
+
+func (suite *replaceSuite) TestRenameManifest() {
+	schema, err := parse(`
+		type Classroom @replaces(name: "StudentList") {
+			id: String!
+		}
+
+		type Course {
+			classroom: Classroom @replaces(name: "studentList", type: "StudentList")
+		}
+	`)
+	suite.Require().NoError(err)
+
+	manifest, err := GetReplacesDirectiveManifest(schema)
+	suite.Require().NoError(err)
+
+	suite.Require().JSONEq(`[
+		{"kind": "object", "oldName": "StudentList", "newName": "Classroom"},
+		{
+			"kind": "field",
+			"parent": "Course",
+			"oldName": "studentList",
+			"newName": "classroom",
+			"oldType": "StudentList",
+			"newType": "Classroom"
+		}
+	]`, string(manifest))
+}
+
+func (suite *replaceSuite) TestRenameManifestRequiresProcessedSchema() {
+	_, err := NewReplacer().RenameManifest()
+	suite.Require().ErrorIs(err, kind.Internal)
+}
+
+func (suite *replaceSuite) TestDirectiveArgument() {
+	schema, err := parse(`
+		directive @cacheControl(
+			maxAgeSeconds: Int @replaces(name: "maxAge")
+		) on FIELD_DEFINITION
+
+		type Course {
+			id: String! @cacheControl(maxAgeSeconds: 60)
+		}
+	`)
+	suite.Require().NoError(err)
+
+	_, err = GetReplacesDirectiveUpdates(schema)
+	suite.Require().ErrorIs(err, kind.InvalidInput)
+	suite.Require().Contains(err.Error(), "@replaces directive can't be used on directive arguments")
+	suite.Require().Contains(err.Error(), "directive:cacheControl")
+}
+
+func (suite *replaceSuite) TestRenamedFieldReferences() {
+	schema, err := parse(`
+		directive @requires(fields: String!) on FIELD_DEFINITION
+		directive @provides(fields: String!) on FIELD_DEFINITION
+
+		type Classroom @key(fields: "id teacherKaid") {
+			id: ID!
+			teacherKaid: String! @replaces(name: "coachKaid")
+		}
+
+		type Student @key(fields: "id") {
+			id: ID!
+			classroom: Classroom! @provides(fields: "coachKaid")
+			teacherName: String! @requires(fields: "classroom { id coachKaid }")
+			# References by the new name are fine.
+			teacherEmail: String! @requires(fields: "classroom { teacherKaid }")
+		}
+	`)
+	suite.Require().NoError(err)
+
+	references, err := RenamedFieldReferences(schema)
+	suite.Require().NoError(err)
+
+	teacherKaid := FieldRef{TypeName: "Classroom", FieldName: "teacherKaid"}
+	suite.Require().Len(references, 2)
+	suite.Require().Equal("provides", references[0].Directive)
+	suite.Require().Equal("Student.classroom", references[0].On)
+	suite.Require().Equal(teacherKaid, references[0].Field)
+	suite.Require().Equal("coachKaid", references[0].OldName)
+	suite.Require().NotNil(references[0].Position)
+	suite.Require().Equal("requires", references[1].Directive)
+	suite.Require().Equal("Student.teacherName", references[1].On)
+	suite.Require().Equal(teacherKaid, references[1].Field)
+	suite.Require().Equal(
+		`@requires on Student.teacherName references Classroom.teacherKaid by its old name "coachKaid"`,
+		references[1].String())
+}
+
+func (suite *replaceSuite) TestRenamedFieldReferencesIgnoresRewrittenKeys() {
+	schema, err := parse(`
+		type Classroom @key(fields: "id teacherKaid") {
+			id: ID!
+			teacherKaid: String! @replaces(name: "coachKaid")
+		}
+	`)
+	suite.Require().NoError(err)
+
+	updates, err := GetReplacesDirectiveUpdates(schema)
+	suite.Require().NoError(err)
+	suite.Require().Contains(updates, `@key(fields: "id coachKaid")`)
+
+	// Load the schema again, with the additions, plus a key naming the old
+	// field which isn't one we'd add.
+	schema, err = parse(`
+		directive @goField(name: String) on FIELD_DEFINITION | ARGUMENT_DEFINITION
+
+		type Classroom @key(fields: "id teacherKaid") @key(fields: "coachKaid") {
+			id: ID!
+			teacherKaid: String! @replaces(name: "coachKaid")
+		}
+	` + updates)
+	suite.Require().NoError(err)
+
+	references, err := RenamedFieldReferences(schema)
+	suite.Require().NoError(err)
+
+	suite.Require().Len(references, 1)
+	suite.Require().Equal("key", references[0].Directive)
+	suite.Require().Equal("Classroom", references[0].On)
+	suite.Require().Equal("coachKaid", references[0].OldName)
+}
+
+func (suite *replaceSuite) TestCheckDeprecatedSchemaUpToDate() {
+	schema, err := parse(`
+		type Classroom @replaces(name: "StudentList") {
+			teacherKaid: String! @replaces(name: "coachKaid")
+		}
+	`)
+	suite.Require().NoError(err)
+
+	deprecated, err := GetReplacesDirectiveUpdates(schema)
+	suite.Require().NoError(err)
+
+	drift, err := CheckDeprecatedSchema(schema, deprecated)
+	suite.Require().NoError(err)
+
+	suite.Require().False(drift.HasDrift())
+}
+
+func (suite *replaceSuite) TestCheckDeprecatedSchemaMissing() {
+	schema, err := parse(`
+		type Classroom @replaces(name: "StudentList") {
+			teacherKaid: String! @replaces(name: "coachKaid")
+		}
+		enum ContentKind {
+			COURSE @replaces(name: "TOPIC")
+		}
+	`)
+	suite.Require().NoError(err)
+
+	// This file predates the rename of coachKaid and TOPIC.
+	deprecated := `
+		type StudentList {
+			teacherKaid: String!
+		}
+	`
+
+	drift, err := CheckDeprecatedSchema(schema, deprecated)
+	suite.Require().NoError(err)
+
+	suite.Require().Equal(&DeprecatedSchemaDrift{
+		Missing: []string{
+			"Classroom.coachKaid",
+			"ContentKind.TOPIC",
+			"StudentList.coachKaid",
+		},
+	}, drift)
+}
+
+func (suite *replaceSuite) TestCheckDeprecatedSchemaStale() {
+	schema, err := parse(`
+		type Classroom {
+			teacherKaid: String! @replaces(name: "coachKaid")
+		}
+	`)
+	suite.Require().NoError(err)
+
+	// The @replaces directive on Classroom has since been removed.
+	deprecated := `
+		type StudentList {
+			teacherKaid: String!
+		}
+
+		extend type Classroom {
+			coachKaid: String! @deprecated(reason: "Replaced by teacherKaid.")
+		}
+
+		extend type StudentList {
+			coachKaid: String! @deprecated(reason: "Replaced by teacherKaid.")
+		}
+	`
+
+	drift, err := CheckDeprecatedSchema(schema, deprecated)
+	suite.Require().NoError(err)
+
+	suite.Require().Equal(&DeprecatedSchemaDrift{
+		Stale: []string{"StudentList", "StudentList.coachKaid"},
+	}, drift)
+}
+
 func TestReplacesDirective(t *testing.T) {
 	khantest.Run(t, new(replaceSuite))
 }
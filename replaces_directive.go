@@ -15,6 +15,7 @@ package gqlgen_plugins
 
 import (
 	_ "embed"
+	"go/types"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -38,6 +39,15 @@ import (
 // The plugin does following:
 //   - gqlgen resolver validation checks, and
 //   - code generation of input "validate and rename" functions
+//   - code generation of functions mapping renamed enum values to the values
+//     that replace them (MapDeprecated<Enum>Value, for coercing old values
+//     received as input), and back (Map<Enum>ValueToDeprecated)
+//
+// With ForwardingResolvers set, it also generates, for each renamed object
+// with resolvers, an implementation of the old object's resolver interface
+// which delegates to the new object's resolver. With InverseInputMappers set,
+// it also generates functions mapping input objects' new fields back to the
+// old ones.
 //
 // The plugin does NOT:
 //   - keep services/deprecated.graphql files up to date
@@ -47,12 +57,60 @@ import (
 // See the directive in pkg/graphql/shared-schemas/replaces_directive.graphql
 // for more information.
 type ReplacesDirective struct {
-	schemaInfo *_schemaInfo
+	// ForwardingResolvers, if set, generates a function
+	// <OldName>ResolverFrom<NewName> for each renamed object with resolvers,
+	// which returns a <OldName>Resolver that maps its obj to a <NewName> and
+	// calls the given <NewName>Resolver, so that the old object's resolvers
+	// needn't be written by hand.
+	ForwardingResolvers bool
+	// InverseInputMappers, if set, also generates a function NewToOld<Name>
+	// for each input object with renamed fields, the inverse of
+	// ValidateAndRename<Name>: it returns a copy of the input with each new
+	// field moved back to the old field it replaces (the first, if several),
+	// e.g. to log the input as a client not yet using the new names would
+	// have sent it. A new field whose type is wider than that of its old
+	// fields (say, Int64 replacing Int) is left as is, since converting it
+	// back could truncate it.
+	InverseInputMappers bool
+	// DryRun, if set, makes GenerateCode neither write nor remove its output
+	// file, but instead just record what it would do; see DryRunOutput.
+	DryRun bool
+
+	schemaInfo   *_schemaInfo
+	dryRunOutput *ReplacesDirectiveOutput
+}
+
+// Actions GenerateCode may take on its output file; see
+// ReplacesDirectiveOutput.
+const (
+	ReplacesDirectiveWrite  = "write"
+	ReplacesDirectiveRemove = "remove"
+	ReplacesDirectiveNoop   = "noop"
+)
+
+// ReplacesDirectiveOutput is what GenerateCode would do, with DryRun set.
+type ReplacesDirectiveOutput struct {
+	// Action is ReplacesDirectiveWrite, ReplacesDirectiveRemove, or
+	// ReplacesDirectiveNoop (if there's nothing to generate, nor any
+	// previously generated file to remove).
+	Action string
+	// Filename is the path of the generated file.
+	Filename string
+	// Content is what we would write to it, for ReplacesDirectiveWrite.
+	Content string
+}
+
+// DryRunOutput returns what the last call to GenerateCode would have done,
+// if DryRun is set, or nil if not.
+func (r *ReplacesDirective) DryRunOutput() *ReplacesDirectiveOutput {
+	return r.dryRunOutput
 }
 
 type _schemaInfo struct {
 	renamedTypes  map[string]*_typeInfo
 	renamedFields map[string]*_fieldInfoGroup
+	// Renamed enum values, one per old name.
+	renamedEnumValues []*_enumValueInfo
 }
 
 func (s *_schemaInfo) hasInputObjectFieldRenames() bool {
@@ -64,6 +122,10 @@ func (s *_schemaInfo) hasInputObjectFieldRenames() bool {
 	return false
 }
 
+func (s *_schemaInfo) hasEnumValueRenames() bool {
+	return len(s.renamedEnumValues) > 0
+}
+
 func (s *_schemaInfo) hasObjectRenames() bool {
 	for _, typeInfo := range s.renamedTypes {
 		if typeInfo.kind == ast.Object {
@@ -82,6 +144,9 @@ type _typeInfo struct {
 type _fieldInfoGroup struct {
 	objectKind ast.DefinitionKind
 	fields     []*_fieldInfo
+	// For an interface, the (sorted) names of the objects implementing it,
+	// on which the resolvers for its fields are configured.
+	implementers []string
 }
 
 type _fieldInfo struct {
@@ -91,6 +156,12 @@ type _fieldInfo struct {
 	treatZeroAsUnset        bool
 }
 
+type _enumValueInfo struct {
+	enumName string
+	newName  string
+	oldName  string
+}
+
 var (
 	_ plugin.Plugin        = (*ReplacesDirective)(nil)
 	_ plugin.ConfigMutator = (*ReplacesDirective)(nil)
@@ -135,14 +206,26 @@ func _validateConfig(cfg *config.Config, schemaInfo *_schemaInfo) error {
 	//     fields:
 	//       kaLocale:
 	//         resolver: true
+	//
+	// For a field of an interface, this applies to each object implementing
+	// it, whether or not the object's own field has the @replaces directive.
 	for newObjectName, fieldGroup := range schemaInfo.renamedFields {
-		if fieldGroup.objectKind != ast.Object {
+		var objectNames []string
+		switch fieldGroup.objectKind {
+		case ast.Object:
+			objectNames = []string{newObjectName}
+		case ast.Interface:
+			objectNames = fieldGroup.implementers
+		default:
 			continue
 		}
 
-		allObjectNames := []string{newObjectName}
-		if typeInfo, ok := schemaInfo.renamedTypes[newObjectName]; ok {
-			allObjectNames = append(allObjectNames, typeInfo.oldName)
+		var allObjectNames []string
+		for _, objectName := range objectNames {
+			allObjectNames = append(allObjectNames, objectName)
+			if typeInfo, ok := schemaInfo.renamedTypes[objectName]; ok {
+				allObjectNames = append(allObjectNames, typeInfo.oldName)
+			}
 		}
 
 		for _, objectName := range allObjectNames {
@@ -213,6 +296,18 @@ func _getSchemaInfo(schema *ast.Schema) (*_schemaInfo, error) {
 				return nil, err
 			}
 			if err == nil {
+				if len(replaceInfo.OldNames) > 1 {
+					// We'd need a mapper per old name, and to track several
+					// old names per type throughout; so far nobody has needed
+					// this.
+					return nil, errors.WrapWithFields(kind.NotImplemented,
+						errors.Fields{
+							"message":  "@replaces directive on objects and input objects can only replace one old name",
+							"type":     definition.Name,
+							"oldNames": replaceInfo.OldNames,
+						},
+					)
+				}
 				replacements.renamedTypes[definition.Name] = &_typeInfo{
 					kind:    definition.Kind,
 					newName: definition.Name,
@@ -226,32 +321,149 @@ func _getSchemaInfo(schema *ast.Schema) (*_schemaInfo, error) {
 				} else if err != nil {
 					return nil, err
 				}
+				if definition.Kind == ast.Object {
+					err = _validateWasRequiredBeforeRename(definition.Name, field, replaceInfo)
+					if err != nil {
+						return nil, err
+					}
+				}
 				if _, ok := replacements.renamedFields[definition.Name]; !ok {
 					replacements.renamedFields[definition.Name] = &_fieldInfoGroup{
 						objectKind: definition.Kind,
 					}
 				}
-				replacements.renamedFields[definition.Name].fields = append(
-					replacements.renamedFields[definition.Name].fields,
-					&_fieldInfo{
+				for _, oldName := range replaceInfo.OldNames {
+					replacements.renamedFields[definition.Name].fields = append(
+						replacements.renamedFields[definition.Name].fields,
+						&_fieldInfo{
+							newName:                 field.Name,
+							oldName:                 oldName,
+							wasRequiredBeforeRename: replaceInfo.WasRequiredBeforeRename,
+							treatZeroAsUnset:        replaceInfo.TreatZeroAsUnset,
+						},
+					)
+				}
+			}
+		case ast.Interface:
+			for _, field := range definition.Fields {
+				replaceInfo, err := graphqltools.GetReplaceInfo(field.Directives)
+				if errors.Is(err, kind.NotFound) {
+					continue
+				} else if err != nil {
+					return nil, err
+				}
+				fieldGroup, ok := replacements.renamedFields[definition.Name]
+				if !ok {
+					fieldGroup = &_fieldInfoGroup{objectKind: definition.Kind}
+					for _, implementer := range schema.GetPossibleTypes(definition) {
+						fieldGroup.implementers = append(fieldGroup.implementers, implementer.Name)
+					}
+					sort.Strings(fieldGroup.implementers)
+					replacements.renamedFields[definition.Name] = fieldGroup
+				}
+				for _, oldName := range replaceInfo.OldNames {
+					fieldGroup.fields = append(fieldGroup.fields, &_fieldInfo{
 						newName:                 field.Name,
-						oldName:                 replaceInfo.OldName,
+						oldName:                 oldName,
 						wasRequiredBeforeRename: replaceInfo.WasRequiredBeforeRename,
 						treatZeroAsUnset:        replaceInfo.TreatZeroAsUnset,
-					},
-				)
+					})
+				}
+			}
+		case ast.Enum:
+			for _, enumValue := range definition.EnumValues {
+				replaceInfo, err := graphqltools.GetReplaceInfo(enumValue.Directives)
+				if errors.Is(err, kind.NotFound) {
+					continue
+				} else if err != nil {
+					return nil, err
+				}
+				for _, oldName := range replaceInfo.OldNames {
+					replacements.renamedEnumValues = append(
+						replacements.renamedEnumValues,
+						&_enumValueInfo{
+							enumName: definition.Name,
+							newName:  enumValue.Name,
+							oldName:  oldName,
+						},
+					)
+				}
 			}
 		}
 	}
 	return replacements, nil
 }
 
+// _validateWasRequiredBeforeRename checks that wasRequiredBeforeRename, if
+// given on a renamed object field, agrees with whether the old field is
+// non-null, which it is exactly when the new field is: the directive's `type`
+// argument only replaces the named type.  On input fields, which must be
+// nullable, it instead says whether the generated code requires that one of
+// the old and new be set, so there's nothing to check.
+func _validateWasRequiredBeforeRename(
+	typeName string,
+	field *ast.FieldDefinition,
+	replaceInfo *graphqltools.ReplaceInfo,
+) error {
+	arg := field.Directives.ForName("replaces").Arguments.ForName("wasRequiredBeforeRename")
+	if arg == nil {
+		return nil
+	}
+	if replaceInfo.WasRequiredBeforeRename != field.Type.NonNull {
+		return errors.WrapWithFields(kind.InvalidInput,
+			errors.Fields{
+				"message":                 "@replaces directive's wasRequiredBeforeRename doesn't match the old field's nullability",
+				"type":                    typeName,
+				"field":                   field.Name,
+				"fieldType":               field.Type.String(),
+				"wasRequiredBeforeRename": replaceInfo.WasRequiredBeforeRename,
+			},
+		)
+	}
+	return nil
+}
+
 //go:embed replaces_directive.gotpl
 var _template string
 
 type _templateData struct {
-	Objects      []_templateDataObjectMapper
-	InputObjects []_templateDataInputObject
+	Objects             []_templateDataObjectMapper
+	InputObjects        []_templateDataInputObject
+	Enums               []_templateDataEnum
+	ForwardingResolvers []_templateDataForwardingResolver
+	// InverseInputMappers is as for ReplacesDirective.
+	InverseInputMappers bool
+}
+
+type _templateDataForwardingResolver struct {
+	NewGoName string
+	OldGoName string
+	Methods   []_templateDataResolverMethod
+}
+
+type _templateDataResolverMethod struct {
+	Name   string
+	Args   []_templateDataResolverArg
+	Result types.Type
+}
+
+type _templateDataResolverArg struct {
+	Name   string
+	GoType types.Type
+}
+
+type _templateDataEnum struct {
+	Name string
+	// Maps each old value to its new value.
+	Values []_templateDataEnumValue
+	// Maps each new value back to its old value. If a value replaces several
+	// old values, we map it back to the first.
+	DeprecatedValues []_templateDataEnumValue
+}
+
+type _templateDataEnumValue struct {
+	NewGoName string
+	OldGoName string
 }
 
 type _templateDataInputObject struct {
@@ -272,6 +484,19 @@ type _templateDataField struct {
 	OldGoName               string
 	WasRequiredBeforeRename bool
 	TreatZeroAsUnset        bool
+	// ConvertTo, if set, is the type to which we must convert the (pointed-to)
+	// value of the old field to assign it to the new one; see
+	// _conversionTarget.
+	ConvertTo types.Type
+	// MapBack is set if this is the old name to which the inverse mapper (see
+	// ReplacesDirective.InverseInputMappers) maps the new field back: the
+	// first of the field's old names whose type isn't narrower than the new
+	// one. (Converting a widened value back could truncate it.)
+	MapBack bool
+	// ConvertBackTo, if set, is the type to which we must convert the
+	// (pointed-to) value of the new field to assign it to the old one. It's
+	// only set if ConvertTo is and both have the same underlying kind.
+	ConvertBackTo types.Type
 }
 
 func (r *ReplacesDirective) GenerateCode(data *codegen.Data) error {
@@ -279,7 +504,16 @@ func (r *ReplacesDirective) GenerateCode(data *codegen.Data) error {
 
 	// If there are no replacements, remove any existing generated file, and
 	// we're done.
-	if !r.schemaInfo.hasInputObjectFieldRenames() && !r.schemaInfo.hasObjectRenames() {
+	if !r.schemaInfo.hasInputObjectFieldRenames() && !r.schemaInfo.hasObjectRenames() &&
+		!r.schemaInfo.hasEnumValueRenames() {
+		if r.DryRun {
+			action := ReplacesDirectiveNoop
+			if _, err := os.Stat(genfilePath); err == nil {
+				action = ReplacesDirectiveRemove
+			}
+			r.dryRunOutput = &ReplacesDirectiveOutput{Action: action, Filename: genfilePath}
+			return nil
+		}
 		err := os.Remove(genfilePath)
 		// There's nothing to remove if the file has never been generated!
 		if os.IsNotExist(err) {
@@ -292,16 +526,54 @@ func (r *ReplacesDirective) GenerateCode(data *codegen.Data) error {
 	if err != nil {
 		return err
 	}
+	if r.ForwardingResolvers {
+		templateData.ForwardingResolvers, err = _constructForwardingResolvers(data, r.schemaInfo)
+		if err != nil {
+			return err
+		}
+	}
+	templateData.InverseInputMappers = r.InverseInputMappers
+
+	renderPath := genfilePath
+	if r.DryRun {
+		// gqlgen can only render to a file, and the imports it generates
+		// depend on the file's directory, so we render to a temporary file
+		// alongside the real one.  (The leading dot makes the go tool ignore
+		// it in the meantime.)
+		tmpfile, err := os.CreateTemp(filepath.Dir(genfilePath), ".replaces_directive-*.go")
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		renderPath = tmpfile.Name()
+		defer os.Remove(renderPath)
+		err = tmpfile.Close()
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
 
 	err = templates.Render(templates.Options{
 		PackageName:     data.Config.Exec.Package,
-		Filename:        genfilePath,
+		Filename:        renderPath,
 		GeneratedHeader: true, // include "DO NOT EDIT" line
 		Template:        _template,
 		Data:            templateData,
 		Packages:        data.Config.Packages,
 	})
-	return errors.WithStack(err)
+	if err != nil || !r.DryRun {
+		return errors.WithStack(err)
+	}
+
+	content, err := os.ReadFile(renderPath)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	r.dryRunOutput = &ReplacesDirectiveOutput{
+		Action:   ReplacesDirectiveWrite,
+		Filename: genfilePath,
+		Content:  string(content),
+	}
+	return nil
 }
 
 func _constructTemplateData(data *codegen.Data, schemaInfo *_schemaInfo) (*_templateData, error) {
@@ -375,6 +647,8 @@ func _constructTemplateData(data *codegen.Data, schemaInfo *_schemaInfo) (*_temp
 			Name: newObjectName,
 		}
 
+		// The fields are in the order of the old names in each directive.
+		mappedBack := make(map[string]bool)
 		for _, fieldInfo := range fieldGroup.fields {
 			newFieldData, err := _getInputField(data, newObjectName, fieldInfo.newName)
 			if err != nil {
@@ -385,19 +659,37 @@ func _constructTemplateData(data *codegen.Data, schemaInfo *_schemaInfo) (*_temp
 				return nil, err
 			}
 
-			newType := newFieldData.TypeReference.GO.String()
-			oldType := oldFieldData.TypeReference.GO.String()
-
-			if newType != oldType {
+			newType := newFieldData.TypeReference.GO
+			oldType := oldFieldData.TypeReference.GO
+			convertTo, ok := _conversionTarget(newType, oldType)
+			if !ok {
 				return nil, errors.WrapWithFields(kind.NotImplemented,
 					errors.Fields{
 						"message":  "don't know how to map between different input type fields",
 						"newField": fieldInfo.newName,
 						"oldField": fieldInfo.oldName,
+						"newType":  newType.String(),
+						"oldType":  oldType.String(),
 					},
 				)
 			}
 
+			var convertBackTo types.Type
+			mapBack := !mappedBack[fieldInfo.newName]
+			if convertTo != nil {
+				// _conversionTarget only converts between pointers to basic
+				// types.
+				oldElem := oldType.(*types.Pointer).Elem()
+				if convertTo.Underlying().(*types.Basic).Kind() ==
+					oldElem.Underlying().(*types.Basic).Kind() {
+					convertBackTo = oldElem
+				} else {
+					// The old type is narrower, so we leave the inverse
+					// mapping to the next old name, if any.
+					mapBack = false
+				}
+			}
+
 			inputObject.Fields = append(inputObject.Fields, _templateDataField{
 				NewName:                 fieldInfo.newName,
 				OldName:                 fieldInfo.oldName,
@@ -405,12 +697,20 @@ func _constructTemplateData(data *codegen.Data, schemaInfo *_schemaInfo) (*_temp
 				OldGoName:               oldFieldData.GoFieldName,
 				WasRequiredBeforeRename: fieldInfo.wasRequiredBeforeRename,
 				TreatZeroAsUnset:        fieldInfo.treatZeroAsUnset,
+				ConvertTo:               convertTo,
+				MapBack:                 mapBack,
+				ConvertBackTo:           convertBackTo,
 			})
+			mappedBack[fieldInfo.newName] = mappedBack[fieldInfo.newName] || mapBack
 		}
 
 		// Make sure field order in the generated file is stable.
 		sort.Slice(inputObject.Fields, func(i, j int) bool {
-			return inputObject.Fields[i].NewName < inputObject.Fields[j].NewName
+			if inputObject.Fields[i].NewName != inputObject.Fields[j].NewName {
+				return inputObject.Fields[i].NewName < inputObject.Fields[j].NewName
+			}
+			// A field may replace several old names.
+			return inputObject.Fields[i].OldName < inputObject.Fields[j].OldName
 		})
 
 		templateData.InputObjects = append(templateData.InputObjects, inputObject)
@@ -424,6 +724,46 @@ func _constructTemplateData(data *codegen.Data, schemaInfo *_schemaInfo) (*_temp
 		}
 	}
 
+	// Construct enum value mappers. The Go constants for both the new and old
+	// values are in the same (generated) enum type, since the old values are
+	// added to the enum by deprecated.graphql.
+	enumsByName := make(map[string]*_templateDataEnum)
+	var enumNames []string
+	for _, enumValueInfo := range schemaInfo.renamedEnumValues {
+		enum, ok := enumsByName[enumValueInfo.enumName]
+		if !ok {
+			enum = &_templateDataEnum{Name: enumValueInfo.enumName}
+			enumsByName[enumValueInfo.enumName] = enum
+			enumNames = append(enumNames, enumValueInfo.enumName)
+		}
+		value := _templateDataEnumValue{
+			NewGoName: EnumValueGoName(enumValueInfo.enumName, enumValueInfo.newName),
+			OldGoName: EnumValueGoName(enumValueInfo.enumName, enumValueInfo.oldName),
+		}
+		enum.Values = append(enum.Values, value)
+		isFirstOldName := true
+		for _, deprecatedValue := range enum.DeprecatedValues {
+			if deprecatedValue.NewGoName == value.NewGoName {
+				isFirstOldName = false
+				break
+			}
+		}
+		if isFirstOldName {
+			enum.DeprecatedValues = append(enum.DeprecatedValues, value)
+		}
+	}
+	sort.Strings(enumNames)
+	for _, enumName := range enumNames {
+		enum := enumsByName[enumName]
+		sort.Slice(enum.Values, func(i, j int) bool {
+			return enum.Values[i].OldGoName < enum.Values[j].OldGoName
+		})
+		sort.Slice(enum.DeprecatedValues, func(i, j int) bool {
+			return enum.DeprecatedValues[i].NewGoName < enum.DeprecatedValues[j].NewGoName
+		})
+		templateData.Enums = append(templateData.Enums, *enum)
+	}
+
 	// Make sure object order in the generated file is stable.
 	sort.Slice(templateData.Objects, func(i, j int) bool {
 		return templateData.Objects[i].NewGoName < templateData.Objects[j].NewGoName
@@ -435,6 +775,117 @@ func _constructTemplateData(data *codegen.Data, schemaInfo *_schemaInfo) (*_temp
 	return &templateData, nil
 }
 
+// _constructForwardingResolvers returns the template data for the forwarding
+// resolvers (see ReplacesDirective.ForwardingResolvers) of the renamed
+// objects with resolvers. Each resolver method of the old object delegates
+// to the new object's method of the same name: the old object has the same
+// fields as the new one, and the new object is extended with the old field
+// names, so this is always the corresponding field.
+func _constructForwardingResolvers(
+	data *codegen.Data,
+	schemaInfo *_schemaInfo,
+) ([]_templateDataForwardingResolver, error) {
+	var resolvers []_templateDataForwardingResolver
+	for _, typeInfo := range schemaInfo.renamedTypes {
+		if typeInfo.kind != ast.Object {
+			continue
+		}
+		newObject := data.Objects.ByName(typeInfo.newName)
+		oldObject := data.Objects.ByName(typeInfo.oldName)
+		if newObject == nil || oldObject == nil {
+			return nil, errors.WrapWithFields(kind.Internal,
+				errors.Fields{
+					"message": "missing object in schema",
+					"newType": typeInfo.newName,
+					"oldType": typeInfo.oldName})
+		}
+		if !oldObject.HasResolvers() {
+			continue
+		}
+
+		resolver := _templateDataForwardingResolver{
+			NewGoName: newObject.Name, // Assume the GraphQL and Go name match
+			OldGoName: oldObject.Name, // Assume the GraphQL and Go name match
+		}
+		for _, oldField := range oldObject.Fields {
+			if !oldField.IsResolver {
+				continue
+			}
+			newField := _getResolverField(newObject, oldField.GoFieldName)
+			if newField == nil || !_sameResolverSignature(newField, oldField) {
+				return nil, errors.WrapWithFields(kind.InvalidInput,
+					errors.Fields{
+						"message": "could not generate forwarding resolver for renamed type; resolvers do not match",
+						"newType": typeInfo.newName,
+						"oldType": typeInfo.oldName,
+						"field":   oldField.Name,
+					},
+				)
+			}
+
+			method := _templateDataResolverMethod{
+				Name:   oldField.GoFieldName,
+				Result: oldField.TypeReference.GO,
+			}
+			for _, arg := range oldField.Args {
+				method.Args = append(method.Args, _templateDataResolverArg{
+					Name:   arg.VarName,
+					GoType: arg.TypeReference.GO,
+				})
+			}
+			resolver.Methods = append(resolver.Methods, method)
+		}
+		sort.Slice(resolver.Methods, func(i, j int) bool {
+			return resolver.Methods[i].Name < resolver.Methods[j].Name
+		})
+		resolvers = append(resolvers, resolver)
+	}
+
+	sort.Slice(resolvers, func(i, j int) bool {
+		return resolvers[i].NewGoName < resolvers[j].NewGoName
+	})
+	return resolvers, nil
+}
+
+// _getResolverField returns the field of the given object with the given Go
+// name, if it has a resolver.
+func _getResolverField(object *codegen.Object, goFieldName string) *codegen.Field {
+	for _, field := range object.Fields {
+		if field.IsResolver && field.GoFieldName == goFieldName {
+			return field
+		}
+	}
+	return nil
+}
+
+// _sameResolverSignature returns whether the resolvers of the given fields,
+// of a renamed object and its old name, take the same arguments (other than
+// obj) and return the same type.
+func _sameResolverSignature(newField *codegen.Field, oldField *codegen.Field) bool {
+	if !types.Identical(newField.TypeReference.GO, oldField.TypeReference.GO) ||
+		len(newField.Args) != len(oldField.Args) {
+		return false
+	}
+	for i, arg := range newField.Args {
+		if !types.Identical(arg.TypeReference.GO, oldField.Args[i].TypeReference.GO) {
+			return false
+		}
+	}
+	return true
+}
+
+// EnumValueGoName returns the name of the Go constant gqlgen generates for
+// the given value of the given enum, e.g. "ContentKindTopic" for the value
+// TOPIC of enum ContentKind, or "ErrorCodeURLNotFound" for URL_NOT_FOUND.
+//
+// Unlike renamed fields, renamed enum values don't get a @goField directive
+// (there's no such thing for enum values), so mapping code that needs to
+// refer to the constant for an old enum value name can use this to compute
+// it the same way gqlgen's modelgen does.
+func EnumValueGoName(enumName string, valueName string) string {
+	return templates.ToGoModelName(enumName, valueName)
+}
+
 func _getInputField(
 	data *codegen.Data,
 	objectName string,
@@ -455,3 +906,53 @@ func _getInputField(
 		"fieldName":  fieldName,
 	})
 }
+
+// _widenings maps each numeric kind to the kinds which can represent all of
+// its values, and so to which we can safely convert it.
+var _widenings = map[types.BasicKind][]types.BasicKind{
+	types.Int8:    {types.Int16, types.Int32, types.Int64, types.Int, types.Float32, types.Float64},
+	types.Int16:   {types.Int32, types.Int64, types.Int, types.Float32, types.Float64},
+	types.Int32:   {types.Int64, types.Int, types.Float64},
+	types.Int:     {types.Int64},
+	types.Float32: {types.Float64},
+}
+
+// _conversionTarget returns how to map a renamed input field's old value to
+// its new type.  If the Go types are the same, that's a plain assignment, and
+// it returns nil.  Otherwise, if both are pointers (as for nullable scalars)
+// to types we can safely convert between -- those with the same underlying
+// type (say, string and a custom scalar based on it) or a numeric type and a
+// wider one -- it returns the new type's element type, to convert to.  If
+// not, it returns false.
+func _conversionTarget(newType, oldType types.Type) (types.Type, bool) {
+	if types.Identical(newType, oldType) {
+		return nil, true
+	}
+	newPtr, ok := newType.(*types.Pointer)
+	if !ok {
+		return nil, false
+	}
+	oldPtr, ok := oldType.(*types.Pointer)
+	if !ok {
+		return nil, false
+	}
+	newBasic, ok := newPtr.Elem().Underlying().(*types.Basic)
+	if !ok {
+		return nil, false
+	}
+	oldBasic, ok := oldPtr.Elem().Underlying().(*types.Basic)
+	if !ok {
+		return nil, false
+	}
+
+	if newBasic.Kind() == oldBasic.Kind() {
+		// e.g. String and a custom scalar based on string
+		return newPtr.Elem(), true
+	}
+	for _, wider := range _widenings[oldBasic.Kind()] {
+		if wider == newBasic.Kind() {
+			return newPtr.Elem(), true
+		}
+	}
+	return nil, false
+}
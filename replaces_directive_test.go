@@ -2,16 +2,24 @@ package gqlgen_plugins
 
 import (
 	"context"
+	"go/types"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"text/template"
 
 	"github.com/99designs/gqlgen/codegen"
 	"github.com/99designs/gqlgen/codegen/config"
+	"github.com/StevenACoffman/simplerr/errors"
 	"github.com/vektah/gqlparser/v2"
 	"github.com/vektah/gqlparser/v2/ast"
 
 	"github.com/Khan/webapp/dev/khantest"
 	"github.com/Khan/webapp/pkg/lib"
+
+	"github.com/StevenACoffman/gqlgen-plugins/errors/kind"
 )
 
 type replacesSuite struct{ khantest.Suite }
@@ -83,6 +91,38 @@ func (suite *replacesSuite) TestGetSchemaInfo() {
 	suite.Require().Equal(expected, schemaInfo)
 }
 
+func (suite *replacesSuite) TestGetSchemaInfoWasRequiredButNullable() {
+	schema, err := parse(`
+		type Domain {
+			kaLocale: String @replaces(name: "locale", wasRequiredBeforeRename: true)
+		}
+	`)
+	suite.Require().NoError(err)
+
+	_, err = _getSchemaInfo(schema)
+	suite.Require().Error(err)
+	suite.Require().True(errors.Is(err, kind.InvalidInput))
+	suite.Require().Contains(err.Error(),
+		"wasRequiredBeforeRename doesn't match the old field's nullability")
+	suite.Require().Contains(err.Error(), "kaLocale")
+}
+
+func (suite *replacesSuite) TestGetSchemaInfoWasNotRequiredButNonNull() {
+	schema, err := parse(`
+		type Domain {
+			kaLocale: String! @replaces(name: "locale", wasRequiredBeforeRename: false)
+		}
+	`)
+	suite.Require().NoError(err)
+
+	_, err = _getSchemaInfo(schema)
+	suite.Require().Error(err)
+	suite.Require().True(errors.Is(err, kind.InvalidInput))
+	suite.Require().Contains(err.Error(),
+		"wasRequiredBeforeRename doesn't match the old field's nullability")
+	suite.Require().Contains(err.Error(), "kaLocale")
+}
+
 func (suite *replacesSuite) TestValiateConfigObjectResolversMatch() {
 	schemaInfo := &_schemaInfo{
 		renamedTypes: map[string]*_typeInfo{
@@ -254,6 +294,55 @@ func (suite *replacesSuite) TestValiateConfigFieldOldResolverMissing() {
 		err.Error(), "renamed fields must have matching resolver configurations")
 }
 
+func (suite *replacesSuite) TestValiateConfigInterfaceFieldResolversDoNotMatch() {
+	// The implementations don't repeat the @replaces directive, but their
+	// resolvers must still match.
+	schema, err := parse(`
+		interface Content {
+			kaLocale: String @replaces(name: "locale")
+		}
+
+		type Course implements Content {
+			kaLocale: String
+		}
+
+		type Unit implements Content {
+			kaLocale: String
+		}
+	`)
+	suite.Require().NoError(err)
+
+	schemaInfo, err := _getSchemaInfo(schema)
+	suite.Require().NoError(err)
+	suite.Require().Equal(&_fieldInfoGroup{
+		objectKind:   ast.Interface,
+		fields:       []*_fieldInfo{{newName: "kaLocale", oldName: "locale"}},
+		implementers: []string{"Course", "Unit"},
+	}, schemaInfo.renamedFields["Content"])
+
+	cfg := &config.Config{
+		Models: config.TypeMap{
+			"Course": config.TypeMapEntry{
+				Fields: map[string]config.TypeMapField{
+					"kaLocale": {Resolver: true},
+					"locale":   {Resolver: true},
+				},
+			},
+			"Unit": config.TypeMapEntry{
+				Fields: map[string]config.TypeMapField{
+					"kaLocale": {Resolver: true},
+				},
+			},
+		},
+	}
+
+	err = _validateConfig(cfg, schemaInfo)
+	suite.Require().Error(err)
+	suite.Require().Contains(
+		err.Error(), "renamed fields must have matching resolver configurations")
+	suite.Require().Contains(err.Error(), "objectName:Unit")
+}
+
 func (suite *replacesSuite) TestConstructTemplateDataConstructsObjectMapperData() {
 	schemaInfo := &_schemaInfo{
 		renamedTypes: map[string]*_typeInfo{
@@ -419,6 +508,532 @@ func (suite *replacesSuite) TestConstructTemplateDataObjectFieldsDoNotMatch() {
 	)
 }
 
+// _inputRenameData returns codegen data for a DomainInput with a kaLocaleId
+// field of the given type which replaces a locale field of the other.
+func _inputRenameData(newType types.Type, oldType types.Type) *codegen.Data {
+	field := func(name string, goName string, typ types.Type) *codegen.Field {
+		return &codegen.Field{
+			FieldDefinition: &ast.FieldDefinition{Name: name},
+			GoFieldName:     goName,
+			TypeReference:   &config.TypeReference{GO: typ},
+		}
+	}
+	return &codegen.Data{
+		Inputs: codegen.Objects{
+			{
+				Definition: &ast.Definition{Kind: ast.InputObject, Name: "DomainInput"},
+				Fields: []*codegen.Field{
+					field("kaLocaleId", "KaLocaleID", newType),
+					field("locale", "Locale", oldType),
+				},
+			},
+		},
+	}
+}
+
+var _localeRenameSchemaInfo = &_schemaInfo{
+	renamedFields: map[string]*_fieldInfoGroup{
+		"DomainInput": {
+			objectKind: ast.InputObject,
+			fields:     []*_fieldInfo{{newName: "kaLocaleId", oldName: "locale"}},
+		},
+	},
+}
+
+func (suite *replacesSuite) TestConstructTemplateDataConvertsInputFieldTypes() {
+	localeID := types.NewNamed(
+		types.NewTypeName(0, _testGraphQLPkg, "LocaleID", nil), types.Typ[types.String], nil)
+	tests := []struct {
+		name          string
+		newType       types.Type
+		oldType       types.Type
+		convertTo     types.Type
+		convertBackTo types.Type
+		mapBack       bool
+	}{
+		{"String to ID", types.Typ[types.String], types.Typ[types.String], nil, nil, true},
+		{"String to custom ID scalar", localeID, types.Typ[types.String], localeID, types.Typ[types.String], true},
+		{"custom ID scalar to String", types.Typ[types.String], localeID, types.Typ[types.String], localeID, true},
+		// Converting widened values back could truncate them.
+		{"Int to Int64", types.Typ[types.Int64], types.Typ[types.Int], types.Typ[types.Int64], nil, false},
+		{"Int to Float", types.Typ[types.Float64], types.Typ[types.Int32], types.Typ[types.Float64], nil, false},
+	}
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			templateData, err := _constructTemplateData(
+				_inputRenameData(types.NewPointer(test.newType), types.NewPointer(test.oldType)),
+				_localeRenameSchemaInfo)
+			suite.Require().NoError(err)
+			suite.Require().Len(templateData.InputObjects, 1)
+			suite.Require().Equal(test.convertTo, templateData.InputObjects[0].Fields[0].ConvertTo)
+			suite.Require().Equal(
+				test.convertBackTo, templateData.InputObjects[0].Fields[0].ConvertBackTo)
+			suite.Require().Equal(test.mapBack, templateData.InputObjects[0].Fields[0].MapBack)
+		})
+	}
+}
+
+func (suite *replacesSuite) TestConstructTemplateDataInputFieldTypesIncompatible() {
+	course := types.NewNamed(
+		types.NewTypeName(0, _testGraphQLPkg, "Course", nil), types.NewStruct(nil, nil), nil)
+	tests := []struct {
+		name    string
+		newType types.Type
+		oldType types.Type
+	}{
+		{"struct to Int", types.NewPointer(types.Typ[types.Int]), types.NewPointer(course)},
+		{"Int64 to Int32", types.NewPointer(types.Typ[types.Int32]), types.NewPointer(types.Typ[types.Int64])},
+		{"list of Int to list of Int64", types.NewSlice(types.Typ[types.Int64]), types.NewSlice(types.Typ[types.Int])},
+	}
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			_, err := _constructTemplateData(
+				_inputRenameData(test.newType, test.oldType), _localeRenameSchemaInfo)
+			suite.Require().Error(err)
+			suite.Require().True(errors.Is(err, kind.NotImplemented))
+			suite.Require().Contains(
+				err.Error(), "don't know how to map between different input type fields")
+		})
+	}
+}
+
+func (suite *replacesSuite) TestRenderInputFieldConversion() {
+	src, err := os.ReadFile("replaces_directive.gotpl")
+	suite.Require().NoError(err)
+	tmpl, err := template.New("replaces_directive.gotpl").Funcs(_testTemplateFuncs).Parse(string(src))
+	suite.Require().NoError(err)
+
+	var out strings.Builder
+	suite.Require().NoError(tmpl.Execute(&out, &_templateData{
+		InputObjects: []_templateDataInputObject{{
+			Name: "DomainInput",
+			Fields: []_templateDataField{{
+				NewName:   "kaLocaleId",
+				OldName:   "locale",
+				NewGoName: "KaLocaleID",
+				OldGoName: "Locale",
+				ConvertTo: types.Typ[types.Int64],
+			}},
+		}},
+	}))
+	// Ignore the template's whitespace.
+	rendered := strings.Join(strings.Fields(out.String()), " ")
+
+	suite.Require().Contains(rendered,
+		"if newIsSet { input.KaLocaleID = new } else { if old != nil { "+
+			"converted := int64(*old) input.KaLocaleID = &converted } else { "+
+			"input.KaLocaleID = nil } }")
+}
+
+func (suite *replacesSuite) TestConstructTemplateDataMapsBackToFirstOldName() {
+	data := _inputRenameData(
+		types.NewPointer(types.Typ[types.String]), types.NewPointer(types.Typ[types.String]))
+	data.Inputs[0].Fields = append(data.Inputs[0].Fields, &codegen.Field{
+		FieldDefinition: &ast.FieldDefinition{Name: "kaLocale"},
+		GoFieldName:     "KaLocale",
+		TypeReference:   &config.TypeReference{GO: types.NewPointer(types.Typ[types.String])},
+	})
+	schemaInfo := &_schemaInfo{
+		renamedFields: map[string]*_fieldInfoGroup{
+			"DomainInput": {
+				objectKind: ast.InputObject,
+				fields: []*_fieldInfo{
+					{newName: "kaLocaleId", oldName: "locale"},
+					{newName: "kaLocaleId", oldName: "kaLocale"},
+				},
+			},
+		},
+	}
+
+	templateData, err := _constructTemplateData(data, schemaInfo)
+	suite.Require().NoError(err)
+
+	suite.Require().Len(templateData.InputObjects, 1)
+	fields := templateData.InputObjects[0].Fields
+	suite.Require().Len(fields, 2)
+	// The fields are sorted by old name, but we map back to the first one in
+	// the directive.
+	suite.Require().Equal("kaLocale", fields[0].OldName)
+	suite.Require().False(fields[0].MapBack)
+	suite.Require().Equal("locale", fields[1].OldName)
+	suite.Require().True(fields[1].MapBack)
+}
+
+func (suite *replacesSuite) TestConstructTemplateDataDoesNotMapBackToNarrowerOldName() {
+	data := _inputRenameData(
+		types.NewPointer(types.Typ[types.Int64]), types.NewPointer(types.Typ[types.Int]))
+	data.Inputs[0].Fields = append(data.Inputs[0].Fields, &codegen.Field{
+		FieldDefinition: &ast.FieldDefinition{Name: "kaLocale"},
+		GoFieldName:     "KaLocale",
+		TypeReference:   &config.TypeReference{GO: types.NewPointer(types.Typ[types.Int64])},
+	})
+	schemaInfo := &_schemaInfo{
+		renamedFields: map[string]*_fieldInfoGroup{
+			"DomainInput": {
+				objectKind: ast.InputObject,
+				fields: []*_fieldInfo{
+					{newName: "kaLocaleId", oldName: "locale"},
+					{newName: "kaLocaleId", oldName: "kaLocale"},
+				},
+			},
+		},
+	}
+
+	templateData, err := _constructTemplateData(data, schemaInfo)
+	suite.Require().NoError(err)
+
+	suite.Require().Len(templateData.InputObjects, 1)
+	fields := templateData.InputObjects[0].Fields
+	suite.Require().Len(fields, 2)
+	// Converting back to locale's Int could truncate, so we map back to
+	// kaLocale instead, though it's the second old name.
+	suite.Require().Equal("kaLocale", fields[0].OldName)
+	suite.Require().True(fields[0].MapBack)
+	suite.Require().Equal("locale", fields[1].OldName)
+	suite.Require().False(fields[1].MapBack)
+	suite.Require().Nil(fields[1].ConvertBackTo)
+}
+
+func (suite *replacesSuite) TestRenderInverseInputMappers() {
+	src, err := os.ReadFile("replaces_directive.gotpl")
+	suite.Require().NoError(err)
+	tmpl, err := template.New("replaces_directive.gotpl").Funcs(_testTemplateFuncs).Parse(string(src))
+	suite.Require().NoError(err)
+
+	localeID := types.NewNamed(
+		types.NewTypeName(0, _testGraphQLPkg, "LocaleID", nil), types.Typ[types.String], nil)
+	templateData := &_templateData{
+		InputObjects: []_templateDataInputObject{{
+			Name: "DomainInput",
+			Fields: []_templateDataField{{
+				NewName:       "kaLocaleId",
+				OldName:       "locale",
+				NewGoName:     "KaLocaleID",
+				OldGoName:     "Locale",
+				ConvertTo:     localeID,
+				MapBack:       true,
+				ConvertBackTo: types.Typ[types.String],
+			}},
+		}},
+	}
+	render := func() string {
+		var out strings.Builder
+		suite.Require().NoError(tmpl.Execute(&out, templateData))
+		// Ignore the template's whitespace.
+		return strings.Join(strings.Fields(out.String()), " ")
+	}
+
+	rendered := render()
+	suite.Require().Contains(rendered, "func ValidateAndRenameDomainInput(")
+	suite.Require().NotContains(rendered, "NewToOldDomainInput")
+
+	templateData.InverseInputMappers = true
+	rendered = render()
+	suite.Require().Contains(rendered,
+		"if newIsSet { input.KaLocaleID = new } else { if old != nil { "+
+			"converted := graphql.LocaleID(*old) input.KaLocaleID = &converted } else { "+
+			"input.KaLocaleID = nil } }")
+	suite.Require().Contains(rendered,
+		"func NewToOldDomainInput(input *DomainInput) *DomainInput { "+
+			"if input == nil { return nil } output := *input "+
+			"// Handle KaLocaleID -> Locale if output.KaLocaleID != nil { "+
+			"converted := string(*output.KaLocaleID) output.Locale = &converted "+
+			"output.KaLocaleID = nil } return &output }")
+}
+
+func (suite *replacesSuite) TestEnumValueGoName() {
+	tests := []struct {
+		enumName  string
+		valueName string
+		expected  string
+	}{
+		{"ContentKind", "TOPIC", "ContentKindTopic"},
+		{"ContentKind", "NOT_FOUND", "ContentKindNotFound"},
+		{"ContentKind", "ID", "ContentKindID"},
+		{"ContentKind", "USER_ID", "ContentKindUserID"},
+		{"ContentKind", "URL_NOT_FOUND", "ContentKindURLNotFound"},
+		{"ContentKind", "V2", "ContentKindV2"},
+		{"ContentKind", "snake_case_value", "ContentKindSnakeCaseValue"},
+		{"ContentKind", "camelCaseValue", "ContentKindCamelCaseValue"},
+	}
+
+	for _, test := range tests {
+		suite.Require().Equal(
+			test.expected, EnumValueGoName(test.enumName, test.valueName),
+			"%s.%s", test.enumName, test.valueName)
+	}
+}
+
+func (suite *replacesSuite) TestGetSchemaInfoEnumValueRenames() {
+	schema, err := parse(`
+		enum ContentKind {
+			DOMAIN
+			COURSE @replaces(name: "TOPIC")
+		}
+	`)
+	suite.Require().NoError(err)
+
+	schemaInfo, err := _getSchemaInfo(schema)
+	suite.Require().NoError(err)
+
+	suite.Require().Equal([]*_enumValueInfo{
+		{enumName: "ContentKind", newName: "COURSE", oldName: "TOPIC"},
+	}, schemaInfo.renamedEnumValues)
+}
+
+func (suite *replacesSuite) TestConstructTemplateDataConstructsEnumMapperData() {
+	schemaInfo := &_schemaInfo{
+		renamedEnumValues: []*_enumValueInfo{
+			{enumName: "ContentKind", newName: "UNIT", oldName: "SUBJECT"},
+			{enumName: "ContentKind", newName: "COURSE", oldName: "TOPIC"},
+			{enumName: "ContentKind", newName: "COURSE", oldName: "CURRICULUM"},
+		},
+	}
+
+	templateData, err := _constructTemplateData(&codegen.Data{}, schemaInfo)
+	suite.Require().NoError(err)
+
+	expected := &_templateData{
+		Enums: []_templateDataEnum{
+			{
+				Name: "ContentKind",
+				Values: []_templateDataEnumValue{
+					{NewGoName: "ContentKindCourse", OldGoName: "ContentKindCurriculum"},
+					{NewGoName: "ContentKindUnit", OldGoName: "ContentKindSubject"},
+					{NewGoName: "ContentKindCourse", OldGoName: "ContentKindTopic"},
+				},
+				// COURSE maps back to TOPIC, its first old name.
+				DeprecatedValues: []_templateDataEnumValue{
+					{NewGoName: "ContentKindCourse", OldGoName: "ContentKindTopic"},
+					{NewGoName: "ContentKindUnit", OldGoName: "ContentKindSubject"},
+				},
+			},
+		},
+	}
+
+	suite.Require().Equal(expected, templateData)
+}
+
+func (suite *replacesSuite) TestRenderEnumMappers() {
+	src, err := os.ReadFile("replaces_directive.gotpl")
+	suite.Require().NoError(err)
+	tmpl, err := template.New("replaces_directive.gotpl").Funcs(_testTemplateFuncs).Parse(string(src))
+	suite.Require().NoError(err)
+
+	var out strings.Builder
+	suite.Require().NoError(tmpl.Execute(&out, &_templateData{
+		Enums: []_templateDataEnum{
+			{
+				Name: "ContentKind",
+				Values: []_templateDataEnumValue{
+					{NewGoName: "ContentKindCourse", OldGoName: "ContentKindTopic"},
+				},
+				DeprecatedValues: []_templateDataEnumValue{
+					{NewGoName: "ContentKindCourse", OldGoName: "ContentKindTopic"},
+				},
+			},
+		},
+	}))
+	// Ignore the template's whitespace.
+	rendered := strings.Join(strings.Fields(out.String()), " ")
+
+	suite.Require().Contains(rendered,
+		"func MapDeprecatedContentKindValue(value ContentKind) ContentKind { "+
+			"switch value { case ContentKindTopic: return ContentKindCourse } return value }")
+	suite.Require().Contains(rendered,
+		"func MapContentKindValueToDeprecated(value ContentKind) ContentKind { "+
+			"switch value { case ContentKindCourse: return ContentKindTopic } return value }")
+}
+
+// _dryRunData returns codegen data whose generated exec file would be in a
+// new temporary directory.
+func (suite *replacesSuite) _dryRunData() *codegen.Data {
+	cfg := &config.Config{
+		Exec: config.ExecConfig{
+			Filename: filepath.Join(suite.T().TempDir(), "generated.go"),
+			Package:  "generated",
+		},
+	}
+	// config.Config.Packages is of a type internal to gqlgen, so we can only
+	// make one by reflection.
+	packages := reflect.ValueOf(cfg).Elem().FieldByName("Packages")
+	packages.Set(reflect.New(packages.Type().Elem()))
+	return &codegen.Data{Config: cfg}
+}
+
+func (suite *replacesSuite) TestGenerateCodeDryRun() {
+	data := suite._dryRunData()
+	dir := filepath.Dir(data.Config.Exec.Filename)
+	r := &ReplacesDirective{
+		DryRun: true,
+		schemaInfo: &_schemaInfo{
+			renamedEnumValues: []*_enumValueInfo{
+				{enumName: "ContentKind", newName: "COURSE", oldName: "TOPIC"},
+			},
+		},
+	}
+
+	suite.Require().NoError(r.GenerateCode(data))
+
+	output := r.DryRunOutput()
+	suite.Require().NotNil(output)
+	suite.Require().Equal(ReplacesDirectiveWrite, output.Action)
+	suite.Require().Equal(filepath.Join(dir, "replaces_directive.go"), output.Filename)
+	suite.Require().Contains(output.Content, "package generated")
+	// Ignore the template's whitespace.
+	suite.Require().Contains(strings.Join(strings.Fields(output.Content), " "),
+		"func MapDeprecatedContentKindValue(value ContentKind) ContentKind { "+
+			"switch value { case ContentKindTopic: return ContentKindCourse } return value }")
+
+	// We didn't write anything (or leave anything behind).
+	entries, err := os.ReadDir(dir)
+	suite.Require().NoError(err)
+	suite.Require().Empty(entries)
+}
+
+func (suite *replacesSuite) TestGenerateCodeDryRunRemove() {
+	data := suite._dryRunData()
+	r := &ReplacesDirective{DryRun: true, schemaInfo: &_schemaInfo{}}
+
+	suite.Require().NoError(r.GenerateCode(data))
+	suite.Require().Equal(ReplacesDirectiveNoop, r.DryRunOutput().Action)
+
+	genfilePath := filepath.Join(filepath.Dir(data.Config.Exec.Filename), "replaces_directive.go")
+	suite.Require().NoError(os.WriteFile(genfilePath, []byte("package generated\n"), 0o644))
+	suite.Require().NoError(r.GenerateCode(data))
+	suite.Require().Equal(ReplacesDirectiveRemove, r.DryRunOutput().Action)
+	_, err := os.Stat(genfilePath)
+	suite.Require().NoError(err)
+}
+
+// _resolverObject returns a codegen.Object of the given name whose fields
+// "id" (a plain field) and "students(minGrade: Int)" (which has a resolver)
+// are like those of a classroom.
+func _resolverObject(name string) *codegen.Object {
+	intPtr := types.NewPointer(types.Typ[types.Int])
+	students := types.NewSlice(types.Typ[types.String])
+	return &codegen.Object{
+		Definition: &ast.Definition{Kind: ast.Object, Name: name},
+		Fields: []*codegen.Field{
+			{
+				FieldDefinition: &ast.FieldDefinition{Name: "id"},
+				GoFieldName:     "ID",
+				TypeReference:   &config.TypeReference{GO: types.Typ[types.String]},
+			},
+			{
+				FieldDefinition: &ast.FieldDefinition{Name: "students"},
+				GoFieldName:     "Students",
+				TypeReference:   &config.TypeReference{GO: students},
+				IsResolver:      true,
+				Args: []*codegen.FieldArgument{{
+					ArgumentDefinition: &ast.ArgumentDefinition{Name: "minGrade"},
+					VarName:            "minGrade",
+					TypeReference:      &config.TypeReference{GO: intPtr},
+				}},
+			},
+		},
+	}
+}
+
+var _classroomRenameSchemaInfo = &_schemaInfo{
+	renamedTypes: map[string]*_typeInfo{
+		"Classroom": {
+			kind:    ast.Object,
+			newName: "Classroom",
+			oldName: "StudentList",
+		},
+	},
+}
+
+func (suite *replacesSuite) TestConstructForwardingResolvers() {
+	data := &codegen.Data{
+		Objects: codegen.Objects{_resolverObject("Classroom"), _resolverObject("StudentList")},
+	}
+
+	resolvers, err := _constructForwardingResolvers(data, _classroomRenameSchemaInfo)
+	suite.Require().NoError(err)
+
+	suite.Require().Equal([]_templateDataForwardingResolver{
+		{
+			NewGoName: "Classroom",
+			OldGoName: "StudentList",
+			Methods: []_templateDataResolverMethod{
+				{
+					Name: "Students",
+					Args: []_templateDataResolverArg{
+						{Name: "minGrade", GoType: types.NewPointer(types.Typ[types.Int])},
+					},
+					Result: types.NewSlice(types.Typ[types.String]),
+				},
+			},
+		},
+	}, resolvers)
+}
+
+func (suite *replacesSuite) TestConstructForwardingResolversNoResolvers() {
+	newObject := _resolverObject("Classroom")
+	oldObject := _resolverObject("StudentList")
+	newObject.Fields = newObject.Fields[:1]
+	oldObject.Fields = oldObject.Fields[:1]
+	data := &codegen.Data{Objects: codegen.Objects{newObject, oldObject}}
+
+	resolvers, err := _constructForwardingResolvers(data, _classroomRenameSchemaInfo)
+	suite.Require().NoError(err)
+	suite.Require().Empty(resolvers)
+}
+
+func (suite *replacesSuite) TestConstructForwardingResolversSignaturesDoNotMatch() {
+	oldObject := _resolverObject("StudentList")
+	oldObject.Fields[1].Args[0].TypeReference = &config.TypeReference{
+		GO: types.NewPointer(types.Typ[types.String]),
+	}
+	data := &codegen.Data{
+		Objects: codegen.Objects{_resolverObject("Classroom"), oldObject},
+	}
+
+	_, err := _constructForwardingResolvers(data, _classroomRenameSchemaInfo)
+	suite.Require().Error(err)
+	suite.Require().Contains(err.Error(),
+		"could not generate forwarding resolver for renamed type; resolvers do not match")
+	suite.Require().Contains(err.Error(), "field:students")
+}
+
+func (suite *replacesSuite) TestRenderForwardingResolvers() {
+	src, err := os.ReadFile("replaces_directive.gotpl")
+	suite.Require().NoError(err)
+	tmpl, err := template.New("replaces_directive.gotpl").Funcs(_testTemplateFuncs).Parse(string(src))
+	suite.Require().NoError(err)
+
+	var out strings.Builder
+	suite.Require().NoError(tmpl.Execute(&out, &_templateData{
+		ForwardingResolvers: []_templateDataForwardingResolver{
+			{
+				NewGoName: "Classroom",
+				OldGoName: "StudentList",
+				Methods: []_templateDataResolverMethod{
+					{
+						Name: "Students",
+						Args: []_templateDataResolverArg{
+							{Name: "minGrade", GoType: types.NewPointer(types.Typ[types.Int])},
+						},
+						Result: types.NewSlice(types.Typ[types.String]),
+					},
+				},
+			},
+		},
+	}))
+	// Ignore the template's whitespace.
+	rendered := strings.Join(strings.Fields(out.String()), " ")
+
+	suite.Require().Contains(rendered,
+		"func StudentListResolverFromClassroom(resolver ClassroomResolver) StudentListResolver { "+
+			"return forwardingStudentListResolver{resolver: resolver} }")
+	suite.Require().Contains(rendered,
+		"func (r forwardingStudentListResolver) Students( "+
+			"ctx context.Context, obj *StudentList, minGrade *int, ) ([]string, error) { "+
+			"return r.resolver.Students( ctx, MapStudentListToClassroom(obj), minGrade, ) }")
+}
+
 func TestReplacesDirective(t *testing.T) {
 	khantest.Run(t, new(replacesSuite))
 }